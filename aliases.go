@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+)
+
+// loadModelAliases parses MODEL_ALIASES, a comma-separated list of
+// alias=model pairs, e.g. "gpt-3.5-turbo=llama3:8b,gpt-4=llama3:70b"
+func loadModelAliases() map[string]string {
+	aliases := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv("MODEL_ALIASES"), ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		alias, model, ok := strings.Cut(pair, "=")
+		alias, model = strings.TrimSpace(alias), strings.TrimSpace(model)
+		if !ok || alias == "" || model == "" {
+			log.Printf("Warning: Ignoring invalid MODEL_ALIASES entry %q (expected alias=model)", pair)
+			continue
+		}
+		aliases[alias] = model
+	}
+	if len(aliases) > 0 {
+		log.Printf("Loaded %d model aliases", len(aliases))
+	}
+	return aliases
+}
+
+// rewriteModelAlias replaces the request body's model field when it names an
+// alias. It returns the (possibly rewritten) body and the alias that was
+// replaced, or an empty alias when the body was left untouched.
+func (p *Proxy) rewriteModelAlias(body []byte) ([]byte, string) {
+	if len(p.modelAliases) == 0 || len(body) == 0 {
+		return body, ""
+	}
+
+	var data map[string]json.RawMessage
+	if err := json.Unmarshal(body, &data); err != nil {
+		return body, ""
+	}
+	var alias string
+	if err := json.Unmarshal(data["model"], &alias); err != nil {
+		return body, ""
+	}
+	model, ok := p.modelAliases[alias]
+	if !ok {
+		return body, ""
+	}
+
+	data["model"], _ = json.Marshal(model)
+	rewritten, err := json.Marshal(data)
+	if err != nil {
+		return body, ""
+	}
+	return rewritten, alias
+}
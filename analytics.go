@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"os"
@@ -12,6 +17,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -56,34 +62,239 @@ func (a AnalyticsRecord) MarshalJSON() ([]byte, error) {
 	})
 }
 
+// UnmarshalJSON accepts the Unix timestamps MarshalJSON writes, or RFC 3339 strings
+func (a *AnalyticsRecord) UnmarshalJSON(data []byte) error {
+	type Alias AnalyticsRecord
+	aux := &struct {
+		*Alias
+		Timestamp json.RawMessage `json:"timestamp"`
+	}{
+		Alias: (*Alias)(a),
+	}
+	if err := json.Unmarshal(data, aux); err != nil {
+		return err
+	}
+
+	a.Timestamp = time.Time{}
+	if len(aux.Timestamp) == 0 || string(aux.Timestamp) == "null" {
+		return nil
+	}
+	var unix float64
+	if err := json.Unmarshal(aux.Timestamp, &unix); err == nil {
+		sec, frac := math.Modf(unix)
+		a.Timestamp = time.Unix(int64(sec), int64(frac*1e9))
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(aux.Timestamp, &text); err != nil {
+		return fmt.Errorf("timestamp must be Unix seconds or an RFC 3339 string")
+	}
+	ts, err := time.Parse(time.RFC3339, text)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp: %w", err)
+	}
+	a.Timestamp = ts
+	return nil
+}
+
+const (
+	DefaultRetentionDays   = 7
+	DefaultCleanupInterval = 1 * time.Hour
+	DefaultVacuumInterval  = 24 * time.Hour
+	DefaultReopenInterval  = 30 * time.Second
+	DefaultCacheTTL        = 10 * time.Second
+
+	DefaultPromptPreviewLen   = 1000 // Characters of each prompt stored (0 = full prompt)
+	DefaultResponsePreviewLen = 200  // Characters of each response stored (0 = full response)
+)
+
+// sqliteDBFile is the SQLite database file name inside the analytics directory
+const sqliteDBFile = "ollama_analytics.db"
+
+// analyticsStore is the SQL database behind an AnalyticsWriter.
+// Queries are written once using '?' placeholders and SQLite-flavoured SQL,
+// and each store adapts the dialect-specific parts.
+type analyticsStore interface {
+	// DB returns the underlying database handle
+	DB() *sql.DB
+	// Rebind converts '?' placeholders to the store's placeholder syntax
+	Rebind(query string) string
+	// EpochExpr returns an expression yielding Unix seconds for a timestamp column
+	EpochExpr(column string) string
+	// LikeOp returns the case-insensitive LIKE operator
+	LikeOp() string
+	// JSONFieldExpr returns an expression yielding a top-level string field of a JSON text column
+	JSONFieldExpr(column, field string) string
+	// JSONTrueExpr returns a condition that holds when a top-level field of a JSON text column is true
+	JSONTrueExpr(column, field string) string
+}
+
+// sqliteStore implements analyticsStore for SQLite
+type sqliteStore struct {
+	db *sql.DB
+}
+
+func (s *sqliteStore) DB() *sql.DB                { return s.db }
+func (s *sqliteStore) Rebind(query string) string { return query }
+func (s *sqliteStore) LikeOp() string             { return "LIKE" }
+
+func (s *sqliteStore) EpochExpr(column string) string {
+	return fmt.Sprintf("CAST(strftime('%%s', %s) AS INTEGER)", column)
+}
+
+func (s *sqliteStore) JSONFieldExpr(column, field string) string {
+	return fmt.Sprintf("json_extract(%s, '$.%s')", column, field)
+}
+
+func (s *sqliteStore) JSONTrueExpr(column, field string) string {
+	// json_extract returns JSON true as the integer 1
+	return fmt.Sprintf("json_extract(%s, '$.%s') = 1", column, field)
+}
+
 // AnalyticsWriter handles writing analytics to storage
 type AnalyticsWriter struct {
-	backend    string
-	dataDir    string
-	db         *sql.DB
-	writeQueue chan AnalyticsRecord
-	wg         sync.WaitGroup
-	mu         sync.RWMutex
-	shutdown   chan bool
+	backend         string
+	dataDir         string
+	store           analyticsStore // nil until the database opens; guarded by storeMu
+	storeErr        error          // Why the database failed to open (nil when open or disabled)
+	storeMu         sync.RWMutex
+	reopenInterval  time.Duration  // How often a failed database open is retried
+	writeQueue      chan AnalyticsRecord
+	wg              sync.WaitGroup
+	mu              sync.RWMutex // Guards the dashboard query cache below
+	shutdown        chan bool
+	metrics         *MetricsCollector // Queue depth and drop metrics (may be nil)
+	retentionDays   int               // 0 disables age-based cleanup
+	maxRows         int               // Newest rows kept (0 = no row cap)
+	cleanupInterval time.Duration     // How often old records are purged
+	vacuumInterval  time.Duration     // How often the SQLite file is compacted
+	storePrompts    string            // Content storage mode for prompts (see getContentStorageMode)
+	storeResponses  string            // Content storage mode for response previews
+	sampleRate      float64           // Fraction of successful requests stored (errors always are)
+
+	promptPreviewLen   int // Max prompt length stored (0 = unlimited)
+	responsePreviewLen int // Max response length stored (0 = unlimited)
+
+	// Short-lived cache for frequently polled dashboard queries
+	cacheTTL       time.Duration
+	cachedModels   []string
+	modelsCachedAt time.Time
+	cachedCount    int
+	countCachedAt  time.Time
+
+	live recordBroadcaster // Fan-out of new records to /analytics/live subscribers
+
+	// Maintenance pause (/admin/analytics/pause)
+	paused        atomic.Bool
+	pausedSince   atomic.Int64 // Unix time the current pause started
+	pausedDropped atomic.Int64 // Records dropped during the current pause
+	writeMu       sync.Mutex   // Held by the writer around each insert so Pause can wait for it
+}
+
+// Content storage modes for STORE_PROMPTS and STORE_RESPONSES
+const (
+	ContentStoreFull = "true"  // Store the (truncated) text
+	ContentStoreNone = "false" // Store an empty string
+	ContentStoreHash = "hash"  // Store a SHA-256 hash so identical content can still be correlated
+)
+
+// getContentStorageMode reads a content storage mode from the environment (default: full)
+func getContentStorageMode(name string) string {
+	value := strings.ToLower(strings.TrimSpace(os.Getenv(name)))
+	switch value {
+	case "":
+		return ContentStoreFull
+	case ContentStoreFull, ContentStoreNone, ContentStoreHash:
+		return value
+	}
+	log.Printf("Warning: Invalid value for %s: %q (expected true, false or hash), using true", name, value)
+	return ContentStoreFull
+}
+
+// getSampleRate reads ANALYTICS_SAMPLE_RATE, the fraction of successful
+// requests to store (default 1.0, every request)
+func getSampleRate() float64 {
+	value := strings.TrimSpace(os.Getenv("ANALYTICS_SAMPLE_RATE"))
+	if value == "" {
+		return 1.0
+	}
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate < 0 || rate > 1 {
+		log.Printf("Warning: Invalid value for ANALYTICS_SAMPLE_RATE: %q (expected 0.0-1.0), using 1.0", value)
+		return 1.0
+	}
+	return rate
+}
+
+// Sampled reports whether a successful request should be stored under the
+// configured sample rate
+func (aw *AnalyticsWriter) Sampled() bool {
+	return aw.sampleRate >= 1 || rand.Float64() < aw.sampleRate
+}
+
+// storedContent applies a content storage mode to text before it is persisted
+func storedContent(mode, text string, maxLen int) string {
+	switch mode {
+	case ContentStoreNone:
+		return ""
+	case ContentStoreHash:
+		if text == "" {
+			return ""
+		}
+		return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(text)))
+	}
+	return truncate(text, maxLen)
 }
 
 // NewAnalyticsWriter creates a new analytics writer
-func NewAnalyticsWriter(backend, dataDir string) *AnalyticsWriter {
+func NewAnalyticsWriter(backend, dataDir string, metrics *MetricsCollector) *AnalyticsWriter {
 	// Ensure data directory exists
 	os.MkdirAll(dataDir, 0755)
 	
+	// Retention settings (ANALYTICS_RETENTION_DAYS=0 keeps data forever)
+	retentionDays := getEnvInt("ANALYTICS_RETENTION_DAYS", DefaultRetentionDays)
+	if retentionDays < 0 {
+		log.Printf("Warning: ANALYTICS_RETENTION_DAYS must not be negative, using default %d", DefaultRetentionDays)
+		retentionDays = DefaultRetentionDays
+	}
+	maxRows := getEnvInt("ANALYTICS_MAX_ROWS", 0)
+	if maxRows < 0 {
+		log.Printf("Warning: ANALYTICS_MAX_ROWS must not be negative, ignoring")
+		maxRows = 0
+	}
+
 	aw := &AnalyticsWriter{
-		backend:    backend,
-		dataDir:    dataDir,
-		writeQueue: make(chan AnalyticsRecord, 1000),
-		shutdown:   make(chan bool),
+		backend:         backend,
+		dataDir:         dataDir,
+		writeQueue:      make(chan AnalyticsRecord, 1000),
+		shutdown:        make(chan bool),
+		metrics:         metrics,
+		retentionDays:   retentionDays,
+		maxRows:         maxRows,
+		cleanupInterval: getEnvDuration("ANALYTICS_CLEANUP_INTERVAL", DefaultCleanupInterval),
+		vacuumInterval:  getEnvDuration("ANALYTICS_VACUUM_INTERVAL", DefaultVacuumInterval),
+		reopenInterval:  getEnvDuration("ANALYTICS_REOPEN_INTERVAL", DefaultReopenInterval),
+		storePrompts:    getContentStorageMode("STORE_PROMPTS"),
+		storeResponses:  getContentStorageMode("STORE_RESPONSES"),
+		sampleRate:      getSampleRate(),
+		cacheTTL:        getEnvDuration("ANALYTICS_CACHE_TTL", DefaultCacheTTL),
+
+		promptPreviewLen:   getEnvInt("PROMPT_PREVIEW_LEN", DefaultPromptPreviewLen),
+		responsePreviewLen: getEnvInt("RESPONSE_PREVIEW_LEN", DefaultResponsePreviewLen),
+	}
+	if aw.promptPreviewLen < 0 {
+		aw.promptPreviewLen = DefaultPromptPreviewLen
+	}
+	if aw.responsePreviewLen < 0 {
+		aw.responsePreviewLen = DefaultResponsePreviewLen
 	}
 
-	if backend == "sqlite" {
-		if err := aw.initSQLite(); err != nil {
-			log.Printf("Failed to initialize SQLite: %v", err)
-			return aw
-		}
+	if err := aw.open(); err != nil {
+		// Keep proxying in a degraded state and retry until the database
+		// can be opened again (e.g. once disk space or permissions are fixed)
+		log.Printf("Analytics unavailable, retrying every %s: %v", aw.reopenInterval, err)
+		aw.wg.Add(1)
+		go aw.reopenLoop()
 	}
 
 	// Start writer goroutine
@@ -100,9 +311,54 @@ func NewAnalyticsWriter(backend, dataDir string) *AnalyticsWriter {
 	return aw
 }
 
+// open connects the configured database backend, recording the failure
+// reason so it can be reported while analytics are degraded
+func (aw *AnalyticsWriter) open() error {
+	var err error
+	switch aw.backend {
+	case "sqlite":
+		// Recreate the directory in case it was missing or unwritable at startup
+		os.MkdirAll(aw.dataDir, 0755)
+		if err = aw.initSQLite(); err != nil {
+			err = fmt.Errorf("failed to initialize SQLite: %w", err)
+		}
+	case "postgres":
+		if err = aw.initPostgres(os.Getenv("ANALYTICS_DSN")); err != nil {
+			err = fmt.Errorf("failed to initialize PostgreSQL: %w", err)
+		}
+	}
+
+	aw.storeMu.Lock()
+	aw.storeErr = err
+	aw.storeMu.Unlock()
+	return err
+}
+
+// reopenLoop retries opening the database until it succeeds or the writer shuts down
+func (aw *AnalyticsWriter) reopenLoop() {
+	defer aw.wg.Done()
+
+	ticker := time.NewTicker(aw.reopenInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := aw.open(); err != nil {
+				log.Printf("Analytics still unavailable: %v", err)
+				continue
+			}
+			log.Printf("Analytics database opened, recording resumed")
+			return
+		case <-aw.shutdown:
+			return
+		}
+	}
+}
+
 // initSQLite initializes the SQLite database
 func (aw *AnalyticsWriter) initSQLite() error {
-	dbPath := filepath.Join(aw.dataDir, "ollama_analytics.db")
+	dbPath := filepath.Join(aw.dataDir, sqliteDBFile)
 
 	// Add WAL mode and timeout to connection string for better concurrency
 	connStr := dbPath + "?_journal=WAL&_timeout=5000&_busy_timeout=5000"
@@ -146,6 +402,7 @@ func (aw *AnalyticsWriter) initSQLite() error {
 	);`
 
 	if _, err := db.Exec(createTableSQL); err != nil {
+		db.Close()
 		return fmt.Errorf("failed to create table: %w", err)
 	}
 
@@ -172,6 +429,7 @@ func (aw *AnalyticsWriter) initSQLite() error {
 		"CREATE INDEX IF NOT EXISTS idx_timestamp ON interactions(timestamp);",
 		"CREATE INDEX IF NOT EXISTS idx_model ON interactions(model);",
 		"CREATE INDEX IF NOT EXISTS idx_prompt_category ON interactions(prompt_category);",
+		"CREATE INDEX IF NOT EXISTS idx_user ON interactions(\"user\");",
 	}
 
 	for _, idx := range indexes {
@@ -180,16 +438,112 @@ func (aw *AnalyticsWriter) initSQLite() error {
 		}
 	}
 
-	aw.db = db
+	aw.setStore(&sqliteStore{db: db})
 	return nil
 }
 
-// Record queues a record for writing
+// Available reports whether a database backend is ready for queries
+func (aw *AnalyticsWriter) Available() bool {
+	return aw.getStore() != nil
+}
+
+// getStore returns the open database, or nil while unavailable
+func (aw *AnalyticsWriter) getStore() analyticsStore {
+	aw.storeMu.RLock()
+	defer aw.storeMu.RUnlock()
+	return aw.store
+}
+
+// setStore publishes a newly opened database
+func (aw *AnalyticsWriter) setStore(store analyticsStore) {
+	aw.storeMu.Lock()
+	aw.store = store
+	aw.storeMu.Unlock()
+}
+
+// unavailableReason explains why a database backend is not available, or
+// returns "" when it is open (or no database backend is configured)
+func (aw *AnalyticsWriter) unavailableReason() string {
+	aw.storeMu.RLock()
+	defer aw.storeMu.RUnlock()
+	if aw.storeErr != nil {
+		return aw.storeErr.Error()
+	}
+	return ""
+}
+
+// query runs a query against the analytics store, adapting placeholders
+func (aw *AnalyticsWriter) query(query string, args ...interface{}) (*sql.Rows, error) {
+	store := aw.getStore()
+	return store.DB().Query(store.Rebind(query), args...)
+}
+
+// queryRow runs a single-row query against the analytics store
+func (aw *AnalyticsWriter) queryRow(query string, args ...interface{}) *sql.Row {
+	store := aw.getStore()
+	return store.DB().QueryRow(store.Rebind(query), args...)
+}
+
+// exec runs a statement against the analytics store
+func (aw *AnalyticsWriter) exec(query string, args ...interface{}) (sql.Result, error) {
+	store := aw.getStore()
+	return store.DB().Exec(store.Rebind(query), args...)
+}
+
+// Record queues a record for writing. While paused the record is dropped
+// before it reaches live subscribers, as in RecordWait.
 func (aw *AnalyticsWriter) Record(record AnalyticsRecord) {
+	if aw.Paused() {
+		aw.pausedDropped.Add(1)
+		return
+	}
+	aw.publishLive(record)
+
 	select {
 	case aw.writeQueue <- record:
+		aw.updateQueueDepth()
 	default:
 		log.Println("Analytics queue full, dropping record")
+		if aw.metrics != nil {
+			aw.metrics.analyticsDropped.Inc()
+		}
+	}
+}
+
+// RecordWait queues a record for writing, waiting for room in the queue
+// instead of dropping the record. Used for bulk ingestion.
+func (aw *AnalyticsWriter) RecordWait(ctx context.Context, record AnalyticsRecord) error {
+	if aw.Paused() {
+		return errAnalyticsPaused
+	}
+	aw.publishLive(record)
+
+	select {
+	case aw.writeQueue <- record:
+		aw.updateQueueDepth()
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// publishLive sends a record to live subscribers with the same content redaction as storage
+func (aw *AnalyticsWriter) publishLive(record AnalyticsRecord) {
+	aw.live.publish(aw.redacted(record))
+}
+
+// redacted returns a copy of record with prompt and response stored the way
+// STORE_PROMPTS and STORE_RESPONSES ask, for views served outside the database
+func (aw *AnalyticsWriter) redacted(record AnalyticsRecord) AnalyticsRecord {
+	record.Prompt = storedContent(aw.storePrompts, record.Prompt, aw.promptPreviewLen)
+	record.ResponsePreview = storedContent(aw.storeResponses, record.ResponsePreview, aw.responsePreviewLen)
+	return record
+}
+
+// updateQueueDepth publishes the current write queue length
+func (aw *AnalyticsWriter) updateQueueDepth() {
+	if aw.metrics != nil {
+		aw.metrics.analyticsQueueDepth.Set(float64(len(aw.writeQueue)))
 	}
 }
 
@@ -198,21 +552,30 @@ func (aw *AnalyticsWriter) writerLoop() {
 	defer aw.wg.Done()
 
 	for record := range aw.writeQueue {
-		if aw.backend == "sqlite" && aw.db != nil {
-			aw.writeSQLite(record)
+		aw.writeMu.Lock()
+		if aw.Paused() {
+			// Queued before the pause; dropped like records arriving during it
+			aw.pausedDropped.Add(1)
+		} else if aw.Available() {
+			aw.writeRecord(record)
+		} else if aw.metrics != nil && aw.unavailableReason() != "" {
+			// Degraded: the record can't be stored, count it instead of losing it silently
+			aw.metrics.analyticsDropped.Inc()
 		}
+		aw.writeMu.Unlock()
+		aw.updateQueueDepth()
 	}
 }
 
-// writeSQLite writes a record to SQLite
-func (aw *AnalyticsWriter) writeSQLite(record AnalyticsRecord) {
+// writeRecord writes a record to the analytics store
+func (aw *AnalyticsWriter) writeRecord(record AnalyticsRecord) {
 	query := `
 	INSERT INTO interactions (
 		timestamp, model, endpoint, prompt, prompt_category,
 		response_preview, duration_seconds, tokens_generated,
 		tokens_per_second, prompt_tokens, load_duration, total_duration,
 		status_code, error_message, user_agent, client_ip,
-		user, cost, status, queue_time, time_to_first_token, metadata
+		"user", cost, status, queue_time, time_to_first_token, metadata
 	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	// Serialize metadata to JSON
@@ -223,13 +586,13 @@ func (aw *AnalyticsWriter) writeSQLite(record AnalyticsRecord) {
 		}
 	}
 
-	_, err := aw.db.Exec(query,
+	_, err := aw.exec(query,
 		record.Timestamp,
 		record.Model,
 		record.Endpoint,
-		truncate(record.Prompt, 1000),
+		storedContent(aw.storePrompts, record.Prompt, aw.promptPreviewLen),
 		record.PromptCategory,
-		truncate(record.ResponsePreview, 200),
+		storedContent(aw.storeResponses, record.ResponsePreview, aw.responsePreviewLen),
 		record.DurationSeconds,
 		record.TokensGenerated,
 		record.TokensPerSecond,
@@ -253,26 +616,38 @@ func (aw *AnalyticsWriter) writeSQLite(record AnalyticsRecord) {
 	}
 }
 
-// cleanupLoop periodically removes old data
+// cleanupLoop periodically removes records older than the retention period
+// or beyond the row cap, whichever triggers first, and compacts SQLite
 func (aw *AnalyticsWriter) cleanupLoop() {
-	ticker := time.NewTicker(1 * time.Hour)
+	if aw.retentionDays == 0 && aw.maxRows == 0 {
+		log.Printf("Analytics retention disabled, records will be kept indefinitely")
+		return
+	}
+	if aw.retentionDays > 0 {
+		log.Printf("Analytics retention: %d days (cleanup every %s)", aw.retentionDays, aw.cleanupInterval)
+	}
+	if aw.maxRows > 0 {
+		log.Printf("Analytics retention: newest %d records (cleanup every %s)", aw.maxRows, aw.cleanupInterval)
+	}
+
+	ticker := time.NewTicker(aw.cleanupInterval)
 	defer ticker.Stop()
+	lastVacuum := time.Now()
 
 	for {
 		select {
 		case <-ticker.C:
-			if aw.backend == "sqlite" && aw.db != nil {
-				cutoff := time.Now().AddDate(0, 0, -7) // 7 days retention
-				query := "DELETE FROM interactions WHERE timestamp < ?"
-				
-				result, err := aw.db.Exec(query, cutoff)
-				if err != nil {
-					log.Printf("Cleanup error: %v", err)
-					continue
-				}
-				
-				if rows, _ := result.RowsAffected(); rows > 0 {
-					log.Printf("Cleaned up %d old analytics records", rows)
+			// Paused for maintenance: skip this pass rather than delete or vacuum
+			if !aw.Available() || aw.Paused() {
+				continue
+			}
+			aw.cleanup()
+
+			// Deleting rows leaves free pages behind; VACUUM gives them back to the filesystem
+			if aw.backend == "sqlite" && time.Since(lastVacuum) >= aw.vacuumInterval {
+				lastVacuum = time.Now()
+				if _, err := aw.exec("VACUUM"); err != nil {
+					log.Printf("Vacuum error: %v", err)
 				}
 			}
 		case <-aw.shutdown:
@@ -281,13 +656,157 @@ func (aw *AnalyticsWriter) cleanupLoop() {
 	}
 }
 
-// Search performs analytics search
+// Cleanup deletes in batches so the single writer connection is released
+// between them and queued inserts aren't blocked for the whole purge
+const (
+	cleanupBatchSize  = 5000
+	cleanupBatchPause = 100 * time.Millisecond
+)
+
+// cleanup runs one pass of age- and row-count-based deletion
+func (aw *AnalyticsWriter) cleanup() {
+	if aw.retentionDays > 0 {
+		cutoff := time.Now().AddDate(0, 0, -aw.retentionDays)
+		rows, err := aw.deleteInBatches("timestamp < ?", cutoff)
+		if err != nil {
+			log.Printf("Cleanup error after removing %d records: %v", rows, err)
+		} else if rows > 0 {
+			log.Printf("Cleaned up %d old analytics records", rows)
+		}
+	}
+
+	if aw.maxRows > 0 {
+		// ids increase with insertion order, so everything at or below the
+		// (maxRows+1)th newest id is beyond the cap
+		var cutoffID int64
+		err := aw.queryRow("SELECT id FROM interactions ORDER BY id DESC LIMIT 1 OFFSET ?", aw.maxRows).Scan(&cutoffID)
+		if err == sql.ErrNoRows {
+			return
+		} else if err != nil {
+			log.Printf("Cleanup error: %v", err)
+			return
+		}
+		rows, err := aw.deleteInBatches("id <= ?", cutoffID)
+		if err != nil {
+			log.Printf("Cleanup error after removing %d records: %v", rows, err)
+		} else if rows > 0 {
+			log.Printf("Cleaned up %d analytics records beyond ANALYTICS_MAX_ROWS=%d", rows, aw.maxRows)
+		}
+	}
+}
+
+// deleteInBatches deletes records matching condition, cleanupBatchSize rows
+// at a time, pausing between batches. It returns the total rows removed and
+// stops early on shutdown.
+func (aw *AnalyticsWriter) deleteInBatches(condition string, args ...interface{}) (int64, error) {
+	query := "DELETE FROM interactions WHERE id IN (SELECT id FROM interactions WHERE " + condition +
+		" ORDER BY id LIMIT ?)"
+	args = append(args, cleanupBatchSize)
+
+	var total int64
+	for {
+		result, err := aw.exec(query, args...)
+		if err != nil {
+			return total, err
+		}
+		rows, _ := result.RowsAffected()
+		total += rows
+		if rows < cleanupBatchSize {
+			return total, nil
+		}
+
+		select {
+		case <-time.After(cleanupBatchPause):
+		case <-aw.shutdown:
+			return total, nil
+		}
+	}
+}
+
+// Search performs analytics search.
+//
+// Text filters:
+//   - search (or prompt_search if search is empty) matches the prompt
+//   - response_search matches the response preview
+//   - text_search matches either the prompt or the response preview
+//
+// When several text filters are supplied they are combined with AND, so a
+// record must satisfy every one of them.
 func (aw *AnalyticsWriter) Search(params url.Values) ([]AnalyticsRecord, error) {
-	if aw.backend != "sqlite" || aw.db == nil {
-		return nil, fmt.Errorf("search only available with sqlite backend")
+	limit, offset := searchPaging(params)
+
+	results := make([]AnalyticsRecord, 0)
+	err := aw.SearchEach(params, limit, offset, func(r AnalyticsRecord) error {
+		results = append(results, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// searchPageSize is how many rows SearchEach reads per query
+const searchPageSize = 500
+
+// SearchEach streams records matching the search filters to fn, newest
+// first. Records are read in keyset pages and the database connection is
+// released before fn sees them, so a slow consumer (an export to a slow
+// client) never holds SQLite's single connection away from the writer.
+// A limit of 0 returns every matching record. Iteration stops at the
+// first error returned by fn.
+func (aw *AnalyticsWriter) SearchEach(params url.Values, limit, offset int, fn func(AnalyticsRecord) error) error {
+	store := aw.getStore()
+	if store == nil {
+		return fmt.Errorf("search only available with sqlite or postgres backend")
 	}
 
-	query := "SELECT id, timestamp, model, endpoint, prompt, prompt_category, response_preview, duration_seconds, tokens_generated, tokens_per_second, prompt_tokens, load_duration, total_duration, status_code, error_message, user_agent, client_ip, user, cost, status, queue_time, time_to_first_token, metadata FROM interactions WHERE 1=1"
+	where, args := aw.buildSearchFilter(params)
+	var last *AnalyticsRecord
+	for {
+		pageSize := searchPageSize
+		if limit > 0 && limit < pageSize {
+			pageSize = limit
+		}
+
+		// Continue after the last record of the previous page; the offset
+		// only applies to the first page
+		query := "SELECT " + recordColumns + " FROM interactions" + where
+		pageArgs := append([]interface{}{}, args...)
+		if last != nil {
+			query += " AND (timestamp < ? OR (timestamp = ? AND id < ?))"
+			pageArgs = append(pageArgs, last.Timestamp, last.Timestamp, last.ID)
+		}
+		query += " ORDER BY timestamp DESC, id DESC LIMIT ? OFFSET ?"
+		pageArgs = append(pageArgs, pageSize, offset)
+		offset = 0
+
+		page, err := queryRecords(store.DB(), store.Rebind(query), pageArgs...)
+		if err != nil {
+			return fmt.Errorf("search %w", err)
+		}
+		for _, r := range page {
+			if err := fn(r); err != nil {
+				return err
+			}
+		}
+
+		if len(page) < pageSize {
+			return nil
+		}
+		if limit > 0 {
+			if limit -= len(page); limit == 0 {
+				return nil
+			}
+		}
+		last = &page[len(page)-1]
+	}
+}
+
+// buildSearchFilter builds the WHERE clause shared by Search and SearchCount
+func (aw *AnalyticsWriter) buildSearchFilter(params url.Values) (string, []interface{}) {
+	query := " WHERE 1=1"
 	args := []interface{}{}
 
 	// Build query conditions
@@ -296,16 +815,78 @@ func (aw *AnalyticsWriter) Search(params url.Values) ([]AnalyticsRecord, error)
 		args = append(args, model)
 	}
 
+	if user := params.Get("user"); user != "" {
+		query += " AND \"user\" = ?"
+		args = append(args, user)
+	}
+
+	if tag := params.Get("tag"); tag != "" {
+		query += " AND " + aw.getStore().JSONFieldExpr("metadata", "tag") + " = ?"
+		args = append(args, tag)
+	}
+
+	if requestID := params.Get("request_id"); requestID != "" {
+		query += " AND " + aw.getStore().JSONFieldExpr("metadata", "request_id") + " = ?"
+		args = append(args, requestID)
+	}
+
+	if slow := params.Get("slow_outlier"); slow != "" {
+		if flagged, err := strconv.ParseBool(slow); err == nil {
+			cond := aw.getStore().JSONTrueExpr("metadata", "slow_outlier")
+			if !flagged {
+				cond = "NOT COALESCE(" + cond + ", FALSE)"
+			}
+			query += " AND " + cond
+		}
+	}
+
 	// Support both 'search' and 'prompt_search' parameters
 	search := params.Get("search")
 	if search == "" {
 		search = params.Get("prompt_search")
 	}
 	if search != "" {
-		query += " AND prompt LIKE ?"
+		query += " AND prompt " + aw.getStore().LikeOp() + " ?"
 		args = append(args, "%"+search+"%")
 	}
 
+	if responseSearch := params.Get("response_search"); responseSearch != "" {
+		query += " AND response_preview " + aw.getStore().LikeOp() + " ?"
+		args = append(args, "%"+responseSearch+"%")
+	}
+
+	if textSearch := params.Get("text_search"); textSearch != "" {
+		query += " AND (prompt " + aw.getStore().LikeOp() + " ? OR response_preview " + aw.getStore().LikeOp() + " ?)"
+		args = append(args, "%"+textSearch+"%", "%"+textSearch+"%")
+	}
+
+	// Status filters
+	if status := params.Get("status"); status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+
+	if statusCode := params.Get("status_code"); statusCode != "" {
+		if code, err := strconv.Atoi(statusCode); err == nil {
+			query += " AND status_code = ?"
+			args = append(args, code)
+		}
+	}
+
+	if minStatus := params.Get("min_status_code"); minStatus != "" {
+		if code, err := strconv.Atoi(minStatus); err == nil {
+			query += " AND status_code >= ?"
+			args = append(args, code)
+		}
+	}
+
+	if maxStatus := params.Get("max_status_code"); maxStatus != "" {
+		if code, err := strconv.Atoi(maxStatus); err == nil {
+			query += " AND status_code <= ?"
+			args = append(args, code)
+		}
+	}
+
 	if startTime := params.Get("start_time"); startTime != "" {
 		if ts, err := strconv.ParseInt(startTime, 10, 64); err == nil {
 			query += " AND timestamp >= ?"
@@ -320,52 +901,37 @@ func (aw *AnalyticsWriter) Search(params url.Values) ([]AnalyticsRecord, error)
 		}
 	}
 
-	// Add limit
-	limit := 100
+	return query, args
+}
+
+// searchPaging returns the limit (default 100) and offset (default 0) for a search
+func searchPaging(params url.Values) (limit, offset int) {
+	limit = 100
 	if l := params.Get("limit"); l != "" {
 		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
 			limit = parsed
 		}
 	}
-	query += " ORDER BY timestamp DESC LIMIT ?"
-	args = append(args, limit)
-
-	// Execute query
-	rows, err := aw.db.Query(query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("search query failed: %w", err)
+	if o := params.Get("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed > 0 {
+			offset = parsed
+		}
 	}
-	defer rows.Close()
+	return limit, offset
+}
 
-	results := make([]AnalyticsRecord, 0)
-	for rows.Next() {
-		var r AnalyticsRecord
-		var metadataJSON string
-		err := rows.Scan(
-			&r.ID, &r.Timestamp, &r.Model, &r.Endpoint, &r.Prompt,
-			&r.PromptCategory, &r.ResponsePreview, &r.DurationSeconds,
-			&r.TokensGenerated, &r.TokensPerSecond, &r.PromptTokens,
-			&r.LoadDuration, &r.TotalDuration, &r.StatusCode,
-			&r.ErrorMessage, &r.UserAgent, &r.ClientIP,
-			&r.User, &r.Cost, &r.Status, &r.QueueTime,
-			&r.TimeToFirstToken, &metadataJSON,
-		)
-		if err != nil {
-			log.Printf("Row scan error: %v", err)
-			continue
-		}
-		
-		// Parse metadata JSON
-		if metadataJSON != "" && metadataJSON != "{}" {
-			var metadata map[string]interface{}
-			if err := json.Unmarshal([]byte(metadataJSON), &metadata); err == nil {
-				r.Metadata = metadata
-			}
-		}
-		results = append(results, r)
+// SearchCount returns the number of records matching the search filters, ignoring paging
+func (aw *AnalyticsWriter) SearchCount(params url.Values) (int, error) {
+	if !aw.Available() {
+		return 0, fmt.Errorf("search only available with sqlite or postgres backend")
 	}
 
-	return results, nil
+	where, args := aw.buildSearchFilter(params)
+	var total int
+	if err := aw.queryRow("SELECT COUNT(*) FROM interactions"+where, args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("search count failed: %w", err)
+	}
+	return total, nil
 }
 
 // GetStats returns analytics statistics
@@ -374,25 +940,120 @@ func (aw *AnalyticsWriter) GetStats() map[string]interface{} {
 		"backend":   aw.backend,
 		"data_dir":  aw.dataDir,
 		"queue_size": len(aw.writeQueue),
+		"analytics_available": aw.Available(),
+		"sample_rate": aw.sampleRate,
+	}
+	if reason := aw.unavailableReason(); reason != "" {
+		stats["analytics_error"] = reason
 	}
 
-	if aw.backend == "sqlite" && aw.db != nil {
-		var count int
-		if err := aw.db.QueryRow("SELECT COUNT(*) FROM interactions").Scan(&count); err == nil {
+	if aw.Available() {
+		aw.mu.RLock()
+		count, cachedAt := aw.cachedCount, aw.countCachedAt
+		aw.mu.RUnlock()
+
+		if time.Since(cachedAt) < aw.cacheTTL {
+			stats["total_records"] = count
+		} else if err := aw.queryRow("SELECT COUNT(*) FROM interactions").Scan(&count); err == nil {
+			aw.mu.Lock()
+			aw.cachedCount, aw.countCachedAt = count, time.Now()
+			aw.mu.Unlock()
 			stats["total_records"] = count
 		}
 	}
+	aw.addDiskUsage(stats)
 
 	return stats
 }
 
+// addDiskUsage reports the SQLite database and WAL file sizes and the free
+// space left on the analytics volume, for tuning retention before it fills up
+func (aw *AnalyticsWriter) addDiskUsage(stats map[string]interface{}) {
+	if aw.backend == "sqlite" {
+		dbPath := filepath.Join(aw.dataDir, sqliteDBFile)
+		if info, err := os.Stat(dbPath); err == nil {
+			stats["db_size_bytes"] = info.Size()
+		}
+		if info, err := os.Stat(dbPath + "-wal"); err == nil {
+			stats["wal_size_bytes"] = info.Size()
+		}
+	}
+	if free, total, err := diskFree(aw.dataDir); err == nil {
+		stats["disk_free_bytes"] = free
+		stats["disk_total_bytes"] = total
+	}
+}
+
+// PurgeFilter selects records to delete. At least one field must be set.
+type PurgeFilter struct {
+	Before   time.Time // Records older than this (zero = no time filter)
+	Model    string
+	ClientIP string
+}
+
+// Purge deletes records matching the filter and returns the number removed
+func (aw *AnalyticsWriter) Purge(filter PurgeFilter) (int64, error) {
+	if !aw.Available() {
+		return 0, fmt.Errorf("analytics not available")
+	}
+
+	query := "DELETE FROM interactions WHERE 1=1"
+	args := []interface{}{}
+	if !filter.Before.IsZero() {
+		query += " AND timestamp < ?"
+		args = append(args, filter.Before)
+	}
+	if filter.Model != "" {
+		query += " AND model = ?"
+		args = append(args, filter.Model)
+	}
+	if filter.ClientIP != "" {
+		// client_ip is stored as "ip:port", "[ipv6]:port" or "forwarded (via remote)".
+		// The address is escaped so wildcards in it can't widen the match.
+		ip := escapeLike(filter.ClientIP)
+		query += ` AND (client_ip = ? OR client_ip LIKE ? ESCAPE '\' OR client_ip LIKE ? ESCAPE '\' OR client_ip LIKE ? ESCAPE '\')`
+		args = append(args, filter.ClientIP, ip+":%", "["+ip+"]:%", ip+" (via %")
+	}
+	if len(args) == 0 {
+		return 0, fmt.Errorf("at least one filter is required")
+	}
+
+	result, err := aw.exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("purge failed: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+
+	// Drop cached dashboard results that may include purged records
+	aw.mu.Lock()
+	aw.modelsCachedAt = time.Time{}
+	aw.countCachedAt = time.Time{}
+	aw.mu.Unlock()
+
+	return rows, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s for use with ESCAPE '\'
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
 // GetModels returns unique models from analytics
 func (aw *AnalyticsWriter) GetModels() ([]string, error) {
-	if aw.backend != "sqlite" || aw.db == nil {
+	if !aw.Available() {
 		return []string{}, nil
 	}
+
+	// Serve from cache while fresh; the dashboard polls this frequently
+	aw.mu.RLock()
+	if aw.cachedModels != nil && time.Since(aw.modelsCachedAt) < aw.cacheTTL {
+		models := append([]string(nil), aw.cachedModels...)
+		aw.mu.RUnlock()
+		return models, nil
+	}
+	aw.mu.RUnlock()
 	
-	rows, err := aw.db.Query("SELECT DISTINCT model FROM interactions WHERE model IS NOT NULL AND model != '' ORDER BY model")
+	rows, err := aw.query("SELECT DISTINCT model FROM interactions WHERE model IS NOT NULL AND model != '' ORDER BY model")
 	if err != nil {
 		return nil, err
 	}
@@ -405,21 +1066,44 @@ func (aw *AnalyticsWriter) GetModels() ([]string, error) {
 			models = append(models, model)
 		}
 	}
+
+	aw.mu.Lock()
+	aw.cachedModels = append([]string{}, models...)
+	aw.modelsCachedAt = time.Now()
+	aw.mu.Unlock()
 	
 	return models, nil
 }
 
 // GetMessageByID returns a single message by ID
 func (aw *AnalyticsWriter) GetMessageByID(id int64) (*AnalyticsRecord, error) {
-	if aw.backend != "sqlite" || aw.db == nil {
+	if !aw.Available() {
 		return nil, fmt.Errorf("analytics not available")
 	}
 	
-	query := "SELECT id, timestamp, model, endpoint, prompt, prompt_category, response_preview, duration_seconds, tokens_generated, tokens_per_second, prompt_tokens, load_duration, total_duration, status_code, error_message, user_agent, client_ip, user, cost, status, queue_time, time_to_first_token, metadata FROM interactions WHERE id = ?"
-	
+	query := "SELECT " + recordColumns + " FROM interactions WHERE id = ?"
+
+	r, err := scanRecord(aw.queryRow(query, id))
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// recordColumns are the interactions columns read by scanRecord, in order
+const recordColumns = "id, timestamp, model, endpoint, prompt, prompt_category, response_preview, duration_seconds, tokens_generated, tokens_per_second, prompt_tokens, load_duration, total_duration, status_code, error_message, user_agent, client_ip, \"user\", cost, status, queue_time, time_to_first_token, metadata"
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanRecord reads a row selected with recordColumns into an AnalyticsRecord
+func scanRecord(row rowScanner) (AnalyticsRecord, error) {
 	var r AnalyticsRecord
 	var metadataJSON string
-	err := aw.db.QueryRow(query, id).Scan(
+	err := row.Scan(
 		&r.ID, &r.Timestamp, &r.Model, &r.Endpoint, &r.Prompt,
 		&r.PromptCategory, &r.ResponsePreview, &r.DurationSeconds,
 		&r.TokensGenerated, &r.TokensPerSecond, &r.PromptTokens,
@@ -429,9 +1113,9 @@ func (aw *AnalyticsWriter) GetMessageByID(id int64) (*AnalyticsRecord, error) {
 		&r.TimeToFirstToken, &metadataJSON,
 	)
 	if err != nil {
-		return nil, err
+		return r, err
 	}
-	
+
 	// Parse metadata JSON
 	if metadataJSON != "" && metadataJSON != "{}" {
 		var metadata map[string]interface{}
@@ -439,14 +1123,17 @@ func (aw *AnalyticsWriter) GetMessageByID(id int64) (*AnalyticsRecord, error) {
 			r.Metadata = metadata
 		}
 	}
-	
-	return &r, nil
+
+	return r, nil
 }
 
 // Close shuts down the analytics writer
 func (aw *AnalyticsWriter) Close() {
 	// Signal shutdown to cleanup goroutine
 	close(aw.shutdown)
+
+	// Disconnect live feed subscribers
+	aw.live.closeAll()
 	
 	// Close write queue
 	close(aw.writeQueue)
@@ -455,14 +1142,17 @@ func (aw *AnalyticsWriter) Close() {
 	aw.wg.Wait()
 	
 	// Close database
-	if aw.db != nil {
-		aw.db.Close()
+	if store := aw.getStore(); store != nil {
+		store.DB().Close()
 	}
 }
 
 // Analytics HTTP handlers
 func (p *Proxy) handleAnalyticsStats(w http.ResponseWriter, r *http.Request) {
 	stats := p.analytics.GetStats()
+	stats["circuit_breaker"] = p.breaker.stateName()
+	stats["analytics_pause"] = p.analytics.pauseState()
+	stats["response_cache"] = p.responseCache.stats()
 	json.NewEncoder(w).Encode(stats)
 }
 
@@ -484,9 +1174,59 @@ func (p *Proxy) handleAnalyticsSearch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
+	// Total matching records across all pages, respecting the same filters
+	total, err := p.analytics.SearchCount(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	limit, offset := searchPaging(r.URL.Query())
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"results": results,
 		"count":   len(results),
+		"total":   total,
+		"offset":  offset,
+		"limit":   limit,
+	})
+}
+
+func (p *Proxy) handleAnalyticsPurge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter := PurgeFilter{
+		Model:    strings.TrimSpace(r.FormValue("model")),
+		ClientIP: strings.TrimSpace(r.FormValue("client_ip")),
+	}
+	if before := r.FormValue("before"); before != "" {
+		ts, err := strconv.ParseInt(before, 10, 64)
+		if err != nil {
+			http.Error(w, "before must be a Unix timestamp", http.StatusBadRequest)
+			return
+		}
+		filter.Before = time.Unix(ts, 0)
+	}
+	if filter.Before.IsZero() && filter.Model == "" && filter.ClientIP == "" {
+		http.Error(w, "At least one filter (before, model, client_ip) is required", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := p.analytics.Purge(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[%s] Purged %d analytics records (before=%q model=%q client_ip=%q)",
+		r.RemoteAddr, deleted, r.FormValue("before"), filter.Model, filter.ClientIP)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"deleted": deleted,
 	})
 }
 
@@ -532,11 +1272,16 @@ func (p *Proxy) handleAnalyticsDashboard(w http.ResponseWriter, r *http.Request)
 func (p *Proxy) handleAnalyticsMessageDetail(w http.ResponseWriter, r *http.Request) {
 	// Extract ID from URL path
 	path := strings.TrimPrefix(r.URL.Path, "/analytics/messages/")
+	path, replay := strings.CutSuffix(path, "/replay")
 	id, err := strconv.ParseInt(path, 10, 64)
 	if err != nil {
 		http.Error(w, "Invalid message ID", http.StatusBadRequest)
 		return
 	}
+	if replay {
+		p.handleAnalyticsReplay(w, r, id)
+		return
+	}
 	
 	message, err := p.analytics.GetMessageByID(id)
 	if err != nil {
@@ -576,6 +1321,16 @@ func (p *Proxy) handleAnalyticsExport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
+	// CSV, JSONL and Parquet stream from the database in pages
+	if format == "csv" || format == "jsonl" {
+		p.streamAnalyticsExport(w, r, format)
+		return
+	}
+	if format == "parquet" {
+		p.streamParquetExport(w, r)
+		return
+	}
+
 	// Export search results
 	results, err := p.analytics.Search(r.URL.Query())
 	if err != nil {
@@ -584,25 +1339,104 @@ func (p *Proxy) handleAnalyticsExport(w http.ResponseWriter, r *http.Request) {
 	}
 	
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=analytics_export.%s", format))
-	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// exportFlushEvery is how many rows are written between flushes when streaming exports
+const exportFlushEvery = 100
+
+// streamAnalyticsExport writes matching records row-by-row without materializing
+// the result set. Without an explicit limit every matching record is exported.
+func (p *Proxy) streamAnalyticsExport(w http.ResponseWriter, r *http.Request, format string) {
+	if !p.analytics.Available() {
+		http.Error(w, "Analytics not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	params := r.URL.Query()
+	limit, offset := searchPaging(params)
+	if params.Get("limit") == "" {
+		limit = 0
+	}
+
+	// CSV columns are validated before anything is written
+	var columns []csvColumn
+	var csvWriter *csv.Writer
 	if format == "csv" {
-		w.Header().Set("Content-Type", "text/csv")
-		w.Write([]byte("ID,Timestamp,Model,User,Prompt,Response,InputTokens,OutputTokens,Latency,Status\n"))
-		for _, r := range results {
-			fmt.Fprintf(w, "%d,%s,%s,%s,%q,%q,%d,%d,%.3f,%s\n",
-				r.ID, r.Timestamp.Format(time.RFC3339), r.Model, r.User,
-				r.Prompt, r.ResponsePreview, r.PromptTokens, r.TokensGenerated,
-				r.DurationSeconds, r.Status)
+		var err error
+		if columns, err = selectCSVColumns(params.Get("columns")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=analytics_export.%s", format))
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		// RFC 4180 quoting: fields with commas, quotes or line breaks are
+		// quoted, and rows end in CRLF as spreadsheets expect
+		csvWriter = csv.NewWriter(w)
+		csvWriter.UseCRLF = true
+		header := make([]string, len(columns))
+		for i, col := range columns {
+			header[i] = col.header
+		}
+		csvWriter.Write(header)
 	} else {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(results)
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	rowCount := 0
+	row := make([]string, len(columns))
+
+	err := p.analytics.SearchEach(params, limit, offset, func(rec AnalyticsRecord) error {
+		var err error
+		if csvWriter != nil {
+			for i, col := range columns {
+				row[i] = col.value(rec)
+			}
+			err = csvWriter.Write(row)
+		} else {
+			err = encoder.Encode(rec)
+		}
+		if err != nil {
+			return err
+		}
+
+		rowCount++
+		if rowCount%exportFlushEvery == 0 {
+			if csvWriter != nil {
+				csvWriter.Flush()
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return nil
+	})
+	if csvWriter != nil {
+		csvWriter.Flush()
+		if err == nil {
+			err = csvWriter.Error()
+		}
+	}
+	if err != nil {
+		// Headers are already sent, so the best we can do is log and stop
+		log.Printf("Export stopped after %d records: %v", rowCount, err)
+		return
+	}
+
+	if flusher != nil {
+		flusher.Flush()
 	}
 }
 
-// truncate limits string length
+// truncate limits string length (maxLen <= 0 means no limit)
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
+	if maxLen <= 0 || len(s) <= maxLen {
 		return s
 	}
 	return s[:maxLen]
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csvColumn is one selectable column of the CSV export
+type csvColumn struct {
+	name   string // Name used in ?columns=, matching the JSON field
+	header string
+	value  func(rec AnalyticsRecord) string
+}
+
+// csvColumns lists every exportable column in their default order
+var csvColumns = []csvColumn{
+	{"id", "ID", func(rec AnalyticsRecord) string { return strconv.FormatInt(rec.ID, 10) }},
+	{"timestamp", "Timestamp", func(rec AnalyticsRecord) string { return rec.Timestamp.Format(time.RFC3339) }},
+	{"model", "Model", func(rec AnalyticsRecord) string { return rec.Model }},
+	{"user", "User", func(rec AnalyticsRecord) string { return rec.User }},
+	{"prompt", "Prompt", func(rec AnalyticsRecord) string { return rec.Prompt }},
+	{"response", "Response", func(rec AnalyticsRecord) string { return rec.ResponsePreview }},
+	{"input_tokens", "InputTokens", func(rec AnalyticsRecord) string { return strconv.Itoa(rec.PromptTokens) }},
+	{"output_tokens", "OutputTokens", func(rec AnalyticsRecord) string { return strconv.Itoa(rec.TokensGenerated) }},
+	{"latency", "Latency", func(rec AnalyticsRecord) string { return formatSeconds(rec.DurationSeconds) }},
+	{"status", "Status", func(rec AnalyticsRecord) string { return rec.Status }},
+	{"endpoint", "Endpoint", func(rec AnalyticsRecord) string { return rec.Endpoint }},
+	{"category", "Category", func(rec AnalyticsRecord) string { return rec.PromptCategory }},
+	{"tokens_per_second", "TokensPerSecond", func(rec AnalyticsRecord) string { return strconv.FormatFloat(rec.TokensPerSecond, 'f', 2, 64) }},
+	{"load_duration", "LoadDuration", func(rec AnalyticsRecord) string { return formatSeconds(rec.LoadDuration) }},
+	{"total_duration", "TotalDuration", func(rec AnalyticsRecord) string { return formatSeconds(rec.TotalDuration) }},
+	{"queue_time", "QueueTime", func(rec AnalyticsRecord) string { return formatSeconds(rec.QueueTime) }},
+	{"time_to_first_token", "TimeToFirstToken", func(rec AnalyticsRecord) string { return formatSeconds(rec.TimeToFirstToken) }},
+	{"status_code", "StatusCode", func(rec AnalyticsRecord) string { return strconv.Itoa(rec.StatusCode) }},
+	{"error", "Error", func(rec AnalyticsRecord) string { return rec.ErrorMessage }},
+	{"client_ip", "ClientIP", func(rec AnalyticsRecord) string { return rec.ClientIP }},
+	{"user_agent", "UserAgent", func(rec AnalyticsRecord) string { return rec.UserAgent }},
+	{"cost", "Cost", func(rec AnalyticsRecord) string { return strconv.FormatFloat(rec.Cost, 'f', -1, 64) }},
+	{"metadata", "Metadata", func(rec AnalyticsRecord) string {
+		if len(rec.Metadata) == 0 {
+			return ""
+		}
+		data, _ := json.Marshal(rec.Metadata)
+		return string(data)
+	}},
+}
+
+// defaultCSVColumns is how many leading csvColumns are exported without ?columns=
+const defaultCSVColumns = 10
+
+// formatSeconds formats a duration in seconds with millisecond precision
+func formatSeconds(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', 3, 64)
+}
+
+// selectCSVColumns resolves a comma-separated ?columns= list, in the order
+// given. An empty list selects the default columns.
+func selectCSVColumns(param string) ([]csvColumn, error) {
+	if strings.TrimSpace(param) == "" {
+		return csvColumns[:defaultCSVColumns], nil
+	}
+
+	byName := make(map[string]csvColumn, len(csvColumns))
+	for _, col := range csvColumns {
+		byName[col.name] = col
+	}
+
+	var selected []csvColumn
+	for _, name := range strings.Split(param, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		col, ok := byName[name]
+		if !ok {
+			names := make([]string, len(csvColumns))
+			for i, c := range csvColumns {
+				names[i] = c.name
+			}
+			return nil, fmt.Errorf("unknown column %q, expected one of: %s", name, strings.Join(names, ", "))
+		}
+		selected = append(selected, col)
+	}
+	if len(selected) == 0 {
+		return csvColumns[:defaultCSVColumns], nil
+	}
+	return selected, nil
+}
@@ -2,40 +2,106 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
+	"math"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 )
 
 // AnalyticsStats represents useful analytics statistics
 type AnalyticsStats struct {
 	// Basic counts
-	TotalRequests    int     `json:"total_requests"`
-	UniqueIPs        int     `json:"unique_ips"`
-	UniqueModels     int     `json:"unique_models"`
-	
-	// Performance metrics  
-	AvgResponseTime  float64 `json:"avg_response_time_ms"`
-	AvgInputTokens   float64 `json:"avg_input_tokens"`
-	AvgOutputTokens  float64 `json:"avg_output_tokens"`
-	AvgTokensPerSec  float64 `json:"avg_tokens_per_second"`
-	
+	TotalRequests int `json:"total_requests"`
+	UniqueIPs     int `json:"unique_ips"`
+	UniqueModels  int `json:"unique_models"`
+
+	// Performance metrics
+	AvgResponseTime float64 `json:"avg_response_time_ms"`
+	AvgInputTokens  float64 `json:"avg_input_tokens"`
+	AvgOutputTokens float64 `json:"avg_output_tokens"`
+	AvgTokensPerSec float64 `json:"avg_tokens_per_second"`
+	TotalTokens     int     `json:"total_tokens"`
+
 	// Rate metrics
 	RequestsPerMinute float64 `json:"requests_per_minute"`
 	SuccessRate       float64 `json:"success_rate_percent"`
 	ErrorRate         float64 `json:"error_rate_percent"`
-	
+
+	// Distributions
+	LatencyPercentiles      Percentiles `json:"latency_percentiles"`
+	TokensPerSecPercentiles Percentiles `json:"tokens_per_second_percentiles"`
+
 	// Top lists
-	TopIPs       []IPStat    `json:"top_ips"`
-	TopModels    []ModelStat `json:"top_models"`
-	RecentTrend  []TrendPoint `json:"recent_trend"`
-	
+	TopIPs        []IPStat       `json:"top_ips"`
+	TopUsers      []UserStat     `json:"top_users"`
+	TopTags       []TagStat      `json:"top_tags"`
+	TopModels     []ModelStat    `json:"top_models"`
+	TopCategories []CategoryStat `json:"top_categories"`
+	RecentTrend   []TrendPoint   `json:"recent_trend"`
+
 	// Time range info
 	TimeRangeHours int    `json:"time_range_hours"`
 	DataStartTime  string `json:"data_start_time"`
 	DataEndTime    string `json:"data_end_time"`
 }
 
+// Percentiles summarizes a distribution (latency in ms, or tokens per second)
+type Percentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+}
+
+// computePercentiles returns percentiles of values using linear interpolation.
+// values must be sorted in ascending order.
+func computePercentiles(values []float64) Percentiles {
+	if len(values) == 0 {
+		return Percentiles{}
+	}
+	at := func(q float64) float64 {
+		pos := q * float64(len(values)-1)
+		lower := int(math.Floor(pos))
+		upper := int(math.Ceil(pos))
+		if lower == upper {
+			return values[lower]
+		}
+		return values[lower] + (values[upper]-values[lower])*(pos-float64(lower))
+	}
+	return Percentiles{P50: at(0.50), P90: at(0.90), P95: at(0.95), P99: at(0.99)}
+}
+
+// queryPercentiles loads a numeric column for records in [startTime, endTime)
+// and computes its percentiles
+func (p *Proxy) queryPercentiles(expr, condition string, startTime, endTime time.Time) (Percentiles, error) {
+	query := "SELECT " + expr + " FROM interactions WHERE timestamp >= ? AND timestamp < ?"
+	if condition != "" {
+		query += " AND " + condition
+	}
+	query += " ORDER BY 1"
+
+	rows, err := p.analytics.query(query, startTime, endTime)
+	if err != nil {
+		return Percentiles{}, err
+	}
+	defer rows.Close()
+
+	var values []float64
+	for rows.Next() {
+		var v float64
+		if err := rows.Scan(&v); err == nil {
+			values = append(values, v)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return Percentiles{}, err
+	}
+	return computePercentiles(values), nil
+}
+
 type IPStat struct {
 	IP           string  `json:"ip"`
 	RequestCount int     `json:"request_count"`
@@ -43,6 +109,22 @@ type IPStat struct {
 	TotalTokens  int     `json:"total_tokens"`
 }
 
+type UserStat struct {
+	User         string  `json:"user"`
+	RequestCount int     `json:"request_count"`
+	AvgLatency   float64 `json:"avg_latency_ms"`
+	TotalTokens  int     `json:"total_tokens"`
+	TotalCost    float64 `json:"total_cost"`
+}
+
+type TagStat struct {
+	Tag          string  `json:"tag"`
+	RequestCount int     `json:"request_count"`
+	AvgLatency   float64 `json:"avg_latency_ms"`
+	TotalTokens  int     `json:"total_tokens"`
+	TotalCost    float64 `json:"total_cost"`
+}
+
 type ModelStat struct {
 	Model        string  `json:"model"`
 	RequestCount int     `json:"request_count"`
@@ -50,15 +132,225 @@ type ModelStat struct {
 	TotalTokens  int     `json:"total_tokens"`
 }
 
+type CategoryStat struct {
+	Category     string  `json:"category"`
+	RequestCount int     `json:"request_count"`
+	AvgLatency   float64 `json:"avg_latency_ms"`
+	TotalTokens  int     `json:"total_tokens"`
+}
+
 type TrendPoint struct {
-	Timestamp    int64 `json:"timestamp"`
-	RequestCount int   `json:"request_count"`
+	Timestamp    int64   `json:"timestamp"`
+	RequestCount int     `json:"request_count"`
 	AvgLatency   float64 `json:"avg_latency"`
 }
 
-// Enhanced analytics stats endpoint
-func (p *Proxy) handleAnalyticsStatsEnhanced(w http.ResponseWriter, r *http.Request) {
-	if p.analytics.backend != "sqlite" || p.analytics.db == nil {
+type TimeseriesPoint struct {
+	Timestamp    int64   `json:"timestamp"`
+	RequestCount int     `json:"request_count"`
+	AvgLatency   float64 `json:"avg_latency"`
+	TotalTokens  int     `json:"total_tokens"`
+	ErrorCount   int     `json:"error_count"`
+}
+
+// maxTimeseriesBuckets caps the number of points returned to keep responses bounded
+const maxTimeseriesBuckets = 10000
+
+// parseBucketSize parses bucket sizes like "1m", "5m", "1h" or "1d"
+func parseBucketSize(bucket string) (time.Duration, error) {
+	if bucket == "" {
+		return time.Hour, nil
+	}
+	if strings.HasSuffix(bucket, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(bucket, "d"))
+		if err != nil || days <= 0 {
+			return 0, fmt.Errorf("invalid bucket size: %s", bucket)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(bucket)
+	if err != nil || d < time.Second {
+		return 0, fmt.Errorf("invalid bucket size: %s", bucket)
+	}
+	return d, nil
+}
+
+// parseTimeRange reads start_time/end_time (Unix seconds) from the query,
+// defaulting to the window ending now
+func parseTimeRange(query url.Values, window time.Duration) (time.Time, time.Time, error) {
+	endTime := time.Now()
+	if e := query.Get("end_time"); e != "" {
+		if ts, err := strconv.ParseInt(e, 10, 64); err == nil {
+			endTime = time.Unix(ts, 0)
+		}
+	}
+	startTime := endTime.Add(-window)
+	if s := query.Get("start_time"); s != "" {
+		if ts, err := strconv.ParseInt(s, 10, 64); err == nil {
+			startTime = time.Unix(ts, 0)
+		}
+	}
+	if !startTime.Before(endTime) {
+		return startTime, endTime, fmt.Errorf("start_time must be before end_time")
+	}
+	return startTime, endTime, nil
+}
+
+// Timeseries endpoint with configurable bucket size
+func (p *Proxy) handleAnalyticsTimeseries(w http.ResponseWriter, r *http.Request) {
+	if !p.analytics.Available() {
+		http.Error(w, "Analytics not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	query := r.URL.Query()
+
+	bucket, err := parseBucketSize(query.Get("bucket"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	bucketSeconds := int64(bucket / time.Second)
+
+	// Get time range (default last 24 hours)
+	startTime, endTime, err := parseTimeRange(query, 24*time.Hour)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Align the range to bucket boundaries
+	firstBucket := startTime.Unix() / bucketSeconds * bucketSeconds
+	lastBucket := endTime.Unix() / bucketSeconds * bucketSeconds
+	if (lastBucket-firstBucket)/bucketSeconds+1 > maxTimeseriesBuckets {
+		http.Error(w, fmt.Sprintf("Too many buckets (max %d), use a larger bucket size", maxTimeseriesBuckets), http.StatusBadRequest)
+		return
+	}
+
+	// Group by bucket using SQL aggregation
+	timeseriesQuery := `
+		SELECT
+			(` + p.analytics.getStore().EpochExpr("timestamp") + ` / ?) * ? as bucket_timestamp,
+			COUNT(*) as request_count,
+			COALESCE(AVG(duration_seconds * 1000), 0) as avg_latency,
+			COALESCE(SUM(tokens_generated), 0) as total_tokens,
+			SUM(CASE WHEN status_code >= 400 OR status = 'error' THEN 1 ELSE 0 END) as error_count
+		FROM interactions
+		WHERE timestamp >= ? AND timestamp < ?
+		GROUP BY bucket_timestamp
+		ORDER BY bucket_timestamp ASC
+	`
+
+	rows, err := p.analytics.query(timeseriesQuery, bucketSeconds, bucketSeconds, startTime, endTime)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	buckets := make(map[int64]TimeseriesPoint)
+	for rows.Next() {
+		var point TimeseriesPoint
+		if err := rows.Scan(&point.Timestamp, &point.RequestCount, &point.AvgLatency, &point.TotalTokens, &point.ErrorCount); err == nil {
+			buckets[point.Timestamp] = point
+		}
+	}
+
+	// Fill empty buckets with zeros so charts don't have gaps
+	points := make([]TimeseriesPoint, 0, (lastBucket-firstBucket)/bucketSeconds+1)
+	for ts := firstBucket; ts <= lastBucket; ts += bucketSeconds {
+		if point, ok := buckets[ts]; ok {
+			points = append(points, point)
+		} else {
+			points = append(points, TimeseriesPoint{Timestamp: ts})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(points)
+}
+
+type ModelCost struct {
+	Model        string  `json:"model"`
+	RequestCount int     `json:"request_count"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	Cost         float64 `json:"cost"`
+}
+
+type CostSummary struct {
+	StartTime     int64       `json:"start_time"`
+	EndTime       int64       `json:"end_time"`
+	TotalRequests int         `json:"total_requests"`
+	TotalCost     float64     `json:"total_cost"`
+	Models        []ModelCost `json:"models"`
+}
+
+// Cost aggregation endpoint
+func (p *Proxy) handleAnalyticsCosts(w http.ResponseWriter, r *http.Request) {
+	if !p.analytics.Available() {
+		http.Error(w, "Analytics not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Get time range (default last 24 hours)
+	startTime, endTime, err := parseTimeRange(r.URL.Query(), 24*time.Hour)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	costsQuery := `
+		SELECT
+			model,
+			COUNT(*) as request_count,
+			COALESCE(SUM(prompt_tokens), 0) as input_tokens,
+			COALESCE(SUM(tokens_generated), 0) as output_tokens,
+			COALESCE(SUM(cost), 0) as total_cost
+		FROM interactions
+		WHERE timestamp >= ? AND timestamp < ?
+		GROUP BY model
+		ORDER BY total_cost DESC
+	`
+
+	rows, err := p.analytics.query(costsQuery, startTime, endTime)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	summary := CostSummary{
+		StartTime: startTime.Unix(),
+		EndTime:   endTime.Unix(),
+		Models:    []ModelCost{},
+	}
+	for rows.Next() {
+		var mc ModelCost
+		if err := rows.Scan(&mc.Model, &mc.RequestCount, &mc.InputTokens, &mc.OutputTokens, &mc.Cost); err == nil {
+			summary.Models = append(summary.Models, mc)
+			summary.TotalRequests += mc.RequestCount
+			summary.TotalCost += mc.Cost
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+type ModelSummary struct {
+	Model           string  `json:"model"`
+	RequestCount    int     `json:"request_count"`
+	AvgLatency      float64 `json:"avg_latency_ms"`
+	AvgTokensPerSec float64 `json:"avg_tokens_per_sec"`
+	TotalTokens     int     `json:"total_tokens"`
+	ErrorRate       float64 `json:"error_rate"`
+	LastUsed        int64   `json:"last_used"`
+}
+
+// Per-model aggregate stats endpoint
+func (p *Proxy) handleAnalyticsModelsStats(w http.ResponseWriter, r *http.Request) {
+	if !p.analytics.Available() {
 		http.Error(w, "Analytics not available", http.StatusServiceUnavailable)
 		return
 	}
@@ -73,30 +365,129 @@ func (p *Proxy) handleAnalyticsStatsEnhanced(w http.ResponseWriter, r *http.Requ
 
 	startTime := time.Now().Add(-time.Duration(hours) * time.Hour)
 
-	stats := &AnalyticsStats{
-		TimeRangeHours: hours,
-		DataStartTime:  startTime.Format(time.RFC3339),
-		DataEndTime:    time.Now().Format(time.RFC3339),
+	modelsQuery := `
+		SELECT
+			model,
+			COUNT(*) as request_count,
+			COALESCE(AVG(duration_seconds * 1000), 0) as avg_latency_ms,
+			COALESCE(AVG(CASE WHEN tokens_per_second > 0 THEN tokens_per_second END), 0) as avg_tokens_per_sec,
+			COALESCE(SUM(COALESCE(prompt_tokens, 0) + COALESCE(tokens_generated, 0)), 0) as total_tokens,
+			COALESCE(SUM(CASE WHEN status_code >= 400 OR status = 'error' THEN 1 ELSE 0 END) * 100.0 / NULLIF(COUNT(*), 0), 0) as error_rate,
+			` + p.analytics.getStore().EpochExpr("MAX(timestamp)") + ` as last_used
+		FROM interactions
+		WHERE timestamp >= ?
+		GROUP BY model
+		ORDER BY request_count DESC
+	`
+
+	rows, err := p.analytics.query(modelsQuery, startTime)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
+	defer rows.Close()
 
-	// Use SQL aggregations for better performance (no in-memory processing)
-	// Get basic aggregate statistics
+	models := []ModelSummary{}
+	for rows.Next() {
+		var m ModelSummary
+		if err := rows.Scan(&m.Model, &m.RequestCount, &m.AvgLatency, &m.AvgTokensPerSec,
+			&m.TotalTokens, &m.ErrorRate, &m.LastUsed); err == nil {
+			models = append(models, m)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"time_range_hours": hours,
+		"models":           models,
+	})
+}
+
+type ErrorSummary struct {
+	ErrorMessage string `json:"error_message"`
+	Category     string `json:"category"`
+	Count        int    `json:"count"`
+	LastSeen     int64  `json:"last_seen"`
+}
+
+// Top error messages by frequency
+func (p *Proxy) handleAnalyticsErrors(w http.ResponseWriter, r *http.Request) {
+	if !p.analytics.Available() {
+		http.Error(w, "Analytics not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Get time range (default last 24 hours)
+	hours := 24
+	if h := r.URL.Query().Get("hours"); h != "" {
+		if parsed, err := strconv.Atoi(h); err == nil && parsed > 0 {
+			hours = parsed
+		}
+	}
+	limit := 20
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+
+	startTime := time.Now().Add(-time.Duration(hours) * time.Hour)
+
+	errorsQuery := `
+		SELECT
+			error_message,
+			COUNT(*) as error_count,
+			` + p.analytics.getStore().EpochExpr("MAX(timestamp)") + ` as last_seen
+		FROM interactions
+		WHERE timestamp >= ? AND error_message IS NOT NULL AND error_message != ''
+		GROUP BY error_message
+		ORDER BY error_count DESC
+		LIMIT ?
+	`
+
+	rows, err := p.analytics.query(errorsQuery, startTime, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	summaries := []ErrorSummary{}
+	for rows.Next() {
+		var e ErrorSummary
+		if err := rows.Scan(&e.ErrorMessage, &e.Count, &e.LastSeen); err == nil {
+			e.Category = categorizeUpstreamError(e.ErrorMessage)
+			summaries = append(summaries, e)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"time_range_hours": hours,
+		"errors":           summaries,
+	})
+}
+
+// queryBasicStats fills the aggregate counts, averages and rates of stats
+// for records in [startTime, endTime)
+func (p *Proxy) queryBasicStats(stats *AnalyticsStats, startTime, endTime time.Time) error {
 	basicStatsQuery := `
 		SELECT
 			COUNT(*) as total_requests,
 			COUNT(DISTINCT client_ip) as unique_ips,
 			COUNT(DISTINCT model) as unique_models,
-			AVG(duration_seconds * 1000) as avg_response_time_ms,
-			AVG(prompt_tokens) as avg_input_tokens,
-			AVG(tokens_generated) as avg_output_tokens,
-			AVG(CASE WHEN duration_seconds > 0 AND tokens_generated > 0
-			    THEN tokens_generated / duration_seconds ELSE 0 END) as avg_tokens_per_sec,
-			SUM(CASE WHEN status_code < 400 THEN 1 ELSE 0 END) * 100.0 / COUNT(*) as success_rate
+			COALESCE(AVG(duration_seconds * 1000), 0) as avg_response_time_ms,
+			COALESCE(AVG(prompt_tokens), 0) as avg_input_tokens,
+			COALESCE(AVG(tokens_generated), 0) as avg_output_tokens,
+			COALESCE(AVG(CASE WHEN duration_seconds > 0 AND tokens_generated > 0
+			    THEN tokens_generated / duration_seconds ELSE 0 END), 0) as avg_tokens_per_sec,
+			COALESCE(SUM(tokens_generated), 0) as total_tokens,
+			COALESCE(SUM(CASE WHEN status_code < 400 THEN 1 ELSE 0 END) * 100.0 / NULLIF(COUNT(*), 0), 0) as success_rate
 		FROM interactions
-		WHERE timestamp >= ?
+		WHERE timestamp >= ? AND timestamp < ?
 	`
 
-	err := p.analytics.db.QueryRow(basicStatsQuery, startTime).Scan(
+	err := p.analytics.queryRow(basicStatsQuery, startTime, endTime).Scan(
 		&stats.TotalRequests,
 		&stats.UniqueIPs,
 		&stats.UniqueModels,
@@ -104,18 +495,72 @@ func (p *Proxy) handleAnalyticsStatsEnhanced(w http.ResponseWriter, r *http.Requ
 		&stats.AvgInputTokens,
 		&stats.AvgOutputTokens,
 		&stats.AvgTokensPerSec,
+		&stats.TotalTokens,
 		&stats.SuccessRate,
 	)
 	if err != nil {
+		return err
+	}
+	stats.ErrorRate = 100 - stats.SuccessRate
+	if stats.TotalRequests == 0 {
+		stats.ErrorRate = 0
+	}
+	return nil
+}
+
+// Enhanced analytics stats endpoint
+func (p *Proxy) handleAnalyticsStatsEnhanced(w http.ResponseWriter, r *http.Request) {
+	if !p.analytics.Available() {
+		http.Error(w, "Analytics not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Get time range: a range preset, or hours (default last 24 hours)
+	hours := 24
+	if h := r.URL.Query().Get("hours"); h != "" {
+		if parsed, err := strconv.Atoi(h); err == nil && parsed > 0 {
+			hours = parsed
+		}
+	}
+
+	endTime := time.Now()
+	startTime := endTime.Add(-time.Duration(hours) * time.Hour)
+	if preset := r.URL.Query().Get("range"); preset != "" {
+		var err error
+		if startTime, endTime, err = parseRangePreset(preset, endTime); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		hours = int(math.Ceil(endTime.Sub(startTime).Hours()))
+	}
+
+	stats := &AnalyticsStats{
+		TimeRangeHours: hours,
+		DataStartTime:  startTime.Format(time.RFC3339),
+		DataEndTime:    endTime.Format(time.RFC3339),
+	}
+
+	// Use SQL aggregations for better performance (no in-memory processing)
+	if err := p.queryBasicStats(stats, startTime, endTime); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	stats.ErrorRate = 100 - stats.SuccessRate
+	// Tail latency and generation speed, which averages hide
+	var err error
+	stats.LatencyPercentiles, err = p.queryPercentiles("duration_seconds * 1000", "duration_seconds IS NOT NULL", startTime, endTime)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	stats.TokensPerSecPercentiles, err = p.queryPercentiles("tokens_per_second", "tokens_per_second > 0", startTime, endTime)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	// Calculate requests per minute
-	if hours > 0 {
-		totalMinutes := float64(hours * 60)
+	if totalMinutes := endTime.Sub(startTime).Minutes(); totalMinutes > 0 {
 		stats.RequestsPerMinute = float64(stats.TotalRequests) / totalMinutes
 	}
 
@@ -127,13 +572,13 @@ func (p *Proxy) handleAnalyticsStatsEnhanced(w http.ResponseWriter, r *http.Requ
 			AVG(duration_seconds * 1000) as avg_latency_ms,
 			SUM(tokens_generated) as total_tokens
 		FROM interactions
-		WHERE timestamp >= ?
+		WHERE timestamp >= ? AND timestamp < ?
 		GROUP BY client_ip
 		ORDER BY request_count DESC
 		LIMIT 10
 	`
 
-	rows, err := p.analytics.db.Query(topIPsQuery, startTime)
+	rows, err := p.analytics.query(topIPsQuery, startTime, endTime)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -149,6 +594,69 @@ func (p *Proxy) handleAnalyticsStatsEnhanced(w http.ResponseWriter, r *http.Requ
 	}
 	stats.TopIPs = ipStats
 
+	// Get top users (from USER_HEADER) using SQL aggregation
+	topUsersQuery := `
+		SELECT
+			COALESCE("user", 'anonymous'),
+			COUNT(*) as request_count,
+			AVG(duration_seconds * 1000) as avg_latency_ms,
+			SUM(tokens_generated) as total_tokens,
+			COALESCE(SUM(cost), 0) as total_cost
+		FROM interactions
+		WHERE timestamp >= ? AND timestamp < ?
+		GROUP BY "user"
+		ORDER BY request_count DESC
+		LIMIT 10
+	`
+
+	userRows, err := p.analytics.query(topUsersQuery, startTime, endTime)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer userRows.Close()
+
+	var userStats []UserStat
+	for userRows.Next() {
+		var stat UserStat
+		if err := userRows.Scan(&stat.User, &stat.RequestCount, &stat.AvgLatency, &stat.TotalTokens, &stat.TotalCost); err == nil {
+			userStats = append(userStats, stat)
+		}
+	}
+	stats.TopUsers = userStats
+
+	// Get top tags (from TAG_HEADER); untagged requests are left out
+	tagExpr := p.analytics.getStore().JSONFieldExpr("metadata", "tag")
+	topTagsQuery := `
+		SELECT
+			` + tagExpr + ` as tag,
+			COUNT(*) as request_count,
+			AVG(duration_seconds * 1000) as avg_latency_ms,
+			SUM(tokens_generated) as total_tokens,
+			COALESCE(SUM(cost), 0) as total_cost
+		FROM interactions
+		WHERE timestamp >= ? AND timestamp < ? AND ` + tagExpr + ` IS NOT NULL
+		GROUP BY ` + tagExpr + `
+		ORDER BY request_count DESC
+		LIMIT 10
+	`
+
+	tagRows, err := p.analytics.query(topTagsQuery, startTime, endTime)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tagRows.Close()
+
+	var tagStats []TagStat
+	for tagRows.Next() {
+		var stat TagStat
+		if err := tagRows.Scan(&stat.Tag, &stat.RequestCount, &stat.AvgLatency, &stat.TotalTokens, &stat.TotalCost); err == nil {
+			tagStats = append(tagStats, stat)
+		}
+	}
+	stats.TopTags = tagStats
+
 	// Get top models using SQL aggregation
 	topModelsQuery := `
 		SELECT
@@ -157,13 +665,13 @@ func (p *Proxy) handleAnalyticsStatsEnhanced(w http.ResponseWriter, r *http.Requ
 			AVG(duration_seconds * 1000) as avg_latency_ms,
 			SUM(tokens_generated) as total_tokens
 		FROM interactions
-		WHERE timestamp >= ?
+		WHERE timestamp >= ? AND timestamp < ?
 		GROUP BY model
 		ORDER BY request_count DESC
 		LIMIT 10
 	`
 
-	rows, err = p.analytics.db.Query(topModelsQuery, startTime)
+	rows, err = p.analytics.query(topModelsQuery, startTime, endTime)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -179,19 +687,49 @@ func (p *Proxy) handleAnalyticsStatsEnhanced(w http.ResponseWriter, r *http.Requ
 	}
 	stats.TopModels = modelStats
 
+	// Get top prompt categories using SQL aggregation
+	topCategoriesQuery := `
+		SELECT
+			COALESCE(prompt_category, ''),
+			COUNT(*) as request_count,
+			AVG(duration_seconds * 1000) as avg_latency_ms,
+			COALESCE(SUM(tokens_generated), 0) as total_tokens
+		FROM interactions
+		WHERE timestamp >= ? AND timestamp < ?
+		GROUP BY prompt_category
+		ORDER BY request_count DESC
+		LIMIT 10
+	`
+
+	categoryRows, err := p.analytics.query(topCategoriesQuery, startTime, endTime)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer categoryRows.Close()
+
+	var categoryStats []CategoryStat
+	for categoryRows.Next() {
+		var stat CategoryStat
+		if err := categoryRows.Scan(&stat.Category, &stat.RequestCount, &stat.AvgLatency, &stat.TotalTokens); err == nil {
+			categoryStats = append(categoryStats, stat)
+		}
+	}
+	stats.TopCategories = categoryStats
+
 	// Get hourly trend using SQL aggregation
 	trendQuery := `
 		SELECT
-			strftime('%s', datetime(timestamp, 'unixepoch', 'start of hour')) as hour_timestamp,
+			(` + p.analytics.getStore().EpochExpr("timestamp") + ` / 3600) * 3600 as hour_timestamp,
 			COUNT(*) as request_count,
 			AVG(duration_seconds * 1000) as avg_latency
 		FROM interactions
-		WHERE timestamp >= ?
+		WHERE timestamp >= ? AND timestamp < ?
 		GROUP BY hour_timestamp
 		ORDER BY hour_timestamp ASC
 	`
 
-	rows, err = p.analytics.db.Query(trendQuery, startTime)
+	rows, err = p.analytics.query(trendQuery, startTime, endTime)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -209,4 +747,98 @@ func (p *Proxy) handleAnalyticsStatsEnhanced(w http.ResponseWriter, r *http.Requ
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(stats)
-}
\ No newline at end of file
+}
+
+// CompareWindow is the compact summary of one time window in /analytics/compare
+type CompareWindow struct {
+	Start           string  `json:"start"`
+	End             string  `json:"end"`
+	TotalRequests   int     `json:"total_requests"`
+	AvgResponseTime float64 `json:"avg_response_time_ms"`
+	ErrorRate       float64 `json:"error_rate_percent"`
+	TotalTokens     int     `json:"total_tokens"`
+	AvgTokensPerSec float64 `json:"avg_tokens_per_second"`
+	UniqueModels    int     `json:"unique_models"`
+	UniqueIPs       int     `json:"unique_ips"`
+}
+
+// percentChange returns the change from previous to current in percent, or
+// nil when there is no previous value to compare against
+func percentChange(previous, current float64) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	change := math.Round((current-previous)/previous*1000) / 10
+	return &change
+}
+
+// handleAnalyticsCompare compares two time windows, e.g. this week against
+// last week. Windows are given as Unix seconds in current_start/current_end
+// and previous_start/previous_end; by default the current window is the last
+// `days` days (7) and the previous window is the same length just before it.
+func (p *Proxy) handleAnalyticsCompare(w http.ResponseWriter, r *http.Request) {
+	if !p.analytics.Available() {
+		http.Error(w, "Analytics not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	query := r.URL.Query()
+	days := 7
+	if d := query.Get("days"); d != "" {
+		if parsed, err := strconv.Atoi(d); err == nil && parsed > 0 {
+			days = parsed
+		}
+	}
+
+	current := url.Values{"start_time": {query.Get("current_start")}, "end_time": {query.Get("current_end")}}
+	currentStart, currentEnd, err := parseTimeRange(current, time.Duration(days)*24*time.Hour)
+	if err != nil {
+		http.Error(w, "current window: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// The previous window defaults to the current window's length, ending where it starts
+	previous := url.Values{"start_time": {query.Get("previous_start")}, "end_time": {query.Get("previous_end")}}
+	if previous.Get("end_time") == "" {
+		previous.Set("end_time", strconv.FormatInt(currentStart.Unix(), 10))
+	}
+	previousStart, previousEnd, err := parseTimeRange(previous, currentEnd.Sub(currentStart))
+	if err != nil {
+		http.Error(w, "previous window: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	windows := make([]CompareWindow, 2)
+	for i, bounds := range [][2]time.Time{{currentStart, currentEnd}, {previousStart, previousEnd}} {
+		var stats AnalyticsStats
+		if err := p.queryBasicStats(&stats, bounds[0], bounds[1]); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		windows[i] = CompareWindow{
+			Start:           bounds[0].Format(time.RFC3339),
+			End:             bounds[1].Format(time.RFC3339),
+			TotalRequests:   stats.TotalRequests,
+			AvgResponseTime: stats.AvgResponseTime,
+			ErrorRate:       stats.ErrorRate,
+			TotalTokens:     stats.TotalTokens,
+			AvgTokensPerSec: stats.AvgTokensPerSec,
+			UniqueModels:    stats.UniqueModels,
+			UniqueIPs:       stats.UniqueIPs,
+		}
+	}
+	cur, prev := windows[0], windows[1]
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"current":  cur,
+		"previous": prev,
+		// Percent change from previous to current (null when previous is zero)
+		"delta_percent": map[string]*float64{
+			"total_requests":        percentChange(float64(prev.TotalRequests), float64(cur.TotalRequests)),
+			"avg_response_time_ms":  percentChange(prev.AvgResponseTime, cur.AvgResponseTime),
+			"error_rate_percent":    percentChange(prev.ErrorRate, cur.ErrorRate),
+			"total_tokens":          percentChange(float64(prev.TotalTokens), float64(cur.TotalTokens)),
+			"avg_tokens_per_second": percentChange(prev.AvgTokensPerSec, cur.AvgTokensPerSec),
+		},
+	})
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	maxIngestBody    = 32 << 20 // Largest ingestion request body
+	maxIngestRecords = 10000    // Records accepted per request
+)
+
+// IngestError describes why one record of an ingestion batch was rejected
+type IngestError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// validateIngestRecord checks required fields and fills in the defaults the
+// proxy would have set for a record it recorded itself
+func (p *Proxy) validateIngestRecord(rec *AnalyticsRecord) error {
+	if strings.TrimSpace(rec.Model) == "" {
+		return errors.New("model is required")
+	}
+	if strings.TrimSpace(rec.Endpoint) == "" {
+		return errors.New("endpoint is required")
+	}
+	if rec.Timestamp.IsZero() {
+		return errors.New("timestamp is required")
+	}
+	if rec.Timestamp.After(time.Now().Add(5 * time.Minute)) {
+		return errors.New("timestamp is in the future")
+	}
+	if rec.DurationSeconds < 0 || rec.TokensGenerated < 0 || rec.PromptTokens < 0 || rec.Cost < 0 {
+		return errors.New("latency, token counts and cost must not be negative")
+	}
+
+	rec.ID = 0 // Assigned by the database
+	if rec.StatusCode == 0 {
+		rec.StatusCode = http.StatusOK
+	}
+	if rec.Status == "" {
+		rec.Status = "success"
+		if rec.StatusCode >= 400 || rec.ErrorMessage != "" {
+			rec.Status = "error"
+		}
+	}
+	if rec.PromptCategory == "" {
+		rec.PromptCategory = p.metrics.categorizer.Categorize(rec.Prompt)
+	}
+	if rec.User == "" {
+		rec.User = anonymousUser
+	}
+	if rec.Metadata == nil {
+		rec.Metadata = make(map[string]interface{})
+	}
+	if _, ok := rec.Metadata["source"]; !ok {
+		rec.Metadata["source"] = "ingest"
+	}
+	return nil
+}
+
+// handleAnalyticsIngest accepts a JSON array of analytics records produced
+// outside the proxy (e.g. offline batch jobs) and stores them alongside
+// proxied traffic. Invalid entries are reported by index; valid ones are kept.
+func (p *Proxy) handleAnalyticsIngest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !p.analytics.Available() {
+		http.Error(w, "Analytics not available", http.StatusServiceUnavailable)
+		return
+	}
+	if p.analytics.Paused() {
+		http.Error(w, "Analytics writes are paused", http.StatusServiceUnavailable)
+		return
+	}
+
+	var items []json.RawMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxIngestBody)).Decode(&items); err != nil {
+		http.Error(w, "Invalid JSON body: expected an array of analytics records", http.StatusBadRequest)
+		return
+	}
+	if len(items) > maxIngestRecords {
+		http.Error(w, fmt.Sprintf("Too many records (max %d per request)", maxIngestRecords), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	accepted := 0
+	rejected := []IngestError{}
+	for i, item := range items {
+		var rec AnalyticsRecord
+		err := json.Unmarshal(item, &rec)
+		if err == nil {
+			err = p.validateIngestRecord(&rec)
+		}
+		if err != nil {
+			rejected = append(rejected, IngestError{Index: i, Error: err.Error()})
+			continue
+		}
+		if err := p.analytics.RecordWait(r.Context(), rec); errors.Is(err, errAnalyticsPaused) {
+			http.Error(w, fmt.Sprintf("Analytics writes were paused after %d records", accepted), http.StatusServiceUnavailable)
+			return
+		} else if err != nil {
+			// Client went away; the records queued so far are kept
+			return
+		}
+		accepted++
+	}
+
+	status := http.StatusOK
+	if accepted == 0 && len(rejected) > 0 {
+		status = http.StatusBadRequest
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"accepted": accepted,
+		"rejected": len(rejected),
+		"errors":   rejected,
+	})
+}
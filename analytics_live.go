@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	liveSubscriberBuffer = 100              // Records buffered per live subscriber before dropping
+	liveHeartbeat        = 15 * time.Second // Keeps idle SSE connections open through proxies
+)
+
+// recordBroadcaster fans out analytics records to live subscribers.
+// Slow subscribers miss records instead of blocking the request path.
+type recordBroadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan AnalyticsRecord]struct{}
+	closed      bool
+}
+
+// subscribe registers a new subscriber and returns its channel
+func (b *recordBroadcaster) subscribe() chan AnalyticsRecord {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan AnalyticsRecord, liveSubscriberBuffer)
+	if b.closed {
+		close(ch)
+		return ch
+	}
+	if b.subscribers == nil {
+		b.subscribers = make(map[chan AnalyticsRecord]struct{})
+	}
+	b.subscribers[ch] = struct{}{}
+	return ch
+}
+
+// unsubscribe removes a subscriber and closes its channel
+func (b *recordBroadcaster) unsubscribe(ch chan AnalyticsRecord) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[ch]; ok {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// publish sends a record to every subscriber without blocking
+func (b *recordBroadcaster) publish(record AnalyticsRecord) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- record:
+		default:
+			// Subscriber is not keeping up, drop the record for it
+		}
+	}
+}
+
+// closeAll disconnects all subscribers, used on shutdown
+func (b *recordBroadcaster) closeAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// handleAnalyticsLive streams each new analytics record as a Server-Sent Event
+func (p *Proxy) handleAnalyticsLive(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// The server's WriteTimeout would otherwise end the feed
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Warning: Could not clear write deadline for live feed: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	records := p.analytics.live.subscribe()
+	defer p.analytics.live.unsubscribe(records)
+
+	heartbeat := time.NewTicker(liveHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case record, ok := <-records:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(record)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+const (
+	parquetContentType = "application/vnd.apache.parquet"
+	parquetRowGroup    = 10000 // Rows buffered per row group before writing it out
+)
+
+// parquetRecord is the typed Parquet schema for analytics exports.
+// Unlike the CSV export it carries every column of the interactions table.
+type parquetRecord struct {
+	ID               int64     `parquet:"id"`
+	Timestamp        time.Time `parquet:"timestamp"`
+	Model            string    `parquet:"model,dict"`
+	Endpoint         string    `parquet:"endpoint,dict"`
+	Prompt           string    `parquet:"prompt"`
+	PromptCategory   string    `parquet:"prompt_category,dict"`
+	ResponsePreview  string    `parquet:"response_preview"`
+	DurationSeconds  float64   `parquet:"duration_seconds"`
+	TokensGenerated  int64     `parquet:"tokens_generated"`
+	TokensPerSecond  float64   `parquet:"tokens_per_second"`
+	PromptTokens     int64     `parquet:"prompt_tokens"`
+	LoadDuration     float64   `parquet:"load_duration"`
+	TotalDuration    float64   `parquet:"total_duration"`
+	StatusCode       int32     `parquet:"status_code"`
+	ErrorMessage     string    `parquet:"error_message"`
+	UserAgent        string    `parquet:"user_agent,dict"`
+	ClientIP         string    `parquet:"client_ip"`
+	User             string    `parquet:"user,dict"`
+	Cost             float64   `parquet:"cost"`
+	Status           string    `parquet:"status,dict"`
+	QueueTime        float64   `parquet:"queue_time"`
+	TimeToFirstToken float64   `parquet:"time_to_first_token"`
+	Metadata         string    `parquet:"metadata"` // JSON object
+}
+
+// newParquetRecord converts an analytics record to its Parquet row
+func newParquetRecord(rec AnalyticsRecord) parquetRecord {
+	metadata := "{}"
+	if len(rec.Metadata) > 0 {
+		if data, err := json.Marshal(rec.Metadata); err == nil {
+			metadata = string(data)
+		}
+	}
+
+	return parquetRecord{
+		ID:               rec.ID,
+		Timestamp:        rec.Timestamp,
+		Model:            rec.Model,
+		Endpoint:         rec.Endpoint,
+		Prompt:           rec.Prompt,
+		PromptCategory:   rec.PromptCategory,
+		ResponsePreview:  rec.ResponsePreview,
+		DurationSeconds:  rec.DurationSeconds,
+		TokensGenerated:  int64(rec.TokensGenerated),
+		TokensPerSecond:  rec.TokensPerSecond,
+		PromptTokens:     int64(rec.PromptTokens),
+		LoadDuration:     rec.LoadDuration,
+		TotalDuration:    rec.TotalDuration,
+		StatusCode:       int32(rec.StatusCode),
+		ErrorMessage:     rec.ErrorMessage,
+		UserAgent:        rec.UserAgent,
+		ClientIP:         rec.ClientIP,
+		User:             rec.User,
+		Cost:             rec.Cost,
+		Status:           rec.Status,
+		QueueTime:        rec.QueueTime,
+		TimeToFirstToken: rec.TimeToFirstToken,
+		Metadata:         metadata,
+	}
+}
+
+// streamParquetExport writes matching records as a zstd-compressed Parquet file,
+// reading rows from the database in pages and emitting a row group every
+// parquetRowGroup rows so large exports don't sit in memory.
+func (p *Proxy) streamParquetExport(w http.ResponseWriter, r *http.Request) {
+	if !p.analytics.Available() {
+		http.Error(w, "Analytics not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	params := r.URL.Query()
+	limit, offset := searchPaging(params)
+	if params.Get("limit") == "" {
+		limit = 0
+	}
+
+	w.Header().Set("Content-Disposition", "attachment; filename=analytics_export.parquet")
+	w.Header().Set("Content-Type", parquetContentType)
+
+	flusher, _ := w.(http.Flusher)
+	writer := parquet.NewGenericWriter[parquetRecord](w, parquet.Compression(&parquet.Zstd))
+	batch := make([]parquetRecord, 0, exportFlushEvery)
+	rowCount := 0
+
+	writeBatch := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		_, err := writer.Write(batch)
+		batch = batch[:0]
+		return err
+	}
+
+	err := p.analytics.SearchEach(params, limit, offset, func(rec AnalyticsRecord) error {
+		batch = append(batch, newParquetRecord(rec))
+		rowCount++
+
+		if len(batch) == cap(batch) {
+			if err := writeBatch(); err != nil {
+				return err
+			}
+		}
+		if rowCount%parquetRowGroup == 0 {
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		err = writeBatch()
+	}
+	if err != nil {
+		// Headers are already sent, so the best we can do is log and stop
+		log.Printf("Parquet export stopped after %d records: %v", rowCount, err)
+		return
+	}
+
+	// Close writes the file footer; without it the file is unreadable
+	if err := writer.Close(); err != nil {
+		log.Printf("Failed to finish Parquet export: %v", err)
+		return
+	}
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// errAnalyticsPaused is returned for writes attempted while analytics is paused
+var errAnalyticsPaused = errors.New("analytics writes are paused")
+
+// Pause stops analytics writes for maintenance such as copying or vacuuming
+// the SQLite file. Records arriving while paused are dropped and counted;
+// Prometheus metrics are unaffected. Returns once no insert is in progress,
+// or false if writes were already paused.
+func (aw *AnalyticsWriter) Pause() bool {
+	if !aw.paused.CompareAndSwap(false, true) {
+		return false
+	}
+	aw.pausedSince.Store(time.Now().Unix())
+	aw.pausedDropped.Store(0)
+
+	// The writer checks the flag while holding writeMu, so once we hold it
+	// the insert that was running (if any) has finished
+	aw.writeMu.Lock()
+	aw.writeMu.Unlock()
+	return true
+}
+
+// Resume restarts analytics writes. Returns how many records were dropped
+// while paused, or false if writes weren't paused.
+func (aw *AnalyticsWriter) Resume() (int64, bool) {
+	if !aw.paused.CompareAndSwap(true, false) {
+		return 0, false
+	}
+	return aw.pausedDropped.Load(), true
+}
+
+// Paused reports whether analytics writes are paused
+func (aw *AnalyticsWriter) Paused() bool {
+	return aw.paused.Load()
+}
+
+// pauseState describes the pause for /analytics/stats and the admin endpoints
+func (aw *AnalyticsWriter) pauseState() map[string]interface{} {
+	state := map[string]interface{}{"paused": aw.Paused()}
+	if aw.Paused() {
+		state["paused_since"] = aw.pausedSince.Load()
+		state["dropped_records"] = aw.pausedDropped.Load()
+	}
+	return state
+}
+
+// handleAdminAnalyticsPause stops analytics writes until /admin/analytics/resume
+func (p *Proxy) handleAdminAnalyticsPause(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if p.analytics.Pause() {
+		log.Printf("Analytics writes paused (requested by %s)", r.RemoteAddr)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p.analytics.pauseState())
+}
+
+// handleAdminAnalyticsResume restarts analytics writes after a pause
+func (p *Proxy) handleAdminAnalyticsResume(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp := map[string]interface{}{"paused": false}
+	if dropped, ok := p.analytics.Resume(); ok {
+		log.Printf("Analytics writes resumed (requested by %s), %d records dropped while paused", r.RemoteAddr, dropped)
+		resp["dropped_records"] = dropped
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// postgresStore implements analyticsStore for PostgreSQL
+type postgresStore struct {
+	db *sql.DB
+}
+
+func (s *postgresStore) DB() *sql.DB    { return s.db }
+func (s *postgresStore) LikeOp() string { return "ILIKE" }
+
+func (s *postgresStore) EpochExpr(column string) string {
+	return fmt.Sprintf("CAST(EXTRACT(EPOCH FROM %s) AS BIGINT)", column)
+}
+
+func (s *postgresStore) JSONFieldExpr(column, field string) string {
+	return fmt.Sprintf("(%s::jsonb ->> '%s')", column, field)
+}
+
+func (s *postgresStore) JSONTrueExpr(column, field string) string {
+	return fmt.Sprintf("(%s::jsonb ->> '%s') = 'true'", column, field)
+}
+
+// Rebind converts '?' placeholders to PostgreSQL's $1, $2, ... syntax
+func (s *postgresStore) Rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// initPostgres initializes the PostgreSQL database
+func (aw *AnalyticsWriter) initPostgres(dsn string) error {
+	if dsn == "" {
+		return fmt.Errorf("ANALYTICS_DSN must be set for the postgres backend")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// Postgres handles concurrent writers, so allow a real pool shared by
+	// the writer goroutine and the analytics HTTP handlers
+	db.SetMaxOpenConns(getEnvInt("ANALYTICS_DB_MAX_CONNS", 10))
+	db.SetMaxIdleConns(5)
+	db.SetConnMaxLifetime(30 * time.Minute)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	// Create table
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS interactions (
+		id BIGSERIAL PRIMARY KEY,
+		timestamp TIMESTAMPTZ DEFAULT NOW(),
+		model TEXT,
+		endpoint TEXT,
+		prompt TEXT,
+		prompt_category TEXT,
+		response_preview TEXT,
+		duration_seconds DOUBLE PRECISION,
+		tokens_generated INTEGER,
+		tokens_per_second DOUBLE PRECISION,
+		prompt_tokens INTEGER DEFAULT 0,
+		load_duration DOUBLE PRECISION DEFAULT 0,
+		total_duration DOUBLE PRECISION DEFAULT 0,
+		status_code INTEGER,
+		error_message TEXT,
+		user_agent TEXT,
+		client_ip TEXT,
+		"user" TEXT DEFAULT '',
+		cost DOUBLE PRECISION DEFAULT 0,
+		status TEXT DEFAULT 'success',
+		queue_time DOUBLE PRECISION DEFAULT 0,
+		time_to_first_token DOUBLE PRECISION DEFAULT 0,
+		metadata TEXT DEFAULT '{}'
+	);`
+
+	if _, err := db.Exec(createTableSQL); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to create table: %w", err)
+	}
+
+	// Create indexes
+	indexes := []string{
+		"CREATE INDEX IF NOT EXISTS idx_timestamp ON interactions(timestamp);",
+		"CREATE INDEX IF NOT EXISTS idx_model ON interactions(model);",
+		"CREATE INDEX IF NOT EXISTS idx_prompt_category ON interactions(prompt_category);",
+		"CREATE INDEX IF NOT EXISTS idx_user ON interactions(\"user\");",
+	}
+
+	for _, idx := range indexes {
+		if _, err := db.Exec(idx); err != nil {
+			log.Printf("Failed to create index: %v", err)
+		}
+	}
+
+	aw.setStore(&postgresStore{db: db})
+	return nil
+}
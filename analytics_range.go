@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseRangePreset translates a range preset into a start and end time ending
+// at now: "today" starts at local midnight, "24h", "7d", "30d" (any number of
+// hours or days) start that long ago
+func parseRangePreset(preset string, now time.Time) (start, end time.Time, err error) {
+	preset = strings.ToLower(strings.TrimSpace(preset))
+	if preset == "today" {
+		year, month, day := now.Date()
+		return time.Date(year, month, day, 0, 0, 0, 0, now.Location()), now, nil
+	}
+
+	unit := time.Hour
+	value, ok := strings.CutSuffix(preset, "h")
+	if !ok {
+		if value, ok = strings.CutSuffix(preset, "d"); ok {
+			unit = 24 * time.Hour
+		}
+	}
+	n, convErr := strconv.Atoi(value)
+	if !ok || convErr != nil || n <= 0 {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid range %q: expected today or a number of hours or days such as 24h, 7d, 30d", preset)
+	}
+	return now.Add(-time.Duration(n) * unit), now, nil
+}
+
+// applyTimeRange rewrites a range=... query parameter on r into the
+// start_time/end_time Unix seconds the search filters understand. Explicit
+// start_time or end_time values take precedence over the preset.
+func applyTimeRange(r *http.Request) error {
+	query := r.URL.Query()
+	preset := query.Get("range")
+	if preset == "" {
+		return nil
+	}
+	start, end, err := parseRangePreset(preset, time.Now())
+	if err != nil {
+		return err
+	}
+	if query.Get("start_time") == "" {
+		query.Set("start_time", strconv.FormatInt(start.Unix(), 10))
+	}
+	if query.Get("end_time") == "" {
+		query.Set("end_time", strconv.FormatInt(end.Unix(), 10))
+	}
+	query.Del("range")
+	r.URL.RawQuery = query.Encode()
+	return nil
+}
+
+// withTimeRange applies range presets before an analytics handler reads its query
+func withTimeRange(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := applyTimeRange(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		next(w, r)
+	}
+}
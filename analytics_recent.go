@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// DefaultRecentRequests is how many records /analytics/recent keeps in memory
+const DefaultRecentRequests = 200
+
+// recentRequests is a ring buffer of the latest analytics records. It is
+// filled synchronously when a request completes, so it shows the tail even
+// while the write queue is backed up or the database is unavailable.
+type recentRequests struct {
+	mu      sync.Mutex
+	records []AnalyticsRecord
+	next    int  // Slot the next record is written to
+	full    bool // Every slot has been written at least once
+}
+
+// newRecentRequests creates a ring buffer sized by RECENT_REQUESTS_SIZE
+func newRecentRequests() *recentRequests {
+	size := getEnvInt("RECENT_REQUESTS_SIZE", DefaultRecentRequests)
+	if size <= 0 {
+		size = DefaultRecentRequests
+	}
+	return &recentRequests{records: make([]AnalyticsRecord, size)}
+}
+
+// add stores a record, overwriting the oldest once the buffer is full
+func (b *recentRequests) add(record AnalyticsRecord) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.records[b.next] = record
+	b.next = (b.next + 1) % len(b.records)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// latest returns up to limit records, newest first
+func (b *recentRequests) latest(limit int) []AnalyticsRecord {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	count := b.next
+	if b.full {
+		count = len(b.records)
+	}
+	if limit <= 0 || limit > count {
+		limit = count
+	}
+
+	result := make([]AnalyticsRecord, 0, limit)
+	for i := 1; i <= limit; i++ {
+		result = append(result, b.records[(b.next-i+len(b.records))%len(b.records)])
+	}
+	return result
+}
+
+// handleAnalyticsRecent serves the in-memory tail of completed requests
+// without touching the database. ?limit=N returns only the newest N.
+func (p *Proxy) handleAnalyticsRecent(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed < 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	records := p.recentRequests.latest(limit)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"records":  records,
+		"count":    len(records),
+		"capacity": len(p.recentRequests.records),
+	})
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// tailAnalytics implements `ollama-proxy logs-tail`: it prints the newest
+// analytics records as JSON lines, then polls for new rows until interrupted.
+// The database is opened read-only on its own connection, so it can run next
+// to a live proxy without contending with its single writer connection.
+func tailAnalytics(args []string) error {
+	fs := flag.NewFlagSet("logs-tail", flag.ExitOnError)
+	count := fs.Int("n", 10, "number of recent records to print first")
+	interval := fs.Duration("interval", time.Second, "how often to poll for new records")
+	dir := fs.String("dir", getAnalyticsDir(false), "analytics directory containing ollama_analytics.db")
+	fs.Parse(args)
+
+	if backend := getAnalyticsBackend(); backend != "sqlite" {
+		return fmt.Errorf("logs-tail reads the SQLite database, but ANALYTICS_BACKEND is %q", backend)
+	}
+	if *interval <= 0 {
+		*interval = time.Second
+	}
+
+	dbPath := filepath.Join(*dir, sqliteDBFile)
+	if _, err := os.Stat(dbPath); err != nil {
+		return fmt.Errorf("analytics database not found: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", "file:"+filepath.ToSlash(dbPath)+"?mode=ro&_pragma=busy_timeout(5000)&_pragma=query_only(1)")
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	encoder := json.NewEncoder(os.Stdout)
+
+	// Print the newest records oldest-first, like tail
+	recent, err := queryRecords(db, "SELECT * FROM (SELECT "+recordColumns+" FROM interactions ORDER BY id DESC LIMIT ?) ORDER BY id", *count)
+	if err != nil {
+		return err
+	}
+	var lastID int64
+	for _, r := range recent {
+		encoder.Encode(r)
+		lastID = r.ID
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			records, err := queryRecords(db, "SELECT "+recordColumns+" FROM interactions WHERE id > ? ORDER BY id", lastID)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				continue
+			}
+			for _, r := range records {
+				encoder.Encode(r)
+				lastID = r.ID
+			}
+		case <-sigChan:
+			return nil
+		}
+	}
+}
+
+// queryRecords runs a query selecting recordColumns and scans every row
+func queryRecords(db *sql.DB, query string, args ...interface{}) ([]AnalyticsRecord, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var records []AnalyticsRecord
+	for rows.Next() {
+		r, err := scanRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestPurgeClientIPWildcards(t *testing.T) {
+	p := newTestProxy(t, "http://127.0.0.1:0")
+	clientIPs := []string{
+		"10.0.0.1:5000",
+		"10.0.0.12:5000",
+		"[2001:db8::1]:5000",
+		"10.0.0.1 (via 127.0.0.1:6000)",
+		"10.0.0.3 (via 127.0.0.1:6000)",
+	}
+	for _, clientIP := range clientIPs {
+		p.analytics.Record(AnalyticsRecord{Timestamp: time.Now(), Model: "llama3", Status: "success", ClientIP: clientIP})
+	}
+	waitForRecords(t, p, len(clientIPs))
+
+	for _, pattern := range []string{"%", "_", "10.0.0.%", "10.0.0._", `10.0.0.1\`} {
+		deleted, err := p.analytics.Purge(PurgeFilter{ClientIP: pattern})
+		if err != nil {
+			t.Fatalf("purge %q: %v", pattern, err)
+		}
+		if deleted != 0 {
+			t.Errorf("purge %q deleted %d records, want 0", pattern, deleted)
+		}
+	}
+
+	deleted, err := p.analytics.Purge(PurgeFilter{ClientIP: "10.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Errorf("purge 10.0.0.1 deleted %d records, want its direct and forwarded records", deleted)
+	}
+	deleted, err = p.analytics.Purge(PurgeFilter{ClientIP: "2001:db8::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Errorf("purge 2001:db8::1 deleted %d records, want 1", deleted)
+	}
+}
+
+// waitForRecords waits for the writer to store n records
+func waitForRecords(t *testing.T, p *Proxy, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if records, err := p.analytics.Search(url.Values{}); err == nil && len(records) >= n {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("%d analytics records were not stored", n)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// auditQueueSize bounds the entries waiting to be written to the audit log
+const auditQueueSize = 10000
+
+// AuditEntry is one completed interaction in the audit log. Unlike analytics
+// records, prompt and response are never truncated, redacted or cleaned up.
+type AuditEntry struct {
+	Timestamp  time.Time     `json:"timestamp"`
+	RequestID  string        `json:"request_id"`
+	User       string        `json:"user"`
+	ClientIP   string        `json:"client_ip"`
+	UserAgent  string        `json:"user_agent,omitempty"`
+	Model      string        `json:"model"`
+	Endpoint   string        `json:"endpoint"`
+	StatusCode int           `json:"status_code"`
+	Status     string        `json:"status"`
+	Prompt     string        `json:"prompt"`
+	Messages   []ChatMessage `json:"messages,omitempty"`
+	Response   string        `json:"response"`
+	ToolCalls  []string      `json:"tool_calls,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// auditLogger appends completed interactions as JSON lines to a daily file
+// (audit-YYYY-MM-DD.jsonl, UTC) in AUDIT_LOG_DIR. Writes happen on a
+// background goroutine so logging never adds request latency.
+type auditLogger struct {
+	dir     string
+	queue   chan AuditEntry
+	wg      sync.WaitGroup
+	file    *os.File
+	day     string       // Date of the open file
+	dropped atomic.Int64 // Entries lost because the queue was full
+}
+
+// newAuditLogger starts the audit logger, or returns nil when AUDIT_LOG_DIR is unset
+func newAuditLogger() *auditLogger {
+	dir := strings.TrimSpace(os.Getenv("AUDIT_LOG_DIR"))
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		log.Printf("Warning: Audit logging disabled, cannot create %s: %v", dir, err)
+		return nil
+	}
+
+	a := &auditLogger{
+		dir:   dir,
+		queue: make(chan AuditEntry, auditQueueSize),
+	}
+	a.wg.Add(1)
+	go a.run()
+	log.Printf("Audit logging enabled: %s", dir)
+	return a
+}
+
+// Log queues an entry without blocking the request
+func (a *auditLogger) Log(entry AuditEntry) {
+	select {
+	case a.queue <- entry:
+	default:
+		dropped := a.dropped.Add(1)
+		log.Printf("Warning: Audit log queue full, dropped entry for request %s (%d dropped so far)", entry.RequestID, dropped)
+	}
+}
+
+// run writes queued entries until the queue is closed
+func (a *auditLogger) run() {
+	defer a.wg.Done()
+	for entry := range a.queue {
+		if err := a.write(entry); err != nil {
+			log.Printf("Audit log write error for request %s: %v", entry.RequestID, err)
+		}
+	}
+	if a.file != nil {
+		a.file.Close()
+	}
+}
+
+// write appends entry to the file for its day, rotating at UTC midnight
+func (a *auditLogger) write(entry AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	day := entry.Timestamp.UTC().Format("2006-01-02")
+	if a.file == nil || day != a.day {
+		if a.file != nil {
+			a.file.Close()
+			a.file = nil
+		}
+		path := filepath.Join(a.dir, fmt.Sprintf("audit-%s.jsonl", day))
+		// Append-only and owner-readable: entries hold full prompts and responses
+		a.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return err
+		}
+		a.day = day
+	}
+
+	_, err = a.file.Write(append(line, '\n'))
+	return err
+}
+
+// Close writes the remaining queued entries and closes the current file
+func (a *auditLogger) Close() {
+	close(a.queue)
+	a.wg.Wait()
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// loadAPIKeys parses the comma-separated PROXY_API_KEYS environment variable
+func loadAPIKeys() []string {
+	var keys []string
+	for _, key := range strings.Split(os.Getenv("PROXY_API_KEYS"), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+const (
+	DefaultUserHeader = "X-User-Id"
+	DefaultTagHeader  = "X-Request-Tag"
+	anonymousUser     = "anonymous"
+	maxUserIDLength   = 128
+	maxTagLength      = 128
+)
+
+// loadUserHeader returns the request header that identifies the calling user (USER_HEADER)
+func loadUserHeader() string {
+	if header := strings.TrimSpace(os.Getenv("USER_HEADER")); header != "" {
+		return header
+	}
+	return DefaultUserHeader
+}
+
+// loadTagHeader returns the request header clients use to tag requests for analytics (TAG_HEADER)
+func loadTagHeader() string {
+	if header := strings.TrimSpace(os.Getenv("TAG_HEADER")); header != "" {
+		return header
+	}
+	return DefaultTagHeader
+}
+
+// requestTag returns the tag named in the configured header, or "" when untagged
+func (p *Proxy) requestTag(r *http.Request) string {
+	tag := strings.TrimSpace(r.Header.Get(p.tagHeader))
+	if len(tag) > maxTagLength {
+		tag = tag[:maxTagLength]
+	}
+	return tag
+}
+
+// requestUser returns the user named in the configured header, or "anonymous"
+func (p *Proxy) requestUser(r *http.Request) string {
+	user := strings.TrimSpace(r.Header.Get(p.userHeader))
+	if user == "" {
+		return anonymousUser
+	}
+	if len(user) > maxUserIDLength {
+		user = user[:maxUserIDLength]
+	}
+	return user
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+// matchesKey compares a token against the allowed keys in constant time
+func matchesKey(token string, keys []string) bool {
+	if token == "" {
+		return false
+	}
+	matched := false
+	for _, key := range keys {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(key)) == 1 {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// authorizeProxyRequest checks the API key for proxied requests.
+// Returns true when no keys are configured (open access).
+func (p *Proxy) authorizeProxyRequest(r *http.Request) bool {
+	if len(p.apiKeys) == 0 {
+		return true
+	}
+	return matchesKey(bearerToken(r), p.apiKeys)
+}
+
+// requireAdmin wraps admin handlers (/metrics, /analytics/*) with ADMIN_API_KEY checking.
+// Admin endpoints are disabled (403) until ADMIN_API_KEY is set.
+func (p *Proxy) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if p.adminAPIKey == "" {
+			http.Error(w, "Forbidden: set ADMIN_API_KEY to enable admin endpoints", http.StatusForbidden)
+			return
+		}
+		if !matchesKey(bearerToken(r), []string{p.adminAPIKey}) {
+			p.recordUnauthorized(r)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="ollama-proxy-admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// recordUnauthorized logs and counts a rejected authentication attempt. Rejections
+// are not stored in analytics, so a client hammering bad keys can't flood the writer.
+func (p *Proxy) recordUnauthorized(r *http.Request) {
+	clientIP := r.RemoteAddr
+	if xForwardedFor := r.Header.Get("X-Forwarded-For"); xForwardedFor != "" {
+		clientIP = xForwardedFor + " (via " + r.RemoteAddr + ")"
+	}
+	log.Printf("[%s] Rejected unauthorized request: %s %s", clientIP, r.Method, r.URL.Path)
+	p.metrics.unauthorized.Inc()
+}
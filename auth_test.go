@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		name       string
+		adminKey   string
+		proxyKeys  string
+		token      string
+		wantStatus int
+	}{
+		{name: "no keys configured", wantStatus: http.StatusForbidden},
+		{name: "only proxy keys configured", proxyKeys: "client-key", token: "client-key", wantStatus: http.StatusForbidden},
+		{name: "missing token", adminKey: "admin-key", wantStatus: http.StatusUnauthorized},
+		{name: "proxy key is not an admin key", adminKey: "admin-key", proxyKeys: "client-key", token: "client-key", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", adminKey: "admin-key", token: "guess", wantStatus: http.StatusUnauthorized},
+		{name: "admin key", adminKey: "admin-key", token: "admin-key", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ADMIN_API_KEY", tt.adminKey)
+			t.Setenv("PROXY_API_KEYS", tt.proxyKeys)
+			p := newTestProxy(t, "http://127.0.0.1:0")
+
+			handler := p.requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/analytics/stats", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestUnauthorizedRequestsAreCountedNotStored(t *testing.T) {
+	t.Setenv("PROXY_API_KEYS", "client-key")
+	p := newTestProxy(t, "http://127.0.0.1:0")
+
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		p.handleProxy(rec, httptest.NewRequest(http.MethodPost, "/api/generate", nil))
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("status = %d, want 401", rec.Code)
+		}
+	}
+
+	if got := testutil.ToFloat64(p.metrics.unauthorized); got != 3 {
+		t.Errorf("ollama_unauthorized_requests_total = %v, want 3", got)
+	}
+
+	// Anything queued by the rejections is written before this marker
+	p.analytics.Record(AnalyticsRecord{Timestamp: time.Now(), Model: "marker", Status: "success"})
+	waitForRecord(t, p)
+	if records, err := p.analytics.Search(url.Values{}); err != nil || len(records) != 1 {
+		t.Errorf("analytics records = %d (err %v), want only the marker", len(records), err)
+	}
+}
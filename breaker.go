@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Circuit breaker states, also the value of the ollama_circuit_breaker_state gauge
+const (
+	breakerClosed   = 0
+	breakerHalfOpen = 1
+	breakerOpen     = 2
+)
+
+// errCircuitOpen is returned for requests rejected while the breaker is open
+var errCircuitOpen = errors.New("circuit breaker open: Ollama is unavailable")
+
+// circuitBreaker fast-fails upstream requests after repeated failures, so a
+// down Ollama costs each request nothing instead of a 30s dial timeout.
+// After the cooldown one probe request is let through (half-open); its
+// result closes the circuit or opens it for another cooldown.
+type circuitBreaker struct {
+	threshold int           // Consecutive failures that open the circuit (0 disables the breaker)
+	window    time.Duration // Failures further apart than this don't accumulate
+	cooldown  time.Duration // How long the circuit stays open before probing
+
+	mu          sync.Mutex
+	state       int
+	failures    int
+	lastFailure time.Time
+	openedAt    time.Time
+	probing     bool // A half-open probe request is in flight
+}
+
+// newCircuitBreaker reads PROXY_BREAKER_THRESHOLD, PROXY_BREAKER_WINDOW and PROXY_BREAKER_COOLDOWN
+func newCircuitBreaker() *circuitBreaker {
+	threshold := getEnvInt("PROXY_BREAKER_THRESHOLD", 5)
+	if threshold < 0 {
+		threshold = 0
+	}
+	return &circuitBreaker{
+		threshold: threshold,
+		window:    getEnvDuration("PROXY_BREAKER_WINDOW", 30*time.Second),
+		cooldown:  getEnvDuration("PROXY_BREAKER_COOLDOWN", 15*time.Second),
+	}
+}
+
+// allow reports whether a request may go upstream
+func (b *circuitBreaker) allow() bool {
+	if b.threshold == 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = breakerHalfOpen
+		log.Printf("Circuit breaker half-open: probing Ollama")
+		fallthrough
+	case breakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+	}
+	return true
+}
+
+// success records a healthy upstream response
+func (b *circuitBreaker) success() {
+	if b.threshold == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != breakerClosed {
+		log.Printf("Circuit breaker closed: Ollama is responding again")
+	}
+	b.state = breakerClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// failure records a failed upstream attempt, opening the circuit at the threshold
+func (b *circuitBreaker) failure() {
+	if b.threshold == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(b.lastFailure) > b.window {
+		b.failures = 0
+	}
+	b.failures++
+	b.lastFailure = now
+
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		if b.state != breakerOpen {
+			log.Printf("Circuit breaker open after %d consecutive upstream failures, rejecting requests for %s", b.failures, b.cooldown)
+		}
+		b.state = breakerOpen
+		b.openedAt = now
+	}
+	b.probing = false
+}
+
+// release ends a request that neither succeeded nor failed upstream (e.g. the
+// client went away), letting another request probe in half-open state
+func (b *circuitBreaker) release() {
+	if b.threshold == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+// retryAfter returns the time left until the next probe
+func (b *circuitBreaker) retryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if remaining := b.cooldown - time.Since(b.openedAt); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// currentState returns the breaker state (closed, half-open or open)
+func (b *circuitBreaker) currentState() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// stateName returns a readable name for the current state
+func (b *circuitBreaker) stateName() string {
+	switch b.currentState() {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half_open"
+	}
+	return "closed"
+}
+
+// registerBreakerMetrics exposes the breaker state as a gauge, read at scrape time
+func (mc *MetricsCollector) registerBreakerMetrics(b *circuitBreaker) {
+	mc.registry.MustRegister(
+		prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "ollama_circuit_breaker_state",
+				Help: "Upstream circuit breaker state (0 = closed, 1 = half-open, 2 = open)",
+			},
+			func() float64 {
+				return float64(b.currentState())
+			},
+		),
+	)
+}
+
+// breakerTransport guards an upstream transport with a circuit breaker
+type breakerTransport struct {
+	next    http.RoundTripper
+	breaker *circuitBreaker
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.breaker.allow() {
+		return nil, errCircuitOpen
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	switch {
+	case err != nil && req.Context().Err() != nil:
+		// Client disconnect or request timeout: says nothing about Ollama's health
+		t.breaker.release()
+	case err != nil:
+		t.breaker.failure()
+	case resp.StatusCode == http.StatusBadGateway ||
+		resp.StatusCode == http.StatusServiceUnavailable ||
+		resp.StatusCode == http.StatusGatewayTimeout:
+		t.breaker.failure()
+	default:
+		t.breaker.success()
+	}
+	return resp, err
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBreakerTransportTransitions(t *testing.T) {
+	const cooldown = 50 * time.Millisecond
+	var calls, failUntil atomic.Int32
+	failUntil.Store(4)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failUntil.Load() {
+			http.Error(w, "loading model", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	t.Setenv("PROXY_BREAKER_THRESHOLD", "3")
+	t.Setenv("PROXY_BREAKER_COOLDOWN", cooldown.String())
+	breaker := newCircuitBreaker()
+	client := &http.Client{Transport: &breakerTransport{next: http.DefaultTransport, breaker: breaker}}
+
+	get := func() (int, error) {
+		resp, err := client.Get(upstream.URL + "/api/tags")
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+	expectState := func(want int) {
+		t.Helper()
+		if got := breaker.currentState(); got != want {
+			t.Fatalf("state = %s (%d), want %d", breaker.stateName(), got, want)
+		}
+	}
+
+	// Failures below the threshold keep the circuit closed
+	for i := 0; i < 2; i++ {
+		if status, err := get(); err != nil || status != http.StatusServiceUnavailable {
+			t.Fatalf("request %d: status %d, err %v", i+1, status, err)
+		}
+		expectState(breakerClosed)
+	}
+
+	// The third failure opens it, and requests then fail fast without reaching upstream
+	get()
+	expectState(breakerOpen)
+	if _, err := get(); !errors.Is(err, errCircuitOpen) {
+		t.Fatalf("err = %v, want errCircuitOpen", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Fatalf("upstream calls = %d, want 3", got)
+	}
+	if retry := breaker.retryAfter(); retry <= 0 || retry > cooldown {
+		t.Errorf("retryAfter = %v, want within the %v cooldown", retry, cooldown)
+	}
+
+	// After the cooldown a single probe goes through; a failed probe reopens the circuit
+	time.Sleep(cooldown)
+	if status, err := get(); err != nil || status != http.StatusServiceUnavailable {
+		t.Fatalf("probe: status %d, err %v", status, err)
+	}
+	expectState(breakerOpen)
+	if _, err := get(); !errors.Is(err, errCircuitOpen) {
+		t.Fatalf("err after failed probe = %v, want errCircuitOpen", err)
+	}
+
+	// Only one probe is let through while half-open
+	time.Sleep(cooldown)
+	if !breaker.allow() {
+		t.Fatal("probe after cooldown was rejected")
+	}
+	expectState(breakerHalfOpen)
+	if breaker.allow() {
+		t.Fatal("second request was allowed while the probe is in flight")
+	}
+	breaker.release()
+
+	// A successful probe closes the circuit again
+	if status, err := get(); err != nil || status != http.StatusOK {
+		t.Fatalf("recovered probe: status %d, err %v", status, err)
+	}
+	expectState(breakerClosed)
+	for i := 0; i < 3; i++ {
+		if status, err := get(); err != nil || status != http.StatusOK {
+			t.Fatalf("request %d after recovery: status %d, err %v", i+1, status, err)
+		}
+	}
+	if got := calls.Load(); got != 8 {
+		t.Errorf("upstream calls = %d, want 8", got)
+	}
+}
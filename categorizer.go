@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CategoryRule maps a prompt regex to a category name
+type CategoryRule struct {
+	Pattern  string `json:"pattern" yaml:"pattern"`
+	Category string `json:"category" yaml:"category"`
+}
+
+// CategorizerConfig holds custom prompt categorization rules
+type CategorizerConfig struct {
+	ReplaceDefaults bool           `json:"replace_defaults" yaml:"replace_defaults"`
+	Rules           []CategoryRule `json:"rules" yaml:"rules"`
+}
+
+// loadCategorizerConfig reads categorization rules from a JSON or YAML file.
+// Files ending in .yaml or .yml are parsed as YAML, everything else as JSON.
+// Example:
+//
+//	{
+//	  "replace_defaults": false,
+//	  "rules": [{"pattern": "contract|clause", "category": "contract_review"}]
+//	}
+func loadCategorizerConfig(path string) (*CategorizerConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read categorizer config: %w", err)
+	}
+
+	var config CategorizerConfig
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &config)
+	default:
+		err = json.Unmarshal(data, &config)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse categorizer config: %w", err)
+	}
+
+	return &config, nil
+}
+
+// compileCategoryRules compiles rules, logging and skipping invalid entries
+func compileCategoryRules(rules []CategoryRule) []patternCategory {
+	var compiled []patternCategory
+	for _, rule := range rules {
+		if rule.Pattern == "" || rule.Category == "" {
+			log.Printf("Warning: Skipping categorizer rule with empty pattern or category: %+v", rule)
+			continue
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			log.Printf("Warning: Skipping invalid categorizer pattern %q: %v", rule.Pattern, err)
+			continue
+		}
+		compiled = append(compiled, patternCategory{
+			pattern:  re,
+			category: rule.Category,
+		})
+	}
+	return compiled
+}
+
+// LoadRules applies custom rules from a config file. Custom rules are checked
+// before the built-in patterns, or replace them when replace_defaults is set.
+// Calling it again replaces the previously loaded custom rules.
+func (pc *PromptCategorizer) LoadRules(path string) error {
+	config, err := loadCategorizerConfig(path)
+	if err != nil {
+		return err
+	}
+
+	custom := compileCategoryRules(config.Rules)
+	patterns := custom
+	if !config.ReplaceDefaults {
+		patterns = append(custom, pc.defaults...)
+	}
+
+	pc.mu.Lock()
+	pc.patterns = patterns
+	pc.mu.Unlock()
+
+	log.Printf("Loaded %d categorizer rules from %s (replace_defaults=%v)", len(custom), path, config.ReplaceDefaults)
+	return nil
+}
+
+// CategorizeResult explains how a prompt would be categorized
+type CategorizeResult struct {
+	Category string `json:"category"`
+	Source   string `json:"source"`            // pattern, first_word, other or empty
+	Pattern  string `json:"pattern,omitempty"` // Matching regex when source is pattern
+}
+
+// Explain categorizes a prompt without recording a new first-word category,
+// reporting which rule produced the result
+func (pc *PromptCategorizer) Explain(prompt string) CategorizeResult {
+	if prompt == "" {
+		return CategorizeResult{Category: "empty", Source: "empty"}
+	}
+
+	promptLower := strings.ToLower(prompt)
+	if p, ok := pc.matchPattern(promptLower); ok {
+		return CategorizeResult{Category: p.category, Source: "pattern", Pattern: p.pattern.String()}
+	}
+
+	if words := strings.Fields(prompt); len(words) > 0 {
+		firstWord := strings.ToLower(words[0])
+		pc.mu.RLock()
+		known := pc.categories[firstWord]
+		count := len(pc.categories)
+		pc.mu.RUnlock()
+		if known || count < pc.maxWords {
+			return CategorizeResult{Category: firstWord, Source: "first_word"}
+		}
+	}
+
+	return CategorizeResult{Category: OtherCategory, Source: "other"}
+}
+
+// ActiveCategories returns the first-word categories recorded so far
+func (pc *PromptCategorizer) ActiveCategories() []string {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+
+	categories := make([]string, 0, len(pc.categories))
+	for category := range pc.categories {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// handleCategorize is a dry run of prompt categorization for tuning CATEGORIZER_CONFIG.
+// POST {"prompt": "..."} returns the category and matching rule; GET lists active categories.
+func (p *Proxy) handleCategorize(w http.ResponseWriter, r *http.Request) {
+	categorizer := p.metrics.categorizer
+	response := map[string]interface{}{
+		"active_categories": categorizer.ActiveCategories(),
+		"max_categories":    categorizer.maxWords,
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req struct {
+			Prompt string `json:"prompt"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON body: expected {\"prompt\": \"...\"}", http.StatusBadRequest)
+			return
+		}
+		response["result"] = categorizer.Explain(req.Prompt)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
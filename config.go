@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the proxy's core settings. Each value comes from, in order of
+// precedence: its environment variable, the --config file, then the default.
+type Config struct {
+	ProxyPort         int    `yaml:"proxy_port" toml:"proxy_port"`                   // PROXY_PORT
+	OllamaBackendPort int    `yaml:"ollama_backend_port" toml:"ollama_backend_port"` // OLLAMA_BACKEND_PORT
+	ProxyBindAddr     string `yaml:"proxy_bind_addr" toml:"proxy_bind_addr"`         // PROXY_BIND_ADDR
+	OllamaBindAddr    string `yaml:"ollama_bind_addr" toml:"ollama_bind_addr"`       // OLLAMA_BIND_ADDR
+	OllamaTargetURL   string `yaml:"ollama_target_url" toml:"ollama_target_url"`     // OLLAMA_TARGET_URL
+	ManageOllama      bool   `yaml:"manage_ollama" toml:"manage_ollama"`             // MANAGE_OLLAMA
+
+	// Env sets any other environment-variable setting from the file, e.g.
+	// ANALYTICS_RETENTION_DAYS. Variables already in the environment win.
+	Env map[string]string `yaml:"env" toml:"env"`
+}
+
+// appConfig is the active configuration, set by loadConfig at startup
+var appConfig = defaultConfig()
+
+// configFileEnv records the variables loadConfig copied from the file's env
+// section, so install-service doesn't freeze them into the service definition
+var configFileEnv = map[string]bool{}
+
+// defaultConfig returns the built-in defaults
+func defaultConfig() *Config {
+	return &Config{
+		ProxyPort:         DefaultProxyPort,
+		OllamaBackendPort: DefaultOllamaPort,
+		OllamaBindAddr:    "0.0.0.0",
+		ManageOllama:      true,
+	}
+}
+
+// loadConfig builds the active configuration from the defaults, the config
+// file at path (if any) and the environment. Files ending in .toml are parsed
+// as TOML, everything else as YAML (which also accepts JSON).
+func loadConfig(path string) error {
+	cfg := defaultConfig()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := parseConfig(path, data, cfg); err != nil {
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+		if cfg.ProxyPort <= 0 || cfg.ProxyPort > 65535 || cfg.OllamaBackendPort <= 0 || cfg.OllamaBackendPort > 65535 {
+			return fmt.Errorf("invalid port in config file %s: ports must be between 1 and 65535", path)
+		}
+
+		// Settings without a Config field are read from the environment
+		// throughout the proxy, so hand file values over there
+		for name, value := range cfg.Env {
+			if _, set := os.LookupEnv(name); !set {
+				os.Setenv(name, value)
+				configFileEnv[name] = true
+			}
+		}
+
+		// An installed service re-reads the file on start, wherever it runs from
+		if abs, err := filepath.Abs(path); err == nil {
+			os.Setenv("PROXY_CONFIG", abs)
+		}
+		log.Printf("Loaded config file %s", path)
+	}
+	appConfig = cfg.withEnv()
+	return nil
+}
+
+// parseConfig decodes data into cfg, rejecting unknown keys so typos don't
+// silently fall back to defaults
+func parseConfig(path string, data []byte, cfg *Config) error {
+	if strings.ToLower(filepath.Ext(path)) == ".toml" {
+		meta, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return err
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("unknown setting %q", undecoded[0].String())
+		}
+		return nil
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	// An empty file decodes to io.EOF; treat it as "all defaults"
+	if err := decoder.Decode(cfg); err != nil && len(bytes.TrimSpace(data)) > 0 {
+		return err
+	}
+	return nil
+}
+
+// withEnv applies environment variable overrides to c and returns it
+func (c *Config) withEnv() *Config {
+	c.ProxyPort = envPort("PROXY_PORT", c.ProxyPort)
+	c.OllamaBackendPort = envPort("OLLAMA_BACKEND_PORT", c.OllamaBackendPort)
+	if addr := strings.TrimSpace(os.Getenv("PROXY_BIND_ADDR")); addr != "" {
+		c.ProxyBindAddr = addr
+	}
+	if addr := strings.TrimSpace(os.Getenv("OLLAMA_BIND_ADDR")); addr != "" {
+		c.OllamaBindAddr = addr
+	}
+	if target := strings.TrimSpace(os.Getenv("OLLAMA_TARGET_URL")); target != "" {
+		c.OllamaTargetURL = target
+	}
+	if manage := strings.TrimSpace(os.Getenv("MANAGE_OLLAMA")); manage != "" {
+		c.ManageOllama = !strings.EqualFold(manage, "false")
+	}
+	return c
+}
+
+// envPort returns the port in the named environment variable, or def if
+// unset or not a valid port
+func envPort(name string, def int) int {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return def
+	}
+	if port, err := strconv.Atoi(value); err == nil && port > 0 && port <= 65535 {
+		return port
+	}
+	log.Printf("Warning: Invalid value for %s: %q, using %d", name, value, def)
+	return def
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// connPoolTransport reports how the upstream connection pool behaves: open,
+// in-use and idle connections, dials and how long requests wait for a
+// connection. Wraps the base transport, so every retry attempt is traced.
+type connPoolTransport struct {
+	next    http.RoundTripper
+	metrics *MetricsCollector
+	open    atomic.Int64 // Connections dialed and not yet closed
+	inUse   atomic.Int64 // Requests holding a connection (HTTP/2 requests may share one)
+}
+
+// newConnPoolTransport instruments transport's dialer and returns a
+// RoundTripper that traces each request through it
+func newConnPoolTransport(transport *http.Transport, metrics *MetricsCollector) *connPoolTransport {
+	t := &connPoolTransport{next: transport, metrics: metrics}
+
+	dial := transport.DialContext
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		t.open.Add(1)
+		t.updateGauges()
+		return &trackedConn{Conn: conn, onClose: func() {
+			t.open.Add(-1)
+			t.updateGauges()
+		}}, nil
+	}
+	t.updateGauges()
+	return t
+}
+
+// updateGauges publishes the in-use and idle connection counts
+func (t *connPoolTransport) updateGauges() {
+	inUse := t.inUse.Load()
+	idle := t.open.Load() - inUse
+	if idle < 0 {
+		// HTTP/2 multiplexes several requests over one connection
+		idle = 0
+	}
+	t.metrics.upstreamConns.WithLabelValues("in_use").Set(float64(inUse))
+	t.metrics.upstreamConns.WithLabelValues("idle").Set(float64(idle))
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *connPoolTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var (
+		getConn  time.Time
+		acquired atomic.Bool
+		dialMu   sync.Mutex
+		dials    = make(map[string]time.Time)
+	)
+	trace := &httptrace.ClientTrace{
+		GetConn: func(string) {
+			getConn = time.Now()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			acquired.Store(true)
+			t.inUse.Add(1)
+			t.updateGauges()
+			t.metrics.upstreamConnWait.Observe(time.Since(getConn).Seconds())
+			t.metrics.upstreamConnsAcquired.WithLabelValues(strconv.FormatBool(info.Reused)).Inc()
+		},
+		// Dials may outlive the request and run on another goroutine
+		ConnectStart: func(network, addr string) {
+			dialMu.Lock()
+			dials[network+"/"+addr] = time.Now()
+			dialMu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			dialMu.Lock()
+			start, ok := dials[network+"/"+addr]
+			dialMu.Unlock()
+			result := "success"
+			if err != nil {
+				result = "error"
+			}
+			t.metrics.upstreamDials.WithLabelValues(result).Inc()
+			if ok {
+				t.metrics.upstreamDialSeconds.Observe(time.Since(start).Seconds())
+			}
+		},
+	}
+
+	resp, err := t.next.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if !acquired.Load() {
+		return resp, err
+	}
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			t.inUse.Add(-1)
+			t.updateGauges()
+		})
+	}
+	if err != nil || resp.StatusCode == http.StatusSwitchingProtocols {
+		// Upgraded connections leave the pool; the reverse proxy needs the raw body
+		release()
+		return resp, err
+	}
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releaseOnClose marks the connection as no longer in use when the body is closed
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+}
+
+// Close implements io.Closer
+func (b *releaseOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
+
+// trackedConn reports when a pooled connection is closed
+type trackedConn struct {
+	net.Conn
+	once    sync.Once
+	onClose func()
+}
+
+// Close implements net.Conn
+func (c *trackedConn) Close() error {
+	c.once.Do(c.onClose)
+	return c.Conn.Close()
+}
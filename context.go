@@ -4,23 +4,77 @@ import (
 	"context"
 	"net/http"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 // ProxyContext stores request context for metrics collection
 type ProxyContext struct {
-	StartTime        time.Time
-	Model            string
-	Prompt           string
-	Endpoint         string
-	PromptCategory   string
-	Writer           http.ResponseWriter
-	Request          *http.Request
-	PromptTokens     int
-	LoadDuration     float64
-	TotalDuration    float64
-	ResponsePreview  string
-	TimeToFirstToken float64
-	ClientIP         string
+	StartTime           time.Time
+	Model               string
+	Prompt              string
+	Endpoint            string
+	PromptCategory      string
+	Writer              http.ResponseWriter
+	Request             *http.Request
+	PromptTokens        int
+	LoadDuration        float64
+	TotalDuration       float64
+	Timings             map[string]int64 // Raw nanosecond durations from Ollama's final response
+	ResponsePreview     string
+	ResponseText        string // Full response text, kept for the audit log
+	TimeToFirstToken    float64
+	ClientIP            string
+	RequestID           string        // X-Request-Id shared by logs, analytics and the upstream request
+	User                string        // Caller from the USER_HEADER header, or "anonymous"
+	Tag                 string        // Client-chosen tag from the TAG_HEADER header (empty = untagged)
+	ModelAlias          string        // Model name the client asked for when MODEL_ALIASES rewrote it
+	OptionsDefaulted    []string      // DEFAULT_OPTIONS keys filled in because the client left them unset
+	OptionsForced       []string      // FORCE_OPTIONS keys whose client value was replaced
+	Messages            []ChatMessage // Full chat history when STORE_FULL_MESSAGES is enabled
+	Tools               []string      // Function names the request offered in "tools"
+	ToolCalls           []string      // Function names the model called in its response
+	Images              imageStats    // Images attached to the request (count and decoded size only)
+	ChatTurns           int           // Messages in a chat request's conversation (0 = not a chat)
+	Streaming           bool          // Client asked for a streamed response
+	StreamDowngraded    bool          // stream:false was forced because the client writer can't flush
+	Retries             int           // Upstream retries performed before the final response
+	QueueTime           float64       // Seconds spent waiting for a concurrency slot
+	ResponseBytes       int           // Response body bytes received from Ollama
+	EmbeddingCount      int           // Number of vectors returned by an embedding request
+	EmbeddingDimensions int           // Length of each returned embedding vector
+	ErrorCategory       string        // Normalized upstream error (model_not_found, out_of_memory, ...)
+	TruncatedReason     string        // Why a stream ended without its final chunk (empty = complete)
+	ReplayOf            int64         // Analytics record ID this request replays (0 = not a replay)
+	ColdStart           float64       // Seconds spent starting Ollama after an idle shutdown
+	CoalescedWith       string        // Request ID whose response was shared by single-flight
+	CacheHit            bool          // Answered from the response cache without contacting Ollama
+}
+
+// reusedResponse reports whether the response was generated for another
+// request (response cache or single-flight), so Ollama did no work for this one
+func (c *ProxyContext) reusedResponse() bool {
+	return c.CacheHit || c.CoalescedWith != ""
+}
+
+const (
+	requestIDHeader    = "X-Request-Id"
+	maxRequestIDLength = 128
+)
+
+// requestIDFor reuses the client's X-Request-Id when it is a sane token,
+// otherwise generates a new UUID
+func requestIDFor(r *http.Request) string {
+	id := r.Header.Get(requestIDHeader)
+	if id == "" || len(id) > maxRequestIDLength {
+		return uuid.NewString()
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.' || c == ':') {
+			return uuid.NewString()
+		}
+	}
+	return id
 }
 
 type contextKey string
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// corsPolicy lets browser frontends on other origins read the analytics APIs
+type corsPolicy struct {
+	origins  map[string]bool // Allowed Origin header values
+	allowAll bool            // "*" was configured
+}
+
+// loadCORSPolicy parses ANALYTICS_CORS_ORIGINS, a comma-separated list of
+// origins such as https://dash.example.com, or "*". Returns nil when unset.
+func loadCORSPolicy() *corsPolicy {
+	c := &corsPolicy{origins: make(map[string]bool)}
+	for _, origin := range strings.Split(os.Getenv("ANALYTICS_CORS_ORIGINS"), ",") {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		switch origin {
+		case "":
+		case "*":
+			c.allowAll = true
+		default:
+			c.origins[origin] = true
+		}
+	}
+	if !c.allowAll && len(c.origins) == 0 {
+		return nil
+	}
+	if c.allowAll {
+		log.Printf("CORS enabled for analytics endpoints: all origins")
+	} else {
+		log.Printf("CORS enabled for analytics endpoints: %d origins", len(c.origins))
+	}
+	return c
+}
+
+// wrap adds CORS headers for allowed origins and answers preflight requests
+// before authentication, since browsers send them without credentials. Only
+// wrap analytics handlers: proxied Ollama traffic is left untouched.
+func (c *corsPolicy) wrap(next http.HandlerFunc) http.HandlerFunc {
+	if c == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		allowed := origin != "" && (c.allowAll || c.origins[origin])
+		if allowed {
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			if c.allowAll {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			h.Set("Access-Control-Expose-Headers", "Content-Disposition")
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			if allowed {
+				h := w.Header()
+				h.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+				h.Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+				h.Set("Access-Control-Max-Age", "600")
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next(w, r)
+	}
+}
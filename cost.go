@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// CostRate is the price per 1,000 tokens for a model
+type CostRate struct {
+	InputPer1K  float64 `json:"input_per_1k"`
+	OutputPer1K float64 `json:"output_per_1k"`
+}
+
+// CostConfig maps model names to token pricing for chargeback
+type CostConfig struct {
+	Default CostRate            `json:"default"`
+	Models  map[string]CostRate `json:"models"`
+}
+
+// loadCostConfig reads the cost configuration from a JSON file.
+// Example:
+//
+//	{
+//	  "default": {"input_per_1k": 0.0001, "output_per_1k": 0.0002},
+//	  "models": {"llama3:70b": {"input_per_1k": 0.0008, "output_per_1k": 0.0016}}
+//	}
+func loadCostConfig(path string) (*CostConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cost config: %w", err)
+	}
+
+	var config CostConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse cost config: %w", err)
+	}
+	if config.Models == nil {
+		config.Models = make(map[string]CostRate)
+	}
+
+	log.Printf("Loaded cost config from %s (%d models)", path, len(config.Models))
+	return &config, nil
+}
+
+// RateFor returns the pricing for a model, trying the exact name first,
+// then the name without its tag (e.g. "llama3" for "llama3:8b"), then the default
+func (c *CostConfig) RateFor(model string) CostRate {
+	if rate, ok := c.Models[model]; ok {
+		return rate
+	}
+	if i := strings.Index(model, ":"); i > 0 {
+		if rate, ok := c.Models[model[:i]]; ok {
+			return rate
+		}
+	}
+	return c.Default
+}
+
+// Calculate returns the cost of a request given its token counts
+func (c *CostConfig) Calculate(model string, promptTokens, outputTokens int) float64 {
+	if c == nil {
+		return 0
+	}
+	rate := c.RateFor(model)
+	return float64(promptTokens)/1000*rate.InputPer1K + float64(outputTokens)/1000*rate.OutputPer1K
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// readyCacheTTL is how long a successful upstream contact counts as "ready"
+const readyCacheTTL = 5 * time.Second
+
+// markUpstreamContact records a successful response from Ollama
+func (p *Proxy) markUpstreamContact() {
+	p.lastUpstreamContact.Store(time.Now().UnixNano())
+}
+
+// checkUpstream returns nil if Ollama was reachable within readyCacheTTL or
+// is stopped for being idle, otherwise performs a lightweight check against
+// the Ollama API
+func (p *Proxy) checkUpstream() error {
+	if p.idle.isStopped() {
+		// Stopped to save GPU memory; the next request starts it again
+		return nil
+	}
+	if last := p.lastUpstreamContact.Load(); last > 0 && time.Since(time.Unix(0, last)) < readyCacheTTL {
+		return nil
+	}
+
+	// Serialize probes so a burst of readiness checks makes one upstream call
+	p.readyMu.Lock()
+	defer p.readyMu.Unlock()
+
+	if last := p.lastUpstreamContact.Load(); last > 0 && time.Since(time.Unix(0, last)) < readyCacheTTL {
+		return nil
+	}
+	if time.Since(p.lastReadyCheck) < readyCacheTTL {
+		return p.lastReadyErr
+	}
+
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(p.target.String() + "/api/tags")
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("ollama returned status %d", resp.StatusCode)
+		}
+	}
+
+	p.lastReadyCheck = time.Now()
+	p.lastReadyErr = err
+	if err == nil {
+		p.markUpstreamContact()
+	}
+	return err
+}
+
+// handleHealth reports that the proxy process is up (liveness probe)
+func (p *Proxy) handleHealth(w http.ResponseWriter, r *http.Request) {
+	health := map[string]interface{}{
+		"status":              "ok",
+		"analytics_available": p.analytics.Available(),
+	}
+	if reason := p.analytics.unavailableReason(); reason != "" {
+		// Analytics failing doesn't stop proxying, so the proxy is still live
+		health["analytics_error"] = reason
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(health)
+}
+
+// handleReady reports whether Ollama is reachable (readiness probe)
+func (p *Proxy) handleReady(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := p.checkUpstream(); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "unavailable",
+			"error":  err.Error(),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "ready",
+	})
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// hostSampleTTL is how long a host reading is reused, keeping sampling off the request path
+const hostSampleTTL = 1 * time.Second
+
+// hostSample is a snapshot of host resource usage
+type hostSample struct {
+	CPUPercent     float64
+	MemUsedBytes   uint64
+	MemTotalBytes  uint64
+	MemUsedPercent float64
+}
+
+// hostSampler reads host CPU and memory usage, caching readings for hostSampleTTL.
+// Readings come from readHostSample, which is a no-op on unsupported platforms.
+type hostSampler struct {
+	mu        sync.Mutex
+	last      hostSample
+	lastOK    bool
+	lastAt    time.Time
+	prevIdle  uint64 // CPU idle ticks at the previous reading
+	prevTotal uint64 // CPU total ticks at the previous reading
+}
+
+// newHostSampler returns a sampler when SAMPLE_HOST_METRICS=true, otherwise nil
+func newHostSampler() *hostSampler {
+	if !strings.EqualFold(strings.TrimSpace(os.Getenv("SAMPLE_HOST_METRICS")), "true") {
+		return nil
+	}
+	return &hostSampler{}
+}
+
+// Sample returns the current host usage, or false if unavailable
+func (h *hostSampler) Sample() (hostSample, bool) {
+	if h == nil {
+		return hostSample{}, false
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.lastAt.IsZero() && time.Since(h.lastAt) < hostSampleTTL {
+		return h.last, h.lastOK
+	}
+	h.last, h.lastOK = readHostSample(h)
+	h.lastAt = time.Now()
+	return h.last, h.lastOK
+}
+
+// registerHostMetrics exposes host usage gauges, sampled at scrape time
+func (mc *MetricsCollector) registerHostMetrics(h *hostSampler) {
+	mc.registry.MustRegister(
+		prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "ollama_host_memory_used_bytes",
+				Help: "Host memory in use",
+			},
+			func() float64 {
+				sample, _ := h.Sample()
+				return float64(sample.MemUsedBytes)
+			},
+		),
+		prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "ollama_host_cpu_percent",
+				Help: "Host CPU utilization since the previous sample",
+			},
+			func() float64 {
+				sample, _ := h.Sample()
+				return sample.CPUPercent
+			},
+		),
+	)
+}
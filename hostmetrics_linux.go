@@ -0,0 +1,94 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// readHostSample reads CPU usage from /proc/stat and memory from /proc/meminfo.
+// CPU percent is measured since the previous reading.
+func readHostSample(h *hostSampler) (hostSample, bool) {
+	var sample hostSample
+
+	memOK := readMeminfo(&sample)
+
+	idle, total, cpuOK := readCPUTicks()
+	if cpuOK {
+		if deltaTotal := total - h.prevTotal; h.prevTotal > 0 && deltaTotal > 0 {
+			sample.CPUPercent = 100 * float64(deltaTotal-(idle-h.prevIdle)) / float64(deltaTotal)
+		}
+		h.prevIdle, h.prevTotal = idle, total
+	}
+
+	return sample, memOK || cpuOK
+}
+
+// readMeminfo fills in memory usage from /proc/meminfo
+func readMeminfo(sample *hostSample) bool {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	var totalKB, availableKB uint64
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			totalKB = value
+		case "MemAvailable:":
+			availableKB = value
+		}
+	}
+	if totalKB == 0 {
+		return false
+	}
+
+	sample.MemTotalBytes = totalKB * 1024
+	sample.MemUsedBytes = (totalKB - availableKB) * 1024
+	sample.MemUsedPercent = 100 * float64(totalKB-availableKB) / float64(totalKB)
+	return true
+}
+
+// readCPUTicks returns the aggregate idle and total CPU ticks from /proc/stat
+func readCPUTicks() (idle, total uint64, ok bool) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return 0, 0, false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		return 0, 0, false
+	}
+	fields := strings.Fields(scanner.Text())
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return 0, 0, false
+	}
+	for i, field := range fields[1:] {
+		value, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			continue
+		}
+		total += value
+		// Fields: user nice system idle iowait ...
+		if i == 3 || i == 4 {
+			idle += value
+		}
+	}
+	return idle, total, true
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package main
+
+// readHostSample is not implemented on this platform; host metrics are skipped
+func readHostSample(h *hostSampler) (hostSample, bool) {
+	return hostSample{}, false
+}
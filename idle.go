@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// idleShutdown stops the managed Ollama after IDLE_SHUTDOWN_TIMEOUT without
+// requests, releasing its GPU memory, and starts it again on the next request
+type idleShutdown struct {
+	timeout time.Duration
+	start   func() error // Starts Ollama and waits until it is ready
+	stop    func()
+	metrics *MetricsCollector
+
+	mu          sync.Mutex // Held while Ollama is being started or stopped
+	active      int        // Requests currently using Ollama
+	lastRequest time.Time
+	stopped     atomic.Bool // Ollama was stopped for being idle
+	done        chan struct{}
+}
+
+// enableIdleShutdown turns on idle shutdown when IDLE_SHUTDOWN_TIMEOUT is set.
+// *process is the managed Ollama, replaced on every restart.
+func (p *Proxy) enableIdleShutdown(ollamaPath string, port int, process **OllamaProcess) {
+	timeout := getEnvDuration("IDLE_SHUTDOWN_TIMEOUT", 0)
+	if timeout == 0 {
+		return
+	}
+
+	p.idle = &idleShutdown{
+		timeout: timeout,
+		start: func() error {
+			newProcess, err := startOllama(ollamaPath, port)
+			if err != nil {
+				return err
+			}
+			if !waitForOllama("localhost", port, StartupTimeout) {
+				newProcess.Stop()
+				return fmt.Errorf("ollama did not become ready within %s", StartupTimeout)
+			}
+			*process = newProcess
+			return nil
+		},
+		stop: func() {
+			(*process).Stop()
+			*process = nil
+		},
+		metrics:     p.metrics,
+		lastRequest: time.Now(),
+		done:        make(chan struct{}),
+	}
+	go p.idle.run()
+	LogPrintf("Idle shutdown enabled: Ollama is stopped after %s without requests", timeout)
+}
+
+// run stops Ollama once it has been idle for the timeout
+func (m *idleShutdown) run() {
+	interval := m.timeout / 10
+	if interval < time.Second {
+		interval = time.Second
+	} else if interval > time.Minute {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+			m.mu.Lock()
+			if !m.stopped.Load() && m.active == 0 && time.Since(m.lastRequest) >= m.timeout {
+				LogPrintf("No requests for %s, stopping Ollama to free GPU memory", m.timeout)
+				m.stop()
+				m.stopped.Store(true)
+				m.metrics.idleShutdowns.Inc()
+			}
+			m.mu.Unlock()
+		}
+	}
+}
+
+// acquire marks a request as using Ollama, starting it first if it was
+// stopped for being idle. Returns how long the cold start took (0 if none).
+// A nil idleShutdown (the feature disabled) does nothing.
+func (m *idleShutdown) acquire(requestID string) (time.Duration, error) {
+	if m == nil {
+		return 0, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastRequest = time.Now()
+	var coldStart time.Duration
+	if m.stopped.Load() {
+		log.Printf("[%s] Ollama was stopped for being idle, starting it", requestID)
+		start := time.Now()
+		if err := m.start(); err != nil {
+			slog.Error("Ollama cold start failed", "request_id", requestID, "error", err)
+			return 0, err
+		}
+		m.stopped.Store(false)
+		coldStart = time.Since(start)
+		m.metrics.coldStartSeconds.Observe(coldStart.Seconds())
+		slog.Info("Ollama cold start complete", "request_id", requestID, "duration", coldStart.Seconds())
+	}
+	m.active++
+	return coldStart, nil
+}
+
+// exclusive runs fn, which replaces the Ollama process, under the lock held
+// by idle stops and cold starts so they never race with it. fn is skipped
+// and false returned while Ollama is stopped for being idle; the next
+// request starts it. A nil idleShutdown just runs fn.
+func (m *idleShutdown) exclusive(fn func()) bool {
+	if m == nil {
+		fn()
+		return true
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.stopped.Load() {
+		return false
+	}
+	fn()
+	return true
+}
+
+// release marks a request as finished with Ollama
+func (m *idleShutdown) release() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.active--
+	m.lastRequest = time.Now()
+}
+
+// isStopped reports whether Ollama is currently stopped for being idle.
+// Safe to call on a nil idleShutdown.
+func (m *idleShutdown) isStopped() bool {
+	return m != nil && m.stopped.Load()
+}
+
+// close stops the idle timer
+func (m *idleShutdown) close() {
+	if m != nil {
+		close(m.done)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import "strings"
+
+// imageStats summarizes the images attached to a request. The image data
+// itself is never kept.
+type imageStats struct {
+	Count int // Number of images
+	Bytes int // Total decoded size in bytes
+}
+
+// requestImages counts the base64 images in a request: the "images" array of
+// /api/generate, and the per-message "images" arrays of /api/chat
+func requestImages(data map[string]interface{}) imageStats {
+	var stats imageStats
+	stats.add(data["images"])
+	if messages, ok := data["messages"].([]interface{}); ok {
+		for _, m := range messages {
+			if message, ok := m.(map[string]interface{}); ok {
+				stats.add(message["images"])
+			}
+		}
+	}
+	return stats
+}
+
+// add counts the images in a JSON "images" array
+func (s *imageStats) add(images interface{}) {
+	list, ok := images.([]interface{})
+	if !ok {
+		return
+	}
+	for _, image := range list {
+		if encoded, ok := image.(string); ok && encoded != "" {
+			s.Count++
+			s.Bytes += base64DecodedLen(encoded)
+		}
+	}
+}
+
+// base64DecodedLen returns the decoded size of base64 data without decoding
+// it. A data: URL prefix, padding and line breaks are ignored.
+func base64DecodedLen(encoded string) int {
+	if strings.HasPrefix(encoded, "data:") {
+		if _, data, ok := strings.Cut(encoded, ","); ok {
+			encoded = data
+		}
+	}
+	encoded = strings.TrimRight(strings.TrimSpace(encoded), "=")
+	length := len(encoded) - strings.Count(encoded, "\n") - strings.Count(encoded, "\r")
+	return length * 3 / 4
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// serviceEnvPrefixes selects which environment variables are carried into the service definition
+var serviceEnvPrefixes = []string{
+	"OLLAMA_", "PROXY_", "ANALYTICS_", "STORE_", "MAX_",
+	"ADMIN_API_KEY", "COST_CONFIG", "TRACK_EMBEDDINGS", "CATEGORIZER_CONFIG", "TRUST_FORWARDED_FOR",
+	"MODEL_LIST_TTL", "MODEL_ALIASES", "PROMPT_PREVIEW_LEN", "RESPONSE_PREVIEW_LEN", "RECENT_REQUESTS_SIZE", "LOG_FORMAT", "SAMPLE_HOST_METRICS",
+	"REQUEST_TIMEOUT", "STREAM_REQUEST_TIMEOUT", "STREAM_REQUEST_BODY_BYTES", "STREAM_FLUSH_INTERVAL", "STREAM_TOKEN_UPDATE_CHUNKS", "IDLE_SHUTDOWN_TIMEOUT", "SINGLEFLIGHT_FORCE", "RESPONSE_CACHE_", "CACHE_ALL", "PRESERVE_HOST", "SLOW_OUTLIER_", "TAGS_CACHE_TTL", "USER_HEADER", "TAG_HEADER", "PER_IP_", "MANAGE_OLLAMA", "ENABLE_", "TOOL_CALL_CATEGORY",
+	"DEFAULT_OPTIONS", "FORCE_OPTIONS", "AUDIT_LOG_DIR", "WARMUP_", "PUSHGATEWAY_URL", "PUSH_",
+}
+
+// serviceEnvironment collects proxy configuration from the current environment
+// so the installed service behaves like the shell that installed it
+func serviceEnvironment() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || configFileEnv[key] {
+			continue
+		}
+		for _, prefix := range serviceEnvPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				env[key] = value
+				break
+			}
+		}
+	}
+
+	// Services start with a minimal PATH, so pin the Ollama location now
+	if _, ok := env["OLLAMA_EXECUTABLE_PATH"]; !ok {
+		if ollamaPath, err := findOllamaExecutable(); err == nil {
+			env["OLLAMA_EXECUTABLE_PATH"] = ollamaPath
+		}
+	}
+	if path := os.Getenv("PATH"); path != "" {
+		env["PATH"] = path
+	}
+	return env
+}
+
+// sortedKeys returns map keys in a stable order for reproducible service files
+func sortedKeys(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
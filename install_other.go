@@ -0,0 +1,161 @@
+//go:build !windows
+
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	systemdUnitPath = "/etc/systemd/system/ollama-proxy.service"
+	launchdLabel    = "com.ollama.metrics-proxy"
+	launchdPlist    = "/Library/LaunchDaemons/" + launchdLabel + ".plist"
+)
+
+// installService installs the proxy as a systemd unit (Linux) or launchd daemon (macOS)
+func installService() error {
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("installing a service requires root privileges (try: sudo %s install-service)", os.Args[0])
+	}
+
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to determine executable path: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exePath); err == nil {
+		exePath = resolved
+	}
+
+	env := serviceEnvironment()
+
+	switch runtime.GOOS {
+	case "linux":
+		return installSystemdService(exePath, env)
+	case "darwin":
+		return installLaunchdService(exePath, env)
+	default:
+		return fmt.Errorf("service installation is not supported on %s", runtime.GOOS)
+	}
+}
+
+// uninstallService removes the systemd unit (Linux) or launchd daemon (macOS)
+func uninstallService() error {
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("uninstalling a service requires root privileges (try: sudo %s uninstall-service)", os.Args[0])
+	}
+
+	switch runtime.GOOS {
+	case "linux":
+		// Stop and disable, ignoring errors if the unit isn't running
+		exec.Command("systemctl", "disable", "--now", "ollama-proxy.service").Run()
+		if err := os.Remove(systemdUnitPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", systemdUnitPath, err)
+		}
+		if output, err := exec.Command("systemctl", "daemon-reload").CombinedOutput(); err != nil {
+			return fmt.Errorf("systemctl daemon-reload failed: %w - %s", err, output)
+		}
+		fmt.Printf("Removed systemd unit %s\n", systemdUnitPath)
+	case "darwin":
+		exec.Command("launchctl", "unload", "-w", launchdPlist).Run()
+		if err := os.Remove(launchdPlist); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", launchdPlist, err)
+		}
+		fmt.Printf("Removed launchd daemon %s\n", launchdPlist)
+	default:
+		return fmt.Errorf("service installation is not supported on %s", runtime.GOOS)
+	}
+	return nil
+}
+
+// installSystemdService writes the systemd unit and reloads systemd
+func installSystemdService(exePath string, env map[string]string) error {
+	var unit strings.Builder
+	unit.WriteString("[Unit]\n")
+	unit.WriteString("Description=Ollama Metrics Proxy\n")
+	unit.WriteString("After=network-online.target\n")
+	unit.WriteString("Wants=network-online.target\n\n")
+	unit.WriteString("[Service]\n")
+	unit.WriteString("Type=simple\n")
+	fmt.Fprintf(&unit, "ExecStart=%s serve\n", systemdQuote(exePath))
+	unit.WriteString("ExecReload=/bin/kill -HUP $MAINPID\n")
+	fmt.Fprintf(&unit, "WorkingDirectory=%s\n", filepath.Dir(exePath))
+	for _, key := range sortedKeys(env) {
+		fmt.Fprintf(&unit, "Environment=%s\n", systemdQuote(key+"="+env[key]))
+	}
+	unit.WriteString("Restart=on-failure\n")
+	unit.WriteString("RestartSec=5\n")
+	unit.WriteString("KillMode=mixed\n")
+	unit.WriteString("TimeoutStopSec=30\n\n")
+	unit.WriteString("[Install]\n")
+	unit.WriteString("WantedBy=multi-user.target\n")
+
+	if err := os.WriteFile(systemdUnitPath, []byte(unit.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", systemdUnitPath, err)
+	}
+	if output, err := exec.Command("systemctl", "daemon-reload").CombinedOutput(); err != nil {
+		return fmt.Errorf("systemctl daemon-reload failed: %w - %s", err, output)
+	}
+
+	fmt.Printf("Installed systemd unit %s\n", systemdUnitPath)
+	fmt.Println("Start it with: sudo systemctl enable --now ollama-proxy")
+	return nil
+}
+
+// systemdQuote quotes a value for use in a systemd unit file
+func systemdQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	value = strings.ReplaceAll(value, "%", "%%")
+	return `"` + value + `"`
+}
+
+// installLaunchdService writes the launchd plist and loads it
+func installLaunchdService(exePath string, env map[string]string) error {
+	logDir := "/Library/Logs/OllamaProxy"
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	var plist strings.Builder
+	plist.WriteString(xml.Header)
+	plist.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	plist.WriteString("<plist version=\"1.0\">\n<dict>\n")
+	fmt.Fprintf(&plist, "\t<key>Label</key>\n\t<string>%s</string>\n", launchdLabel)
+	plist.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+	fmt.Fprintf(&plist, "\t\t<string>%s</string>\n\t\t<string>serve</string>\n", xmlEscape(exePath))
+	plist.WriteString("\t</array>\n")
+	fmt.Fprintf(&plist, "\t<key>WorkingDirectory</key>\n\t<string>%s</string>\n", xmlEscape(filepath.Dir(exePath)))
+	plist.WriteString("\t<key>EnvironmentVariables</key>\n\t<dict>\n")
+	for _, key := range sortedKeys(env) {
+		fmt.Fprintf(&plist, "\t\t<key>%s</key>\n\t\t<string>%s</string>\n", xmlEscape(key), xmlEscape(env[key]))
+	}
+	plist.WriteString("\t</dict>\n")
+	plist.WriteString("\t<key>RunAtLoad</key>\n\t<true/>\n")
+	plist.WriteString("\t<key>KeepAlive</key>\n\t<dict>\n\t\t<key>SuccessfulExit</key>\n\t\t<false/>\n\t</dict>\n")
+	fmt.Fprintf(&plist, "\t<key>StandardOutPath</key>\n\t<string>%s/ollama-proxy.log</string>\n", logDir)
+	fmt.Fprintf(&plist, "\t<key>StandardErrorPath</key>\n\t<string>%s/ollama-proxy.err.log</string>\n", logDir)
+	plist.WriteString("</dict>\n</plist>\n")
+
+	if err := os.WriteFile(launchdPlist, []byte(plist.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", launchdPlist, err)
+	}
+	if output, err := exec.Command("launchctl", "load", "-w", launchdPlist).CombinedOutput(); err != nil {
+		return fmt.Errorf("launchctl load failed: %w - %s", err, output)
+	}
+
+	fmt.Printf("Installed launchd daemon %s\n", launchdPlist)
+	return nil
+}
+
+// xmlEscape escapes a value for inclusion in a plist
+func xmlEscape(value string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
@@ -0,0 +1,183 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+const (
+	windowsServiceName        = "OllamaMetricsProxy"
+	windowsServiceDisplayName = "Ollama Metrics Proxy"
+	windowsServiceDescription = "Transparent metrics proxy for Ollama with Prometheus monitoring and analytics"
+	serviceStopTimeout        = 30 * time.Second
+)
+
+// installService registers the proxy with the Service Control Manager, configures
+// restart-on-failure recovery and creates the event log source
+func installService() error {
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to determine executable path: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exePath); err == nil {
+		exePath = resolved
+	}
+
+	m, err := connectServiceManager()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(windowsServiceName); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s already exists (run: %s uninstall-service)", windowsServiceName, os.Args[0])
+	}
+
+	s, err := m.CreateService(windowsServiceName, exePath, mgr.Config{
+		DisplayName:      windowsServiceDisplayName,
+		Description:      windowsServiceDescription,
+		StartType:        mgr.StartAutomatic,
+		DelayedAutoStart: true,
+	}, "-service")
+	if err != nil {
+		return fmt.Errorf("failed to create service: %w", err)
+	}
+	defer s.Close()
+
+	// Restart after 5s, 10s, then 30s; the failure count resets after a day
+	recovery := []mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 10 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 30 * time.Second},
+	}
+	if err := s.SetRecoveryActions(recovery, uint32((24 * time.Hour).Seconds())); err != nil {
+		s.Delete()
+		return fmt.Errorf("failed to configure service recovery: %w", err)
+	}
+
+	env := serviceEnvironment()
+	// LocalSystem has its own PATH; copying the installing user's could break it
+	delete(env, "PATH")
+	if err := setServiceEnvironment(env); err != nil {
+		s.Delete()
+		return err
+	}
+
+	if err := eventlog.InstallAsEventCreate(windowsServiceName, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		// Usually means the source is left over from a previous install
+		fmt.Printf("Warning: could not register event log source: %v\n", err)
+	}
+
+	fmt.Printf("Installed service %s (%s -service)\n", windowsServiceName, exePath)
+	for _, key := range sortedKeys(env) {
+		fmt.Printf("  %s=%s\n", key, env[key])
+	}
+
+	if err := s.Start(); err != nil {
+		return fmt.Errorf("service installed but failed to start: %w", err)
+	}
+	fmt.Println("Service started. The proxy is available at http://localhost:11434")
+	return nil
+}
+
+// uninstallService stops and removes the service and its event log source
+func uninstallService() error {
+	m, err := connectServiceManager()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(windowsServiceName)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", windowsServiceName)
+	}
+	defer s.Close()
+
+	if err := stopService(s); err != nil {
+		return err
+	}
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("failed to delete service: %w", err)
+	}
+	if err := eventlog.Remove(windowsServiceName); err != nil {
+		fmt.Printf("Warning: could not remove event log source: %v\n", err)
+	}
+
+	fmt.Printf("Removed service %s\n", windowsServiceName)
+	return nil
+}
+
+// connectServiceManager opens the Service Control Manager, which requires an elevated prompt
+func connectServiceManager() (*mgr.Mgr, error) {
+	m, err := mgr.Connect()
+	if err != nil {
+		if errors.Is(err, windows.ERROR_ACCESS_DENIED) {
+			return nil, fmt.Errorf("access denied: run this command from an Administrator prompt")
+		}
+		return nil, fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	return m, nil
+}
+
+// stopService asks a running service to stop and waits for it to exit
+func stopService(s *mgr.Service) error {
+	status, err := s.Query()
+	if err != nil {
+		return fmt.Errorf("failed to query service: %w", err)
+	}
+	if status.State == svc.Stopped {
+		return nil
+	}
+
+	fmt.Printf("Stopping service %s...\n", windowsServiceName)
+	if status.State != svc.StopPending {
+		if status, err = s.Control(svc.Stop); err != nil {
+			return fmt.Errorf("failed to stop service: %w", err)
+		}
+	}
+
+	deadline := time.Now().Add(serviceStopTimeout)
+	for status.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("service did not stop within %s", serviceStopTimeout)
+		}
+		time.Sleep(500 * time.Millisecond)
+		if status, err = s.Query(); err != nil {
+			return fmt.Errorf("failed to query service: %w", err)
+		}
+	}
+	return nil
+}
+
+// setServiceEnvironment stores the service's environment block in the registry,
+// which the Service Control Manager applies when starting the process
+func setServiceEnvironment(env map[string]string) error {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE,
+		`SYSTEM\CurrentControlSet\Services\`+windowsServiceName, registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("failed to open service registry key: %w", err)
+	}
+	defer key.Close()
+
+	values := make([]string, 0, len(env))
+	for _, name := range sortedKeys(env) {
+		values = append(values, name+"="+env[name])
+	}
+	if err := key.SetStringsValue("Environment", values); err != nil {
+		return fmt.Errorf("failed to set service environment: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ipFilter restricts proxied requests by client IP address
+type ipFilter struct {
+	allow             []*net.IPNet // Empty = all addresses allowed
+	deny              []*net.IPNet // Checked first, deny wins
+	trustForwardedFor bool         // Use X-Forwarded-For instead of the connection address
+}
+
+// loadIPFilter parses PROXY_ALLOW_CIDRS, PROXY_DENY_CIDRS and TRUST_FORWARDED_FOR
+func loadIPFilter() *ipFilter {
+	f := &ipFilter{
+		allow:             parseCIDRList("PROXY_ALLOW_CIDRS"),
+		deny:              parseCIDRList("PROXY_DENY_CIDRS"),
+		trustForwardedFor: strings.EqualFold(strings.TrimSpace(os.Getenv("TRUST_FORWARDED_FOR")), "true"),
+	}
+	if len(f.allow) > 0 || len(f.deny) > 0 {
+		log.Printf("IP filtering enabled (%d allowed, %d denied ranges, trust X-Forwarded-For: %v)",
+			len(f.allow), len(f.deny), f.trustForwardedFor)
+	}
+	return f
+}
+
+// parseCIDRList parses a comma-separated list of CIDRs or bare IPs from an
+// environment variable. Invalid entries are logged and skipped.
+func parseCIDRList(name string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, entry := range strings.Split(os.Getenv(name), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil {
+				bits := 128
+				if ip.To4() != nil {
+					bits = 32
+				}
+				nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Printf("Warning: Ignoring invalid entry %q in %s: %v", entry, name, err)
+			continue
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+// enabled reports whether any allow or deny ranges are configured
+func (f *ipFilter) enabled() bool {
+	return len(f.allow) > 0 || len(f.deny) > 0
+}
+
+// clientIP returns the address the filter should check. X-Forwarded-For is
+// only used when trusted, and then only its right-most entry: that one was
+// added by the proxy in front of us, while earlier entries are client-supplied.
+func (f *ipFilter) clientIP(r *http.Request) net.IP {
+	if f.trustForwardedFor {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			parts := strings.Split(xff, ",")
+			if ip := net.ParseIP(strings.TrimSpace(parts[len(parts)-1])); ip != nil {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// allowed applies the deny list, then the allow list (if any)
+func (f *ipFilter) allowed(ip net.IP) bool {
+	if ip == nil {
+		// Unparseable address: only allow when no allow list is configured
+		return len(f.allow) == 0
+	}
+	for _, n := range f.deny {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	if len(f.allow) == 0 {
+		return true
+	}
+	for _, n := range f.allow {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkClientIP rejects requests from filtered addresses with a 403.
+// Returns false when the request was rejected.
+func (p *Proxy) checkClientIP(w http.ResponseWriter, r *http.Request) bool {
+	if p.ipFilter == nil || !p.ipFilter.enabled() {
+		return true
+	}
+
+	ip := p.ipFilter.clientIP(r)
+	if p.ipFilter.allowed(ip) {
+		return true
+	}
+
+	log.Printf("[%s] Rejected request from filtered IP %v: %s %s", r.RemoteAddr, ip, r.Method, r.URL.Path)
+	p.metrics.deniedRequests.Inc()
+	http.Error(w, "Forbidden", http.StatusForbidden)
+	return false
+}
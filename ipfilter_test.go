@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIPFilter(t *testing.T) {
+	tests := []struct {
+		name         string
+		allow        string
+		deny         string
+		trustXFF     string
+		remoteAddr   string
+		forwardedFor string
+		wantAllowed  bool
+		wantClientIP string
+	}{
+		{name: "no lists", remoteAddr: "203.0.113.5:4000", wantAllowed: true, wantClientIP: "203.0.113.5"},
+		{name: "allowed range", allow: "10.0.0.0/8", remoteAddr: "10.1.2.3:4000", wantAllowed: true, wantClientIP: "10.1.2.3"},
+		{name: "outside allowed range", allow: "10.0.0.0/8", remoteAddr: "192.168.1.5:4000", wantAllowed: false, wantClientIP: "192.168.1.5"},
+		{name: "bare IP allow entry", allow: "192.168.1.5", remoteAddr: "192.168.1.5:4000", wantAllowed: true, wantClientIP: "192.168.1.5"},
+		{name: "denied range", deny: "192.168.0.0/16", remoteAddr: "192.168.1.5:4000", wantAllowed: false, wantClientIP: "192.168.1.5"},
+		{name: "deny wins over allow", allow: "10.0.0.0/8", deny: "10.0.0.0/24", remoteAddr: "10.0.0.7:4000", wantAllowed: false, wantClientIP: "10.0.0.7"},
+		{name: "allowed next to denied subnet", allow: "10.0.0.0/8", deny: "10.0.0.0/24", remoteAddr: "10.0.1.7:4000", wantAllowed: true, wantClientIP: "10.0.1.7"},
+		{name: "IPv6 allowed", allow: "2001:db8::/32", remoteAddr: "[2001:db8::1]:4000", wantAllowed: true, wantClientIP: "2001:db8::1"},
+		{name: "IPv6 denied", deny: "2001:db8::1", remoteAddr: "[2001:db8::1]:4000", wantAllowed: false, wantClientIP: "2001:db8::1"},
+		{name: "IPv6 outside IPv4 allow list", allow: "10.0.0.0/8", remoteAddr: "[2001:db8::1]:4000", wantAllowed: false, wantClientIP: "2001:db8::1"},
+		{name: "untrusted X-Forwarded-For is ignored", allow: "10.0.0.0/8", remoteAddr: "203.0.113.5:4000", forwardedFor: "10.0.0.1", wantAllowed: false, wantClientIP: "203.0.113.5"},
+		{name: "trusted X-Forwarded-For", allow: "10.0.0.0/8", trustXFF: "true", remoteAddr: "127.0.0.1:4000", forwardedFor: "10.0.0.1", wantAllowed: true, wantClientIP: "10.0.0.1"},
+		{name: "trusted X-Forwarded-For denied", deny: "203.0.113.0/24", trustXFF: "true", remoteAddr: "127.0.0.1:4000", forwardedFor: "203.0.113.9", wantAllowed: false, wantClientIP: "203.0.113.9"},
+		{name: "spoofed leftmost entry", allow: "10.0.0.0/8", trustXFF: "true", remoteAddr: "127.0.0.1:4000", forwardedFor: "10.0.0.1, 203.0.113.5", wantAllowed: false, wantClientIP: "203.0.113.5"},
+		{name: "spoofed leftmost entry can't dodge deny", deny: "203.0.113.0/24", trustXFF: "true", remoteAddr: "127.0.0.1:4000", forwardedFor: "10.0.0.1,203.0.113.5", wantAllowed: false, wantClientIP: "203.0.113.5"},
+		{name: "unparseable X-Forwarded-For falls back to the connection", allow: "127.0.0.0/8", trustXFF: "true", remoteAddr: "127.0.0.1:4000", forwardedFor: "unknown", wantAllowed: true, wantClientIP: "127.0.0.1"},
+		{name: "unparseable address with allow list", allow: "10.0.0.0/8", remoteAddr: "@", wantAllowed: false},
+		{name: "unparseable address with only deny list", deny: "10.0.0.0/8", remoteAddr: "@", wantAllowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PROXY_ALLOW_CIDRS", tt.allow)
+			t.Setenv("PROXY_DENY_CIDRS", tt.deny)
+			t.Setenv("TRUST_FORWARDED_FOR", tt.trustXFF)
+			f := loadIPFilter()
+
+			req := httptest.NewRequest(http.MethodPost, "/api/generate", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+
+			ip := f.clientIP(req)
+			gotIP := ""
+			if ip != nil {
+				gotIP = ip.String()
+			}
+			if gotIP != tt.wantClientIP {
+				t.Errorf("client IP = %q, want %q", gotIP, tt.wantClientIP)
+			}
+			if got := f.allowed(ip); got != tt.wantAllowed {
+				t.Errorf("allowed = %v, want %v", got, tt.wantAllowed)
+			}
+		})
+	}
+}
+
+func TestCheckClientIPRejectsWithForbidden(t *testing.T) {
+	t.Setenv("PROXY_DENY_CIDRS", "192.0.2.0/24")
+	p := newTestProxy(t, "http://127.0.0.1:0")
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/generate", nil)
+	req.RemoteAddr = "192.0.2.1:4000"
+	if p.checkClientIP(rec, req) {
+		t.Fatal("denied client was allowed")
+	}
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", rec.Code)
+	}
+}
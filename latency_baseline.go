@@ -0,0 +1,112 @@
+package main
+
+import (
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	DefaultSlowOutlierStdDevs = 3.0 // Standard deviations above the mean that count as slow
+	DefaultSlowOutlierWindow  = 100 // Recent requests per model in the baseline
+	slowOutlierMinSamples     = 20  // Requests a model needs before anything is flagged
+)
+
+// latencyBaselines keeps a rolling window of successful request durations per
+// model and flags requests far slower than that model's recent mean
+type latencyBaselines struct {
+	mu      sync.Mutex
+	models  map[string]*latencyWindow
+	window  int
+	stddevs float64 // 0 disables detection
+}
+
+// latencyWindow is a ring buffer of durations in seconds
+type latencyWindow struct {
+	samples []float64
+	next    int
+	full    bool
+}
+
+// newLatencyBaselines reads SLOW_OUTLIER_STDDEVS and SLOW_OUTLIER_WINDOW
+func newLatencyBaselines() *latencyBaselines {
+	window := getEnvInt("SLOW_OUTLIER_WINDOW", DefaultSlowOutlierWindow)
+	if window < slowOutlierMinSamples {
+		log.Printf("Warning: SLOW_OUTLIER_WINDOW must be at least %d, using %d", slowOutlierMinSamples, slowOutlierMinSamples)
+		window = slowOutlierMinSamples
+	}
+	return &latencyBaselines{
+		models:  make(map[string]*latencyWindow),
+		window:  window,
+		stddevs: getSlowOutlierStdDevs(),
+	}
+}
+
+// getSlowOutlierStdDevs reads SLOW_OUTLIER_STDDEVS (default 3, 0 disables)
+func getSlowOutlierStdDevs() float64 {
+	value := strings.TrimSpace(os.Getenv("SLOW_OUTLIER_STDDEVS"))
+	if value == "" {
+		return DefaultSlowOutlierStdDevs
+	}
+	stddevs, err := strconv.ParseFloat(value, 64)
+	if err != nil || stddevs < 0 || math.IsNaN(stddevs) || math.IsInf(stddevs, 0) {
+		log.Printf("Warning: Invalid value for SLOW_OUTLIER_STDDEVS: %q, using %g", value, DefaultSlowOutlierStdDevs)
+		return DefaultSlowOutlierStdDevs
+	}
+	return stddevs
+}
+
+// observe reports whether duration is a slow outlier against the model's
+// baseline, then adds it to the baseline so it adapts to lasting shifts
+func (b *latencyBaselines) observe(model string, duration float64) bool {
+	if b.stddevs == 0 {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	w, ok := b.models[model]
+	if !ok {
+		w = &latencyWindow{samples: make([]float64, b.window)}
+		b.models[model] = w
+	}
+
+	outlier := false
+	if mean, stddev, n := w.stats(); n >= slowOutlierMinSamples && stddev > 0 {
+		outlier = duration > mean+b.stddevs*stddev
+	}
+
+	w.samples[w.next] = duration
+	w.next = (w.next + 1) % len(w.samples)
+	if w.next == 0 {
+		w.full = true
+	}
+	return outlier
+}
+
+// stats returns the mean and population standard deviation of the window
+func (w *latencyWindow) stats() (mean, stddev float64, n int) {
+	n = w.next
+	if w.full {
+		n = len(w.samples)
+	}
+	if n == 0 {
+		return 0, 0, 0
+	}
+
+	var sum float64
+	for _, s := range w.samples[:n] {
+		sum += s
+	}
+	mean = sum / float64(n)
+
+	var variance float64
+	for _, s := range w.samples[:n] {
+		variance += (s - mean) * (s - mean)
+	}
+	return mean, math.Sqrt(variance / float64(n)), n
+}
@@ -2,26 +2,38 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
+// jsonLogging reports whether LOG_FORMAT=json was requested
+func jsonLogging() bool {
+	return strings.EqualFold(strings.TrimSpace(os.Getenv("LOG_FORMAT")), "json")
+}
+
+// newLogHandler returns the slog handler selected by LOG_FORMAT (text by default)
+func newLogHandler(w io.Writer) slog.Handler {
+	opts := &slog.HandlerOptions{Level: slog.LevelInfo}
+	if jsonLogging() {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
 // serviceLogger is the internal logger instance
 
 // InitServiceLogging sets up file-based logging when running as a Windows service
 func InitServiceLogging() error {
-	// Always use ProgramData for service mode
-	programData := os.Getenv("ProgramData")
-	if programData == "" {
-		programData = "C:\\ProgramData"
-	}
-	logDir := filepath.Join(programData, "OllamaProxy", "logs")
+	logDir := getServiceLogDir()
 	
-	// Ensure log directory exists
-	if err := os.MkdirAll(logDir, 0755); err != nil {
-		return fmt.Errorf("failed to create log directory: %w", err)
+	// Ensure log directory exists and is writable
+	if err := ensureWritableDir(logDir); err != nil {
+		return fmt.Errorf("failed to create log directory %s: %w", logDir, err)
 	}
 	
 	// Create log file with timestamp
@@ -36,9 +48,15 @@ func InitServiceLogging() error {
 	// Create logger and assign to global ServiceLogger
 	ServiceLogger = log.New(f, "", log.Ldate|log.Ltime|log.Lmicroseconds|log.Lshortfile)
 	
-	// Redirect standard log output to file as well
-	log.SetOutput(f)
-	log.SetFlags(log.Ldate | log.Ltime | log.Lmicroseconds | log.Lshortfile)
+	// Send structured logs to the file. SetDefault also routes the standard
+	// log package through slog, which is what we want for JSON output.
+	slog.SetDefault(slog.New(newLogHandler(f)))
+
+	// In text mode keep the standard log output in its usual format
+	if !jsonLogging() {
+		log.SetOutput(f)
+		log.SetFlags(log.Ldate | log.Ltime | log.Lmicroseconds | log.Lshortfile)
+	}
 	
 	ServiceLogger.Printf("=== Service logging initialized ===")
 	ServiceLogger.Printf("Log file: %s", logFile)
@@ -48,6 +66,19 @@ func InitServiceLogging() error {
 	return nil
 }
 
+// getServiceLogDir returns the service log directory: a logs folder inside
+// ANALYTICS_DIR when set, otherwise under ProgramData
+func getServiceLogDir() string {
+	if dir := strings.TrimSpace(os.Getenv("ANALYTICS_DIR")); dir != "" {
+		return filepath.Join(dir, "logs")
+	}
+	programData := os.Getenv("ProgramData")
+	if programData == "" {
+		programData = "C:\\ProgramData"
+	}
+	return filepath.Join(programData, "OllamaProxy", "logs")
+}
+
 func getCurrentWorkingDir() string {
 	if wd, err := os.Getwd(); err == nil {
 		return wd
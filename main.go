@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -27,43 +28,64 @@ var (
 
 // getOllamaPort returns the configured Ollama backend port
 func getOllamaPort() int {
-	if port := os.Getenv("OLLAMA_BACKEND_PORT"); port != "" {
-		if p, err := fmt.Sscanf(port, "%d", new(int)); err == nil && p == 1 {
-			var portNum int
-			fmt.Sscanf(port, "%d", &portNum)
-			if portNum > 0 && portNum <= 65535 {
-				return portNum
-			}
-		}
-	}
-	return DefaultOllamaPort
+	return appConfig.OllamaBackendPort
 }
 
 // getProxyPort returns the configured proxy frontend port
 func getProxyPort() int {
-	if port := os.Getenv("PROXY_PORT"); port != "" {
-		if p, err := fmt.Sscanf(port, "%d", new(int)); err == nil && p == 1 {
-			var portNum int
-			fmt.Sscanf(port, "%d", &portNum)
-			if portNum > 0 && portNum <= 65535 {
-				return portNum
-			}
+	return appConfig.ProxyPort
+}
+
+// getProxyBindAddr returns the interface address the proxy listens on.
+// Empty (the default) listens on all interfaces.
+func getProxyBindAddr() string {
+	return appConfig.ProxyBindAddr
+}
+
+// getOllamaBindAddr returns the interface address the managed Ollama listens on
+func getOllamaBindAddr() string {
+	return appConfig.OllamaBindAddr
+}
+
+// getEnvInt returns the integer value of an environment variable, or def if unset or invalid
+func getEnvInt(name string, def int) int {
+	if value := os.Getenv(name); value != "" {
+		if parsed, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+			return parsed
+		}
+		log.Printf("Warning: Invalid value for %s: %q, using default %d", name, value, def)
+	}
+	return def
+}
+
+// getEnvDuration returns the duration value of an environment variable, or def if unset or invalid
+func getEnvDuration(name string, def time.Duration) time.Duration {
+	if value := os.Getenv(name); value != "" {
+		if parsed, err := time.ParseDuration(strings.TrimSpace(value)); err == nil && parsed > 0 {
+			return parsed
 		}
+		log.Printf("Warning: Invalid value for %s: %q, using default %s", name, value, def)
 	}
-	return DefaultProxyPort
+	return def
 }
 
 func main() {
-	// Initialize structured logging
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
-	}))
-	slog.SetDefault(logger)
+	// Initialize structured logging (LOG_FORMAT=json for JSON output)
+	slog.SetDefault(slog.New(newLogHandler(os.Stdout)))
 
 	// Check if running as Windows service first
 	serviceFlag := flag.Bool("service", false, "Run as Windows service")
+	noManageFlag := flag.Bool("no-manage", false, "Don't start or kill Ollama; proxy an instance managed elsewhere")
+	configFlag := flag.String("config", os.Getenv("PROXY_CONFIG"), "Path to a YAML or TOML config file (env vars override it)")
 	flag.Parse()
 
+	if *noManageFlag {
+		os.Setenv("MANAGE_OLLAMA", "false")
+	}
+	if err := loadConfig(*configFlag); err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+
 	if *serviceFlag {
 		runAsService()
 		return
@@ -83,6 +105,25 @@ func main() {
 		}
 	}
 
+	// Subcommands handled by the proxy itself
+	switch command {
+	case "install-service":
+		if err := installService(); err != nil {
+			log.Fatalf("Error installing service: %v", err)
+		}
+		return
+	case "uninstall-service":
+		if err := uninstallService(); err != nil {
+			log.Fatalf("Error uninstalling service: %v", err)
+		}
+		return
+	case "logs-tail":
+		if err := tailAnalytics(args); err != nil {
+			log.Fatalf("Error tailing analytics: %v", err)
+		}
+		return
+	}
+
 	// Check if this is a proxy command (serve), otherwise passthrough
 	if !isProxyCommand(command) {
 		exitCode := runPassthroughCommand(command, args)
@@ -98,47 +139,70 @@ func main() {
 		log.Fatalf("Error: Port %d is already in use (existing Ollama or proxy?)\nStop the existing process or use a different port", proxyPort)
 	}
 
-	if isPortOpen("localhost", ollamaPort) {
-		log.Fatalf("Error: Port %d is already in use", ollamaPort)
-	}
-
-	printBanner(ollamaPort, proxyPort)
-
-	// Find ollama executable
-	ollamaPath, err := findOllamaExecutable()
-	if err != nil {
-		log.Fatalf("Error finding Ollama: %v", err)
-	}
-
 	// Set up signal handling
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
-	// Kill any existing Ollama processes
-	if err := killExistingOllama(); err != nil {
-		log.Printf("Warning: Failed to kill existing Ollama: %v", err)
-		// Continue anyway, it might work
-	}
+	targetURL := fmt.Sprintf("http://localhost:%d", ollamaPort)
+	var ollamaPath string
+	var ollamaProcess *OllamaProcess
 
-	// Start Ollama process
-	ollamaProcess, err := startOllama(ollamaPath, ollamaPort)
-	if err != nil {
-		log.Fatalf("Failed to start Ollama: %v", err)
-	}
-	defer func() {
-		if ollamaProcess != nil {
-			ollamaProcess.Stop()
+	if remoteURL := getExternalTargetURL(); remoteURL != "" {
+		// Remote or unmanaged mode: Ollama runs elsewhere, only provide the metrics layer
+		targetURL = remoteURL
+		printRemoteBanner(remoteURL, proxyPort)
+
+		if err := pingOllama(remoteURL, 5*time.Second); err != nil {
+			log.Printf("Warning: Ollama at %s is not reachable yet: %v", remoteURL, err)
+		} else {
+			fmt.Printf("[OK] Ollama is reachable at %s\n", remoteURL)
 		}
-	}()
+	} else {
+		if isPortOpen("localhost", ollamaPort) {
+			log.Fatalf("Error: Port %d is already in use", ollamaPort)
+		}
+
+		printBanner(ollamaPort, proxyPort)
+
+		// Find ollama executable
+		var err error
+		ollamaPath, err = findOllamaExecutable()
+		if err != nil {
+			log.Fatalf("Error finding Ollama: %v", err)
+		}
+
+		// Kill any existing Ollama processes
+		if err := killExistingOllama(); err != nil {
+			log.Printf("Warning: Failed to kill existing Ollama: %v", err)
+			// Continue anyway, it might work
+		}
+
+		// Start Ollama process
+		ollamaProcess, err = startOllama(ollamaPath, ollamaPort)
+		if err != nil {
+			log.Fatalf("Failed to start Ollama: %v", err)
+		}
+		defer func() {
+			if ollamaProcess != nil {
+				ollamaProcess.Stop()
+			}
+		}()
 
-	// Wait for Ollama to be ready
-	if !waitForOllama("localhost", ollamaPort, StartupTimeout) {
-		log.Fatal("Ollama failed to start")
+		// Wait for Ollama to be ready
+		if !waitForOllama("localhost", ollamaPort, StartupTimeout) {
+			log.Fatal("Ollama failed to start")
+		}
 	}
 
+	// Preload WARMUP_MODELS before clients can connect
+	warmupModels(targetURL)
+
 	// Start metrics proxy
-	proxy := NewProxy(fmt.Sprintf("http://localhost:%d", ollamaPort), proxyPort, false)
+	proxy := NewProxy(targetURL, proxyPort, false)
 	defer proxy.Shutdown()
+	if ollamaProcess != nil {
+		proxy.enableIdleShutdown(ollamaPath, ollamaPort, &ollamaProcess)
+	}
 
 	go func() {
 		if err := proxy.Start(); err != nil {
@@ -152,13 +216,26 @@ func main() {
 	printProxyReady(proxyPort)
 
 	// Handle specific commands if not "serve"
-	if command != "serve" && command != "start" {
+	if command != "serve" && command != "start" && ollamaPath != "" {
 		// Run the actual command (e.g., "run phi4")
 		runOllamaCommand(ollamaPath, command, args, proxyPort)
 	}
 
-	// Wait for interrupt signal
-	<-sigChan
+	// Wait for interrupt signal, reloading config files on SIGHUP
+	reloadChan := make(chan os.Signal, 1)
+	notifyReload(reloadChan)
+waitLoop:
+	for {
+		select {
+		case <-reloadChan:
+			log.Printf("Received SIGHUP, reloading configuration")
+			if err := proxy.reloadConfig(); err != nil {
+				log.Printf("Warning: Config reload incomplete: %v", err)
+			}
+		case <-sigChan:
+			break waitLoop
+		}
+	}
 	fmt.Println("\nShutting down...")
 }
 
@@ -168,6 +245,9 @@ func printUsage() {
 	fmt.Println("  ollama-proxy list")
 	fmt.Println("  ollama-proxy run phi4")
 	fmt.Println("  ollama-proxy serve  # Start with metrics proxy")
+	fmt.Println("  ollama-proxy install-service    # Install as a Windows/systemd/launchd service")
+	fmt.Println("  ollama-proxy uninstall-service  # Remove the service")
+	fmt.Println("  ollama-proxy logs-tail [-n 10] [-interval 1s] [-dir path]  # Follow analytics records as JSON lines")
 }
 
 func printBanner(ollamaPort, proxyPort int) {
@@ -179,6 +259,16 @@ func printBanner(ollamaPort, proxyPort int) {
 	fmt.Println(strings.Repeat("=", 60))
 }
 
+func printRemoteBanner(remoteURL string, proxyPort int) {
+	fmt.Println(strings.Repeat("=", 60))
+	fmt.Println("  Ollama Transparent Metrics Wrapper (Go Edition)")
+	fmt.Println(strings.Repeat("=", 60))
+	fmt.Printf("External Ollama: forwarding to %s\n", remoteURL)
+	fmt.Println("Local Ollama will not be started or managed")
+	fmt.Printf("Starting proxy on port %d (your apps connect here)\n", proxyPort)
+	fmt.Println(strings.Repeat("=", 60))
+}
+
 func printProxyReady(proxyPort int) {
 	fmt.Println("\n" + strings.Repeat("=", 60))
 	fmt.Println("✓ Metrics proxy is running!")
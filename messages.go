@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+)
+
+// DefaultFullMessagesMaxBytes caps the message history stored per request
+const DefaultFullMessagesMaxBytes = 64 * 1024
+
+// ChatMessage is one entry of a chat request's conversation history
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// loadStoreFullMessages reports whether STORE_FULL_MESSAGES asks for the
+// entire chat history to be kept in analytics metadata
+func loadStoreFullMessages() bool {
+	return strings.EqualFold(strings.TrimSpace(os.Getenv("STORE_FULL_MESSAGES")), "true")
+}
+
+// chatMessages extracts the role and text content of every message in a
+// chat request body (native /api/chat or OpenAI-compatible)
+func chatMessages(body []byte) []ChatMessage {
+	var data struct {
+		Messages []map[string]interface{} `json:"messages"`
+	}
+	if len(body) == 0 || json.Unmarshal(body, &data) != nil {
+		return nil
+	}
+
+	messages := make([]ChatMessage, 0, len(data.Messages))
+	for _, m := range data.Messages {
+		role, _ := m["role"].(string)
+		content, _ := messageContent(m)
+		messages = append(messages, ChatMessage{Role: role, Content: content})
+	}
+	return messages
+}
+
+// capMessages keeps the newest messages that fit in maxBytes of content, so the
+// turns closest to the request survive. It reports whether anything was cut.
+func capMessages(messages []ChatMessage, maxBytes int) ([]ChatMessage, bool) {
+	if maxBytes <= 0 {
+		return messages, false
+	}
+
+	size := 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		size += len(messages[i].Role) + len(messages[i].Content)
+		if size > maxBytes {
+			if i == len(messages)-1 {
+				// Even the latest message alone is too big: keep a truncated copy
+				last := messages[i]
+				last.Content = truncate(last.Content, maxBytes)
+				return []ChatMessage{last}, true
+			}
+			return messages[i+1:], true
+		}
+	}
+	return messages, false
+}
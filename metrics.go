@@ -1,9 +1,9 @@
 package main
 
 import (
-	"crypto/md5"
-	"fmt"
+	"log"
 	"net/http"
+	"os"
 	"regexp"
 	"strings"
 	"sync"
@@ -12,17 +12,48 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-const MaxPromptCategories = 50
+const (
+	DefaultMaxPromptCategories = 50      // First-word categories kept before falling back to OtherCategory
+	OtherCategory              = "other" // Category for prompts once the first-word limit is reached
+)
 
 // MetricsCollector handles Prometheus metrics collection
 type MetricsCollector struct {
-	requestDuration *prometheus.HistogramVec
-	tokensGenerated *prometheus.HistogramVec
-	tokensPerSecond *prometheus.HistogramVec
-	requestsTotal   *prometheus.CounterVec
-	activeRequests  prometheus.Gauge
-	categorizer     *PromptCategorizer
-	registry        *prometheus.Registry
+	requestDuration       *prometheus.HistogramVec
+	tokensGenerated       *prometheus.HistogramVec
+	tokensPerSecond       *prometheus.HistogramVec
+	promptEvalRate        *prometheus.HistogramVec
+	timeToFirstToken      *prometheus.HistogramVec
+	promptBytes           *prometheus.HistogramVec
+	responseBytes         *prometheus.HistogramVec
+	chatTurns             *prometheus.HistogramVec
+	modelLoadSeconds      *prometheus.HistogramVec
+	modelLoads            *prometheus.CounterVec
+	requestsTotal         *prometheus.CounterVec
+	requestErrors         *prometheus.CounterVec
+	toolCalls             *prometheus.CounterVec
+	truncatedStreams      *prometheus.CounterVec
+	multimodalRequests    *prometheus.CounterVec
+	slowOutliers          *prometheus.CounterVec
+	upstreamConns         *prometheus.GaugeVec
+	upstreamConnsAcquired *prometheus.CounterVec
+	upstreamConnWait      prometheus.Histogram
+	upstreamDials         *prometheus.CounterVec
+	upstreamDialSeconds   prometheus.Histogram
+	activeRequests        *prometheus.GaugeVec
+	streamingTokens       *prometheus.GaugeVec
+	idleShutdowns         prometheus.Counter
+	coalescedRequests     *prometheus.CounterVec
+	coldStartSeconds      prometheus.Histogram
+	queueWait             prometheus.Histogram
+	analyticsQueueDepth   prometheus.Gauge
+	analyticsDropped      prometheus.Counter
+	deniedRequests        prometheus.Counter
+	unauthorized          prometheus.Counter
+	rateLimited           prometheus.Counter
+	categorizer           *PromptCategorizer
+	recent                *recentWindow
+	registry              *prometheus.Registry
 }
 
 // NewMetricsCollector creates a new metrics collector
@@ -54,6 +85,61 @@ func NewMetricsCollector() *MetricsCollector {
 			},
 			[]string{"model", "prompt_category"},  // Removed client_ip for cardinality control
 		),
+		promptEvalRate: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "ollama_prompt_eval_tokens_per_second",
+				Help:    "Prompt processing (prefill) speed from prompt_eval_count / prompt_eval_duration",
+				Buckets: []float64{10, 50, 100, 250, 500, 1000, 2000, 5000, 10000, 20000},
+			},
+			[]string{"model"},
+		),
+		timeToFirstToken: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "ollama_time_to_first_token_seconds",
+				Help:    "Time from request start to the first streamed token",
+				Buckets: []float64{0.05, 0.1, 0.25, 0.5, 0.75, 1.0, 1.5, 2.0, 3.0, 5.0, 10.0, 20.0, 30.0, 60.0},
+			},
+			[]string{"model", "prompt_category"},
+		),
+		promptBytes: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "ollama_prompt_bytes",
+				Help:    "Request body size in bytes, independent of tokenization",
+				Buckets: prometheus.ExponentialBuckets(256, 4, 9), // 256B to 16MB
+			},
+			[]string{"model", "endpoint"},
+		),
+		chatTurns: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "ollama_chat_turns",
+				Help:    "Messages sent in chat requests (conversation depth)",
+				Buckets: prometheus.ExponentialBuckets(1, 2, 9), // 1 to 256 messages
+			},
+			[]string{"model", "endpoint"},
+		),
+		responseBytes: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "ollama_response_bytes",
+				Help:    "Response body size in bytes received from Ollama",
+				Buckets: prometheus.ExponentialBuckets(256, 4, 9), // 256B to 16MB
+			},
+			[]string{"model", "endpoint"},
+		),
+		modelLoadSeconds: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "ollama_model_load_seconds",
+				Help:    "Time Ollama spent loading a model into memory before serving a request",
+				Buckets: []float64{0.01, 0.05, 0.1, 0.5, 1.0, 2.5, 5.0, 10.0, 20.0, 30.0, 60.0, 120.0},
+			},
+			[]string{"model"},
+		),
+		modelLoads: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "ollama_model_loads_total",
+				Help: "Requests that reported a model load (nonzero load_duration)",
+			},
+			[]string{"model"},
+		),
 		requestsTotal: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "ollama_requests_total",
@@ -61,23 +147,193 @@ func NewMetricsCollector() *MetricsCollector {
 			},
 			[]string{"model", "endpoint", "prompt_category", "status"},  // Removed client_ip for cardinality control
 		),
-		activeRequests: prometheus.NewGauge(
+		requestErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "ollama_request_errors_total",
+				Help: "Failed requests by normalized error type",
+			},
+			[]string{"model", "endpoint", "error_type"},
+		),
+		toolCalls: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "ollama_tool_calls_total",
+				Help: "Tool/function calls made by the model, by tool name",
+			},
+			[]string{"model", "tool"},
+		),
+		truncatedStreams: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "ollama_truncated_streams_total",
+				Help: "Streamed generations that ended without their final chunk",
+			},
+			[]string{"model", "reason"},
+		),
+		multimodalRequests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "ollama_multimodal_requests_total",
+				Help: "Requests that included one or more images",
+			},
+			[]string{"model"},
+		),
+		slowOutliers: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "ollama_slow_outliers_total",
+				Help: "Successful requests much slower than the model's recent baseline",
+			},
+			[]string{"model"},
+		),
+		activeRequests: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "ollama_active_requests",
-				Help: "Currently active requests",
+				Help: "Currently active requests, split into streamed responses and everything else",
+			},
+			[]string{"type"},
+		),
+		streamingTokens: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "ollama_streaming_tokens_inflight",
+				Help: "Tokens generated so far by streams still in progress",
+			},
+			[]string{"model"},
+		),
+		idleShutdowns: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "ollama_idle_shutdowns_total",
+				Help: "Times Ollama was stopped after IDLE_SHUTDOWN_TIMEOUT without requests",
+			},
+		),
+		coalescedRequests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "ollama_singleflight_coalesced_total",
+				Help: "Requests answered with the response of an identical concurrent request",
+			},
+			[]string{"model"},
+		),
+		coldStartSeconds: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "ollama_cold_start_seconds",
+				Help:    "Time to start Ollama again for a request after an idle shutdown",
+				Buckets: []float64{1, 2, 5, 10, 20, 30, 60},
+			},
+		),
+		upstreamConns: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "ollama_upstream_connections",
+				Help: "Pooled connections to Ollama, by state (in_use, idle)",
+			},
+			[]string{"state"},
+		),
+		upstreamConnsAcquired: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "ollama_upstream_connections_acquired_total",
+				Help: "Upstream connections handed to requests, by whether an idle pooled connection was reused",
+			},
+			[]string{"reused"},
+		),
+		upstreamConnWait: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "ollama_upstream_connection_wait_seconds",
+				Help:    "Time requests waited to get a connection to Ollama, including any dial",
+				Buckets: []float64{0.0001, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1.0, 5.0, 30.0},
+			},
+		),
+		upstreamDials: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "ollama_upstream_dials_total",
+				Help: "New TCP connections opened to Ollama, by result",
+			},
+			[]string{"result"},
+		),
+		upstreamDialSeconds: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "ollama_upstream_dial_seconds",
+				Help:    "Time to establish a TCP connection to Ollama",
+				Buckets: []float64{0.0001, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1.0, 5.0, 30.0},
+			},
+		),
+		queueWait: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "ollama_queue_wait_seconds",
+				Help:    "Time requests waited for a concurrency slot",
+				Buckets: []float64{0.001, 0.01, 0.05, 0.1, 0.5, 1.0, 2.5, 5.0, 10.0, 30.0, 60.0},
+			},
+		),
+		analyticsQueueDepth: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "ollama_analytics_queue_depth",
+				Help: "Analytics records waiting in the write queue",
+			},
+		),
+		analyticsDropped: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "ollama_analytics_dropped_total",
+				Help: "Analytics records dropped because the write queue was full",
+			},
+		),
+		deniedRequests: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "ollama_denied_requests_total",
+				Help: "Proxied requests rejected by the client IP allow/deny lists",
+			},
+		),
+		unauthorized: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "ollama_unauthorized_requests_total",
+				Help: "Requests rejected for a missing or invalid API key",
+			},
+		),
+		rateLimited: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "ollama_rate_limited_requests_total",
+				Help: "Proxied requests rejected by the per-IP rate limit",
 			},
 		),
 		categorizer: NewPromptCategorizer(),
+		recent:      &recentWindow{},
 		registry:    registry,
 	}
 
+	// Load custom prompt categorization rules
+	if configPath := os.Getenv("CATEGORIZER_CONFIG"); configPath != "" {
+		if err := mc.categorizer.LoadRules(configPath); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+
 	// Register metrics
 	registry.MustRegister(
 		mc.requestDuration,
 		mc.tokensGenerated,
 		mc.tokensPerSecond,
+		mc.promptEvalRate,
+		mc.timeToFirstToken,
+		mc.promptBytes,
+		mc.responseBytes,
+		mc.chatTurns,
+		mc.modelLoadSeconds,
+		mc.modelLoads,
 		mc.requestsTotal,
+		mc.requestErrors,
+		mc.toolCalls,
+		mc.truncatedStreams,
+		mc.multimodalRequests,
+		mc.slowOutliers,
+		mc.upstreamConns,
+		mc.upstreamConnsAcquired,
+		mc.upstreamConnWait,
+		mc.upstreamDials,
+		mc.upstreamDialSeconds,
 		mc.activeRequests,
+		mc.streamingTokens,
+		mc.idleShutdowns,
+		mc.coalescedRequests,
+		mc.coldStartSeconds,
+		mc.queueWait,
+		mc.analyticsQueueDepth,
+		mc.analyticsDropped,
+		mc.deniedRequests,
+		mc.unauthorized,
+		mc.rateLimited,
 	)
 
 	// Also register Go runtime metrics
@@ -96,8 +352,10 @@ func (mc *MetricsCollector) Handler() http.Handler {
 
 // PromptCategorizer categorizes prompts to limit metric cardinality
 type PromptCategorizer struct {
-	patterns   []patternCategory
+	patterns   []patternCategory // Active rules; replaced as a whole on reload
+	defaults   []patternCategory // Built-in rules custom rules are layered on
 	categories map[string]bool
+	maxWords   int // MAX_PROMPT_CATEGORIES: distinct first-word categories allowed
 	mu         sync.RWMutex
 }
 
@@ -110,6 +368,7 @@ type patternCategory struct {
 func NewPromptCategorizer() *PromptCategorizer {
 	pc := &PromptCategorizer{
 		categories: make(map[string]bool),
+		maxWords:   getEnvInt("MAX_PROMPT_CATEGORIES", DefaultMaxPromptCategories),
 	}
 
 	// Define categorization patterns
@@ -138,6 +397,7 @@ func NewPromptCategorizer() *PromptCategorizer {
 			})
 		}
 	}
+	pc.defaults = pc.patterns
 
 	return pc
 }
@@ -151,10 +411,8 @@ func (pc *PromptCategorizer) Categorize(prompt string) string {
 	promptLower := strings.ToLower(prompt)
 
 	// Check patterns
-	for _, p := range pc.patterns {
-		if p.pattern.MatchString(promptLower) {
-			return p.category
-		}
+	if p, ok := pc.matchPattern(promptLower); ok {
+		return p.category
 	}
 
 	// Use first word as category if under limit
@@ -163,12 +421,16 @@ func (pc *PromptCategorizer) Categorize(prompt string) string {
 		firstWord := strings.ToLower(words[0])
 		
 		pc.mu.RLock()
+		known := pc.categories[firstWord]
 		count := len(pc.categories)
 		pc.mu.RUnlock()
 
-		if count < MaxPromptCategories {
+		if known {
+			return firstWord
+		}
+		if count < pc.maxWords {
 			pc.mu.Lock()
-			if len(pc.categories) < MaxPromptCategories {
+			if len(pc.categories) < pc.maxWords {
 				pc.categories[firstWord] = true
 				pc.mu.Unlock()
 				return firstWord
@@ -177,7 +439,20 @@ func (pc *PromptCategorizer) Categorize(prompt string) string {
 		}
 	}
 
-	// Fallback to hash-based category
-	hash := md5.Sum([]byte(promptLower))
-	return fmt.Sprintf("other_%x", hash[:4])
+	// Everything else shares one label so cardinality stays bounded
+	return OtherCategory
+}
+
+// matchPattern returns the first pattern matching the lowercased prompt
+func (pc *PromptCategorizer) matchPattern(promptLower string) (patternCategory, bool) {
+	pc.mu.RLock()
+	patterns := pc.patterns
+	pc.mu.RUnlock()
+
+	for _, p := range patterns {
+		if p.pattern.MatchString(promptLower) {
+			return p, true
+		}
+	}
+	return patternCategory{}, false
 }
\ No newline at end of file
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// MetricFamilyJSON is one registered metric with all of its series
+type MetricFamilyJSON struct {
+	Name   string             `json:"name"`
+	Help   string             `json:"help"`
+	Type   string             `json:"type"` // counter, gauge, histogram, summary or untyped
+	Series []MetricSeriesJSON `json:"series"`
+}
+
+// MetricSeriesJSON is a single label set. Counters, gauges and untyped metrics
+// set Value; histograms set Count, Sum and Buckets; summaries set Count, Sum
+// and Quantiles. Non-finite values are encoded as "NaN", "+Inf" or "-Inf".
+type MetricSeriesJSON struct {
+	Labels      map[string]string `json:"labels"`
+	Value       interface{}       `json:"value,omitempty"`
+	Count       *uint64           `json:"count,omitempty"`
+	Sum         interface{}       `json:"sum,omitempty"`
+	Buckets     []BucketJSON      `json:"buckets,omitempty"`
+	Quantiles   []QuantileJSON    `json:"quantiles,omitempty"`
+	TimestampMs int64             `json:"timestamp_ms,omitempty"`
+}
+
+// BucketJSON is a cumulative histogram bucket
+type BucketJSON struct {
+	UpperBound interface{} `json:"le"`
+	Count      uint64      `json:"count"`
+}
+
+// QuantileJSON is one summary quantile
+type QuantileJSON struct {
+	Quantile float64     `json:"quantile"`
+	Value    interface{} `json:"value"`
+}
+
+// jsonFloat returns v, or its Prometheus text form when JSON can't encode it
+func jsonFloat(v float64) interface{} {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return v
+}
+
+// familiesToJSON converts gathered metric families without dropping any
+// series, labels or buckets
+func familiesToJSON(families []*dto.MetricFamily) []MetricFamilyJSON {
+	result := make([]MetricFamilyJSON, 0, len(families))
+	for _, family := range families {
+		f := MetricFamilyJSON{
+			Name:   family.GetName(),
+			Help:   family.GetHelp(),
+			Type:   strings.ToLower(family.GetType().String()),
+			Series: make([]MetricSeriesJSON, 0, len(family.GetMetric())),
+		}
+
+		for _, m := range family.GetMetric() {
+			s := MetricSeriesJSON{
+				Labels:      make(map[string]string, len(m.GetLabel())),
+				TimestampMs: m.GetTimestampMs(),
+			}
+			for _, label := range m.GetLabel() {
+				s.Labels[label.GetName()] = label.GetValue()
+			}
+
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				s.Value = jsonFloat(m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				s.Value = jsonFloat(m.GetGauge().GetValue())
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				count := h.GetSampleCount()
+				s.Count = &count
+				s.Sum = jsonFloat(h.GetSampleSum())
+				s.Buckets = make([]BucketJSON, 0, len(h.GetBucket())+1)
+				for _, b := range h.GetBucket() {
+					s.Buckets = append(s.Buckets, BucketJSON{UpperBound: jsonFloat(b.GetUpperBound()), Count: b.GetCumulativeCount()})
+				}
+				// The +Inf bucket is implicit in the gathered data, as in the text format
+				s.Buckets = append(s.Buckets, BucketJSON{UpperBound: "+Inf", Count: count})
+			case dto.MetricType_SUMMARY:
+				sm := m.GetSummary()
+				count := sm.GetSampleCount()
+				s.Count = &count
+				s.Sum = jsonFloat(sm.GetSampleSum())
+				for _, q := range sm.GetQuantile() {
+					s.Quantiles = append(s.Quantiles, QuantileJSON{Quantile: q.GetQuantile(), Value: jsonFloat(q.GetValue())})
+				}
+			default:
+				s.Value = jsonFloat(m.GetUntyped().GetValue())
+			}
+			f.Series = append(f.Series, s)
+		}
+		result = append(result, f)
+	}
+	return result
+}
+
+// handleMetricsJSON returns every registered metric series as JSON, for
+// dashboards that can't parse the Prometheus exposition format
+func (p *Proxy) handleMetricsJSON(w http.ResponseWriter, r *http.Request) {
+	families, err := p.metrics.registry.Gather()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"timestamp": time.Now().Unix(),
+		"metrics":   familiesToJSON(families),
+	})
+}
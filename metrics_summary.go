@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	DefaultSummaryWindowMinutes = 5  // Window for recent rates in /metrics/summary
+	maxSummaryWindowMinutes     = 60 // Longest window kept in memory
+)
+
+// recentWindow counts requests per minute over the last hour. Prometheus
+// counters only hold totals since start, so recent rates are tracked here.
+type recentWindow struct {
+	mu      sync.Mutex
+	buckets [maxSummaryWindowMinutes]minuteBucket
+}
+
+type minuteBucket struct {
+	minute      int64 // Unix minute the bucket currently holds
+	requests    int
+	errors      int
+	durationSum float64
+}
+
+// add counts a finished request in the current minute
+func (w *recentWindow) add(duration float64, failed bool) {
+	minute := time.Now().Unix() / 60
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	b := &w.buckets[minute%maxSummaryWindowMinutes]
+	if b.minute != minute {
+		*b = minuteBucket{minute: minute}
+	}
+	b.requests++
+	b.durationSum += duration
+	if failed {
+		b.errors++
+	}
+}
+
+// since totals the last n minutes, including the current one
+func (w *recentWindow) since(n int) (requests, errors int, durationSum float64) {
+	oldest := time.Now().Unix()/60 - int64(n) + 1
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, b := range w.buckets {
+		if b.minute >= oldest {
+			requests += b.requests
+			errors += b.errors
+			durationSum += b.durationSum
+		}
+	}
+	return requests, errors, durationSum
+}
+
+// MetricsSummary is a compact JSON snapshot of the proxy's metrics
+type MetricsSummary struct {
+	Timestamp               int64   `json:"timestamp"`
+	ActiveRequests          float64 `json:"active_requests"`
+	ActiveStreaming         float64 `json:"active_streaming_requests"`
+	TotalRequests           float64 `json:"total_requests"`
+	TotalErrors             float64 `json:"total_errors"`
+	AvgLatencySeconds       float64 `json:"avg_latency_seconds"`
+	TotalTokens             float64 `json:"total_tokens"`
+	AnalyticsQueueDepth     float64 `json:"analytics_queue_depth"`
+	WindowMinutes           int     `json:"window_minutes"`
+	WindowRequests          int     `json:"window_requests"`
+	WindowErrors            int     `json:"window_errors"`
+	WindowErrorRate         float64 `json:"window_error_rate"`
+	WindowAvgLatency        float64 `json:"window_avg_latency_seconds"`
+	WindowRequestsPerMinute float64 `json:"window_requests_per_minute"`
+}
+
+// labelValue returns the value of the named label on m, or ""
+func labelValue(m *dto.Metric, name string) string {
+	for _, label := range m.GetLabel() {
+		if label.GetName() == name {
+			return label.GetValue()
+		}
+	}
+	return ""
+}
+
+// summarizeFamilies totals the gathered Prometheus metrics the summary reports
+func summarizeFamilies(families []*dto.MetricFamily, summary *MetricsSummary) {
+	var durationSum float64
+	var durationCount uint64
+
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			switch family.GetName() {
+			case "ollama_active_requests":
+				summary.ActiveRequests += m.GetGauge().GetValue()
+				if labelValue(m, "type") == "streaming" {
+					summary.ActiveStreaming += m.GetGauge().GetValue()
+				}
+			case "ollama_analytics_queue_depth":
+				summary.AnalyticsQueueDepth += m.GetGauge().GetValue()
+			case "ollama_requests_total":
+				summary.TotalRequests += m.GetCounter().GetValue()
+			case "ollama_request_errors_total":
+				summary.TotalErrors += m.GetCounter().GetValue()
+			case "ollama_request_duration_seconds":
+				durationSum += m.GetHistogram().GetSampleSum()
+				durationCount += m.GetHistogram().GetSampleCount()
+			case "ollama_tokens_generated":
+				summary.TotalTokens += m.GetHistogram().GetSampleSum()
+			}
+		}
+	}
+
+	if durationCount > 0 {
+		summary.AvgLatencySeconds = durationSum / float64(durationCount)
+	}
+}
+
+// handleMetricsSummary returns a JSON snapshot of current metrics for tools that
+// don't scrape Prometheus. ?minutes=N (1-60, default 5) sets the recent window.
+func (p *Proxy) handleMetricsSummary(w http.ResponseWriter, r *http.Request) {
+	minutes := DefaultSummaryWindowMinutes
+	if m := r.URL.Query().Get("minutes"); m != "" {
+		if parsed, err := strconv.Atoi(m); err == nil && parsed > 0 {
+			minutes = parsed
+		}
+	}
+	if minutes > maxSummaryWindowMinutes {
+		minutes = maxSummaryWindowMinutes
+	}
+
+	families, err := p.metrics.registry.Gather()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	summary := MetricsSummary{
+		Timestamp:     time.Now().Unix(),
+		WindowMinutes: minutes,
+	}
+	summarizeFamilies(families, &summary)
+
+	requests, errors, durationSum := p.metrics.recent.since(minutes)
+	summary.WindowRequests = requests
+	summary.WindowErrors = errors
+	summary.WindowRequestsPerMinute = float64(requests) / float64(minutes)
+	if requests > 0 {
+		summary.WindowErrorRate = float64(errors) / float64(requests)
+		summary.WindowAvgLatency = durationSum / float64(requests)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultModelListTTL   = 60 * time.Second // How long the /api/tags model list is trusted
+	modelListMinRefresh   = 10 * time.Second // Minimum gap between refreshes triggered by unknown models
+	modelListFetchTimeout = 2 * time.Second
+	unknownModelLabel     = "unknown"
+)
+
+// modelRegistry caches the models Ollama reports in /api/tags so that only
+// real model names are used as Prometheus label values. Arbitrary client
+// supplied names would otherwise create unbounded metric cardinality.
+type modelRegistry struct {
+	target     string
+	ttl        time.Duration
+	mu         sync.RWMutex
+	known      map[string]bool
+	fetchedAt  time.Time
+	refreshing atomic.Bool
+}
+
+// newModelRegistry creates a registry for the Ollama instance at target
+func newModelRegistry(target string) *modelRegistry {
+	return &modelRegistry{
+		target: strings.TrimSuffix(target, "/"),
+		ttl:    getEnvDuration("MODEL_LIST_TTL", DefaultModelListTTL),
+	}
+}
+
+// label returns the model name if Ollama knows it, otherwise "unknown"
+func (m *modelRegistry) label(model string) string {
+	if model == "" || model == unknownModelLabel {
+		return unknownModelLabel
+	}
+
+	m.mu.RLock()
+	known, fetchedAt := m.known, m.fetchedAt
+	m.mu.RUnlock()
+
+	if known == nil {
+		// Never loaded yet: fetch once synchronously so early requests get real labels
+		m.refresh()
+		m.mu.RLock()
+		known, fetchedAt = m.known, m.fetchedAt
+		m.mu.RUnlock()
+	} else if time.Since(fetchedAt) > m.ttl {
+		go m.refresh()
+	}
+
+	if name, ok := matchModel(known, model); ok {
+		return name
+	}
+
+	// The model may have been pulled since the last refresh
+	if time.Since(fetchedAt) > modelListMinRefresh {
+		go m.refresh()
+	}
+	return unknownModelLabel
+}
+
+// matchModel looks up a model name, treating "llama3" and "llama3:latest" as the same model
+func matchModel(known map[string]bool, model string) (string, bool) {
+	if known[model] {
+		return model, true
+	}
+	if !strings.Contains(model, ":") && known[model+":latest"] {
+		return model, true
+	}
+	if base, ok := strings.CutSuffix(model, ":latest"); ok && known[base] {
+		return model, true
+	}
+	return "", false
+}
+
+// refresh reloads the model list from /api/tags. Concurrent calls are collapsed
+// into one request; on failure the previous list is kept.
+func (m *modelRegistry) refresh() {
+	if !m.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	defer m.refreshing.Store(false)
+
+	known, err := m.fetch()
+	if err != nil {
+		log.Printf("Warning: Failed to refresh model list from Ollama: %v", err)
+		m.mu.Lock()
+		if m.known == nil {
+			m.known = make(map[string]bool)
+		}
+		m.fetchedAt = time.Now()
+		m.mu.Unlock()
+		return
+	}
+
+	m.mu.Lock()
+	m.known = known
+	m.fetchedAt = time.Now()
+	m.mu.Unlock()
+}
+
+// fetch requests the current model list from Ollama
+func (m *modelRegistry) fetch() (map[string]bool, error) {
+	client := &http.Client{Timeout: modelListFetchTimeout}
+	resp, err := client.Get(m.target + "/api/tags")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ollama returned status %d", resp.StatusCode)
+	}
+
+	var tags struct {
+		Models []struct {
+			Name  string `json:"name"`
+			Model string `json:"model"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("failed to parse model list: %w", err)
+	}
+
+	known := make(map[string]bool, len(tags.Models))
+	for _, t := range tags.Models {
+		if t.Name != "" {
+			known[t.Name] = true
+		}
+		if t.Model != "" {
+			known[t.Model] = true
+		}
+	}
+	return known, nil
+}
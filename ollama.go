@@ -2,6 +2,9 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -11,7 +14,9 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
@@ -21,6 +26,9 @@ type OllamaProcess struct {
 	port int
 }
 
+// DefaultOllamaStopTimeout is how long Stop waits for Ollama to exit before killing it
+const DefaultOllamaStopTimeout = 10 * time.Second
+
 // Stop terminates the Ollama process
 func (op *OllamaProcess) Stop() {
 	if op == nil || op.cmd == nil || op.cmd.Process == nil {
@@ -43,14 +51,29 @@ func (op *OllamaProcess) Stop() {
 			log.Printf("Successfully killed Ollama process tree")
 		}
 	} else {
-		// On Unix-like systems, use regular kill
+		// On Unix-like systems, send SIGTERM first so Ollama can stop its model
+		// runners and release GPU memory
+		if err := op.cmd.Process.Signal(syscall.SIGTERM); err != nil && !errors.Is(err, os.ErrProcessDone) {
+			log.Printf("Failed to send SIGTERM: %v", err)
+		}
+	}
+	
+	// Wait for process to exit, escalating to SIGKILL if it hangs
+	exited := make(chan struct{})
+	go func() {
+		op.cmd.Wait()
+		close(exited)
+	}()
+	timeout := getEnvDuration("OLLAMA_STOP_TIMEOUT", DefaultOllamaStopTimeout)
+	select {
+	case <-exited:
+	case <-time.After(timeout):
+		log.Printf("Ollama did not exit within %v, killing it", timeout)
 		if err := op.cmd.Process.Kill(); err != nil {
 			log.Printf("Failed to kill process: %v", err)
 		}
+		<-exited
 	}
-	
-	// Wait for process to exit
-	op.cmd.Wait()
 }
 
 // findOllamaExecutable locates the ollama executable
@@ -191,14 +214,69 @@ func checkPort(port int) error {
 	return nil
 }
 
+// DefaultOllamaKeepAlive keeps models loaded indefinitely unless OLLAMA_KEEP_ALIVE is set
+const DefaultOllamaKeepAlive = "-1"
+
+// proxyOllamaVars are OLLAMA_* settings read by the proxy itself rather than Ollama
+var proxyOllamaVars = map[string]bool{
+	"OLLAMA_HOST":                  true, // Set by the proxy to the backend address
+	"OLLAMA_BACKEND_PORT":          true,
+	"OLLAMA_BIND_ADDR":             true,
+	"OLLAMA_TARGET_URL":            true,
+	"OLLAMA_EXECUTABLE_PATH":       true,
+	"OLLAMA_MAX_RESTARTS_PER_HOUR": true,
+	"OLLAMA_STOP_TIMEOUT":          true,
+}
+
+// isProxyOllamaVar reports whether an OLLAMA_* variable configures the proxy
+func isProxyOllamaVar(key string) bool {
+	key = strings.ToUpper(key)
+	return proxyOllamaVars[key] ||
+		strings.HasPrefix(key, "OLLAMA_HEALTH_") ||
+		strings.HasPrefix(key, "OLLAMA_RESTART_")
+}
+
+// ollamaEnvironment builds the environment for the managed Ollama process.
+// Every OLLAMA_* variable in the proxy's environment (OLLAMA_NUM_PARALLEL,
+// OLLAMA_FLASH_ATTENTION, ...) is passed through, except the proxy's own
+// settings. OLLAMA_HOST is always the proxy's backend address, and
+// OLLAMA_KEEP_ALIVE defaults to -1 when not set.
+func ollamaEnvironment(host string) []string {
+	env := make([]string, 0, len(os.Environ())+2)
+	keepAliveSet := false
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		upper := strings.ToUpper(key)
+		if !strings.HasPrefix(upper, "OLLAMA_") {
+			env = append(env, kv)
+			continue
+		}
+		if isProxyOllamaVar(key) {
+			if upper == "OLLAMA_HOST" && value != host {
+				log.Printf("Ignoring OLLAMA_HOST=%s for the managed Ollama (the proxy uses %s)", value, host)
+			}
+			continue
+		}
+		if upper == "OLLAMA_KEEP_ALIVE" {
+			keepAliveSet = true
+		}
+		log.Printf("Passing %s to Ollama", kv)
+		env = append(env, kv)
+	}
+
+	env = append(env, "OLLAMA_HOST="+host)
+	if !keepAliveSet {
+		env = append(env, "OLLAMA_KEEP_ALIVE="+DefaultOllamaKeepAlive)
+	}
+	return env
+}
+
 // startOllama starts the Ollama process on the specified port
 func startOllama(ollamaPath string, port int) (*OllamaProcess, error) {
-	env := append(os.Environ(), 
-		fmt.Sprintf("OLLAMA_HOST=0.0.0.0:%d", port),
-		"OLLAMA_KEEP_ALIVE=-1",  // Keep models loaded for 5 minutes
-	)
+	host := net.JoinHostPort(getOllamaBindAddr(), strconv.Itoa(port))
+	env := ollamaEnvironment(host)
 	
-	log.Printf("Starting Ollama server on port %d", port)
+	log.Printf("Starting Ollama server on %s", host)
 	cmd := exec.Command(ollamaPath, "serve")
 	cmd.Env = env
 	
@@ -257,6 +335,106 @@ func isPortOpen(host string, port int) bool {
 	return true
 }
 
+// getRemoteTargetURL returns OLLAMA_TARGET_URL when the proxy should forward
+// to an existing (remote) Ollama instead of managing a local one
+func getRemoteTargetURL() string {
+	target := strings.TrimSpace(appConfig.OllamaTargetURL)
+	if target == "" {
+		return ""
+	}
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+	return strings.TrimRight(target, "/")
+}
+
+// manageOllama reports whether the proxy starts and supervises Ollama itself.
+// MANAGE_OLLAMA=false (or --no-manage) leaves it to another service manager.
+func manageOllama() bool {
+	return appConfig.ManageOllama
+}
+
+// getExternalTargetURL returns the Ollama to forward to when the proxy must
+// not start, kill or restart it: OLLAMA_TARGET_URL if set, otherwise the
+// local backend port when MANAGE_OLLAMA=false. Empty means Ollama is managed.
+func getExternalTargetURL() string {
+	if remoteURL := getRemoteTargetURL(); remoteURL != "" {
+		return remoteURL
+	}
+	if !manageOllama() {
+		return fmt.Sprintf("http://localhost:%d", getOllamaPort())
+	}
+	return ""
+}
+
+// DefaultWarmupTimeout bounds how long a single model may take to load during warm-up
+const DefaultWarmupTimeout = 5 * time.Minute
+
+// warmupModels preloads the models listed in WARMUP_MODELS (comma-separated)
+// so the first real request doesn't pay for the model load. An empty prompt
+// makes Ollama load the model without generating anything. Models are loaded
+// one at a time; failures are logged and skipped.
+func warmupModels(baseURL string) {
+	var models []string
+	for _, model := range strings.Split(os.Getenv("WARMUP_MODELS"), ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			models = append(models, model)
+		}
+	}
+	if len(models) == 0 {
+		return
+	}
+
+	client := &http.Client{Timeout: getEnvDuration("WARMUP_TIMEOUT", DefaultWarmupTimeout)}
+	LogPrintf("Warming up %d models", len(models))
+	for _, model := range models {
+		start := time.Now()
+		if err := warmupModel(client, baseURL, model); err != nil {
+			LogPrintf("Warning: Warm-up of %s failed after %s, skipping: %v", model, time.Since(start).Round(time.Millisecond), err)
+			continue
+		}
+		LogPrintf("Warmed up %s in %s", model, time.Since(start).Round(time.Millisecond))
+	}
+}
+
+// warmupModel loads one model with an empty /api/generate request
+func warmupModel(client *http.Client, baseURL, model string) error {
+	body, err := json.Marshal(map[string]interface{}{"model": model, "prompt": "", "stream": false})
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(baseURL+"/api/generate", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var data map[string]interface{}
+		if json.NewDecoder(resp.Body).Decode(&data) == nil {
+			if msg := upstreamErrorMessage(data); msg != "" {
+				return fmt.Errorf("API returned %d: %s", resp.StatusCode, msg)
+			}
+		}
+		return fmt.Errorf("API returned %d", resp.StatusCode)
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// pingOllama checks that the Ollama API at baseURL responds
+func pingOllama(baseURL string, timeout time.Duration) error {
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(baseURL + "/api/tags")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
 // waitForOllama waits for Ollama to be ready
 func waitForOllama(host string, port int, timeout time.Duration) bool {
 	deadline := time.Now().Add(timeout)
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+)
+
+// OpenAI-compatible endpoints (/v1/chat/completions, /v1/completions) use a
+// different request and response shape than Ollama's native API. These helpers
+// extract the same fields parseRequest and the response handlers need.
+
+// messageContent returns the text of a chat message. OpenAI clients may send
+// content as a string or as an array of {"type": "text", "text": "..."} parts.
+func messageContent(message map[string]interface{}) (string, bool) {
+	switch content := message["content"].(type) {
+	case string:
+		return content, true
+	case []interface{}:
+		var parts []string
+		for _, part := range content {
+			if p, ok := part.(map[string]interface{}); ok {
+				if text, ok := p["text"].(string); ok {
+					parts = append(parts, text)
+				}
+			}
+		}
+		return strings.Join(parts, "\n"), len(parts) > 0
+	}
+	return "", false
+}
+
+// openAIChoiceText extracts generated text from the first choice of an
+// OpenAI-style response or stream chunk
+func openAIChoiceText(data map[string]interface{}) (string, bool) {
+	choices, ok := data["choices"].([]interface{})
+	if !ok || len(choices) == 0 {
+		return "", false
+	}
+	choice, ok := choices[0].(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+
+	// Chat completions: "message" (non-streaming) or "delta" (streaming)
+	for _, key := range []string{"message", "delta"} {
+		if message, ok := choice[key].(map[string]interface{}); ok {
+			if text, ok := messageContent(message); ok {
+				return text, true
+			}
+		}
+	}
+
+	// Legacy completions
+	if text, ok := choice["text"].(string); ok {
+		return text, true
+	}
+	return "", false
+}
+
+// openAIUsage extracts token counts from an OpenAI-style "usage" object
+func openAIUsage(data map[string]interface{}) (promptTokens, completionTokens int, ok bool) {
+	usage, ok := data["usage"].(map[string]interface{})
+	if !ok {
+		return 0, 0, false
+	}
+	if v, ok := usage["prompt_tokens"].(float64); ok {
+		promptTokens = int(v)
+	}
+	if v, ok := usage["completion_tokens"].(float64); ok {
+		completionTokens = int(v)
+	}
+	return promptTokens, completionTokens, true
+}
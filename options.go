@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// optionDefaults holds the generation options merged into /api/generate and
+// /api/chat requests
+type optionDefaults struct {
+	values map[string]json.RawMessage // DEFAULT_OPTIONS, e.g. {"num_ctx": 8192, "temperature": 0.7}
+	force  map[string]bool            // FORCE_OPTIONS keys that replace client-supplied values
+}
+
+// loadDefaultOptions parses DEFAULT_OPTIONS (a JSON object) and FORCE_OPTIONS
+// (a comma-separated list of its keys). Returns nil when no defaults are configured.
+func loadDefaultOptions() *optionDefaults {
+	raw := strings.TrimSpace(os.Getenv("DEFAULT_OPTIONS"))
+	if raw == "" {
+		return nil
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &values); err != nil {
+		log.Printf("Warning: Ignoring invalid DEFAULT_OPTIONS (expected a JSON object): %v", err)
+		return nil
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	force := make(map[string]bool)
+	for _, key := range strings.Split(os.Getenv("FORCE_OPTIONS"), ",") {
+		if key = strings.TrimSpace(key); key == "" {
+			continue
+		}
+		if _, ok := values[key]; !ok {
+			log.Printf("Warning: FORCE_OPTIONS entry %q has no value in DEFAULT_OPTIONS, ignoring", key)
+			continue
+		}
+		force[key] = true
+	}
+	log.Printf("Loaded %d default generation options (%d forced)", len(values), len(force))
+	return &optionDefaults{values: values, force: force}
+}
+
+// usesOptions reports whether the request path takes an Ollama "options" object
+func usesOptions(path string) bool {
+	switch path {
+	case "/api/generate", "/api/chat":
+		return true
+	}
+	return false
+}
+
+// apply merges the defaults into the body's "options" object. It returns the
+// (possibly rewritten) body, the keys filled in because the client left them
+// unset, and the keys whose client value was replaced by a forced default.
+func (d *optionDefaults) apply(body []byte) (rewritten []byte, defaulted, forced []string) {
+	if d == nil || len(body) == 0 {
+		return body, nil, nil
+	}
+
+	var data map[string]json.RawMessage
+	if err := json.Unmarshal(body, &data); err != nil {
+		return body, nil, nil
+	}
+	options := make(map[string]json.RawMessage)
+	if raw, ok := data["options"]; ok && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &options); err != nil {
+			// Leave malformed options for Ollama to reject
+			return body, nil, nil
+		}
+	}
+
+	for key, value := range d.values {
+		current, set := options[key]
+		switch {
+		case !set:
+			options[key] = value
+			defaulted = append(defaulted, key)
+		case d.force[key] && !jsonEqual(current, value):
+			options[key] = value
+			forced = append(forced, key)
+		}
+	}
+	if len(defaulted) == 0 && len(forced) == 0 {
+		return body, nil, nil
+	}
+
+	var err error
+	if data["options"], err = json.Marshal(options); err != nil {
+		return body, nil, nil
+	}
+	if rewritten, err = json.Marshal(data); err != nil {
+		return body, nil, nil
+	}
+	sort.Strings(defaulted)
+	sort.Strings(forced)
+	return rewritten, defaulted, forced
+}
+
+// jsonEqual compares two JSON values ignoring insignificant whitespace
+func jsonEqual(a, b json.RawMessage) bool {
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, a) != nil || json.Compact(&cb, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"log/slog"
+	"math"
 	"net"
 	"net/http"
 	"net/http/httputil"
@@ -16,18 +18,91 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	DefaultMaxRequestBody      = 32 * 1024 * 1024      // 32MB
+	DefaultMaxResponseCapture  = 1024 * 1024           // 1MB
+	DefaultDrainTimeout        = 30 * time.Second      // Wait for in-flight streams on shutdown
+	DefaultRequestTimeout      = 5 * time.Minute       // Cap for non-streaming proxied requests
+	DefaultStreamTimeout       = 30 * time.Minute      // Cap for streaming generate/chat requests
+	DefaultStreamFlushInterval = 10 * time.Millisecond // How often buffered response bytes are flushed to clients
+
+	// maxStreamLine bounds one buffered streaming chunk; longer lines aren't parsed
+	maxStreamLine = 16 * 1024 * 1024
+
+	// StatusClientClosedRequest records requests abandoned by the client (nginx convention)
+	StatusClientClosedRequest = 499
+)
+
 // Proxy handles HTTP reverse proxy with metrics collection
 type Proxy struct {
 	target        *url.URL
 	reverseProxy  *httputil.ReverseProxy
 	port          int
+	bindAddr      string // Interface to listen on (empty = all interfaces)
 	metrics       *MetricsCollector
 	analytics     *AnalyticsWriter
 	server        *http.Server
-	maxConcurrent chan struct{} // Semaphore for rate limiting
+	maxConcurrent chan struct{}     // Semaphore for rate limiting
+	apiKeys       []string          // Accepted keys for proxied requests (empty = open access)
+	adminAPIKey   string            // Key required for /metrics and /analytics/* (empty = admin endpoints disabled)
+	ipFilter      *ipFilter         // Client IP allow/deny lists
+	models        *modelRegistry    // Known Ollama models, used to bound metric label values
+	host          *hostSampler      // Host CPU/memory sampler (nil = disabled)
+	tags          *tagsCache        // Short-lived cache of GET /api/tags responses
+	userHeader    string            // Request header carrying the caller's user ID
+	tagHeader     string            // Request header carrying a client-chosen analytics tag
+	breaker       *circuitBreaker   // Fast-fails requests while Ollama is down
+	modelAliases  map[string]string // Requested model name -> model actually forwarded
+	rateLimiter   *rateLimiter      // Per-client-IP request rate limit (nil = disabled)
+
+	toolCallCategory string            // Prompt category for requests that offer tools ("" = categorize normally)
+	defaultOptions   *optionDefaults   // Generation options merged into generate/chat requests (nil = none)
+	audit            *auditLogger      // Full-content audit trail in AUDIT_LOG_DIR (nil = disabled)
+	cors             *corsPolicy       // Cross-origin access to the analytics APIs (nil = disabled)
+	recentRequests   *recentRequests   // In-memory tail of completed requests for /analytics/recent
+	latency          *latencyBaselines // Per-model latency baselines for slow outlier detection
+	idle             *idleShutdown     // Stops Ollama when unused (nil = disabled)
+	singleFlight     *singleFlight     // Coalesces identical concurrent requests (nil = disabled)
+	responseCache    *responseCache    // Responses to deterministic requests (nil = disabled)
+	pusher           *metricsPusher    // Pushes metrics to PUSHGATEWAY_URL (nil = disabled)
+
+	// Per-model token pricing (nil = no cost tracking), swapped on config reload
+	costs atomic.Pointer[CostConfig]
+
+	maxRequestBody     int64         // Largest request body accepted from clients
+	streamRequestBody  int64         // Bodies larger than this are streamed upstream, not buffered (0 = off)
+	maxResponseCapture int           // Bytes of streaming response retained for metrics
+	streamTokenUpdate  int           // Content chunks between in-flight token gauge updates (0 = off)
+	requestTimeout     time.Duration // Per-request cap for non-streaming requests
+	streamTimeout      time.Duration // Per-request cap for streaming requests
+
+	storeFullMessages    bool // Keep the whole chat history in analytics metadata
+	fullMessagesMaxBytes int  // Cap on the stored history per request
+	storeRawTimings      bool // Keep Ollama's raw nanosecond timings in analytics metadata
+	preserveHost         bool // Forward the client's Host header instead of the target's
+
+	// Shutdown draining of in-flight streams
+	drainTimeout  time.Duration
+	activeStreams sync.WaitGroup
+	streamCount   atomic.Int64
+	draining      atomic.Bool
+
+	// Readiness tracking for /ready
+	lastUpstreamContact atomic.Int64 // Unix nanoseconds of the last successful upstream response
+	readyMu             sync.Mutex
+	lastReadyCheck      time.Time
+	lastReadyErr        error
+
+	// Reported by /status
+	started           time.Time
+	status            statusCache
+	ollamaRestarts    atomic.Int64 // Restarts by the health monitor
+	lastOllamaRestart atomic.Int64 // Unix seconds of the last restart
 }
 
 // NewProxy creates a new proxy instance
@@ -37,46 +112,109 @@ func NewProxy(targetURL string, port int, isService bool) *Proxy {
 		log.Fatalf("Invalid target URL: %v", err)
 	}
 
-	// Determine analytics directory based on execution context
-	var analyticsDir string
+	analyticsDir := getAnalyticsDir(isService)
 
-	if isService {
-		// Service mode: use ProgramData
-		programData := os.Getenv("ProgramData")
-		if programData == "" {
-			programData = "C:\\ProgramData"
+	// Ensure directory exists. An explicit ANALYTICS_DIR must be usable,
+	// otherwise analytics would silently end up nowhere.
+	if strings.TrimSpace(os.Getenv("ANALYTICS_DIR")) != "" {
+		if err := ensureWritableDir(analyticsDir); err != nil {
+			log.Fatalf("ANALYTICS_DIR %s is not usable: %v", analyticsDir, err)
 		}
-		analyticsDir = filepath.Join(programData, "OllamaProxy", "analytics")
-	} else {
-		// Console mode: use directory relative to executable
-		if exePath, err := os.Executable(); err == nil {
-			exeDir := filepath.Dir(exePath)
-			analyticsDir = filepath.Join(exeDir, "ollama_analytics")
-		} else {
-			analyticsDir = filepath.Join(".", "ollama_analytics")
-		}
-	}
-	
-	// Ensure directory exists
-	if err := os.MkdirAll(analyticsDir, 0755); err != nil {
+	} else if err := os.MkdirAll(analyticsDir, 0755); err != nil {
 		log.Printf("Warning: Failed to create analytics directory %s: %v", analyticsDir, err)
 	}
 	
+	metrics := NewMetricsCollector()
+
 	p := &Proxy{
 		target:        target,
 		port:          port,
-		metrics:       NewMetricsCollector(),
-		analytics:     NewAnalyticsWriter("sqlite", analyticsDir),
+		bindAddr:      getProxyBindAddr(),
+		metrics:       metrics,
+		analytics:     NewAnalyticsWriter(getAnalyticsBackend(), analyticsDir, metrics),
 		maxConcurrent: make(chan struct{}, 50), // Limit to 50 concurrent requests
+		apiKeys:       loadAPIKeys(),
+		adminAPIKey:   strings.TrimSpace(os.Getenv("ADMIN_API_KEY")),
+		ipFilter:      loadIPFilter(),
+		models:        newModelRegistry(targetURL),
+		host:          newHostSampler(),
+		tags:          newTagsCache(),
+		userHeader:    loadUserHeader(),
+		tagHeader:     loadTagHeader(),
+		breaker:       newCircuitBreaker(),
+		modelAliases:  loadModelAliases(),
+		rateLimiter:   newRateLimiter(),
+
+		toolCallCategory: loadToolCallCategory(),
+		defaultOptions:   loadDefaultOptions(),
+		audit:            newAuditLogger(),
+		cors:             loadCORSPolicy(),
+		recentRequests:   newRecentRequests(),
+		latency:          newLatencyBaselines(),
+		started:          time.Now(),
+		singleFlight:     newSingleFlight(),
+		responseCache:    newResponseCache(),
+		pusher:           newMetricsPusher(metrics.registry),
+
+		storeFullMessages:    loadStoreFullMessages(),
+		fullMessagesMaxBytes: getEnvInt("STORE_FULL_MESSAGES_MAX_BYTES", DefaultFullMessagesMaxBytes),
+		storeRawTimings:      strings.EqualFold(strings.TrimSpace(os.Getenv("STORE_RAW_TIMINGS")), "true"),
+		preserveHost:         strings.EqualFold(strings.TrimSpace(os.Getenv("PRESERVE_HOST")), "true"),
+
+		maxRequestBody:     int64(getEnvInt("MAX_REQUEST_BODY_BYTES", DefaultMaxRequestBody)),
+		streamRequestBody:  int64(getEnvInt("STREAM_REQUEST_BODY_BYTES", 0)),
+		maxResponseCapture: getEnvInt("MAX_RESPONSE_CAPTURE_BYTES", DefaultMaxResponseCapture),
+		streamTokenUpdate:  getEnvInt("STREAM_TOKEN_UPDATE_CHUNKS", 0),
+		drainTimeout:       getEnvDuration("PROXY_DRAIN_TIMEOUT", DefaultDrainTimeout),
+		requestTimeout:     getEnvDuration("REQUEST_TIMEOUT", DefaultRequestTimeout),
+		streamTimeout:      getEnvDuration("STREAM_REQUEST_TIMEOUT", DefaultStreamTimeout),
+	}
+	if p.maxRequestBody <= 0 {
+		p.maxRequestBody = DefaultMaxRequestBody
+	}
+	if p.maxResponseCapture < 0 {
+		p.maxResponseCapture = DefaultMaxResponseCapture
+	}
+	if p.streamTokenUpdate < 0 {
+		p.streamTokenUpdate = 0
 	}
 
-	// Create custom transport with proper timeouts for Ollama
+	metrics.registerBreakerMetrics(p.breaker)
+	if p.host != nil {
+		metrics.registerHostMetrics(p.host)
+		log.Printf("Host resource sampling enabled")
+	}
+
+	if len(p.apiKeys) > 0 {
+		log.Printf("API key authentication enabled for proxied requests (%d keys)", len(p.apiKeys))
+	}
+	if p.adminAPIKey != "" {
+		log.Printf("Admin API key required for /metrics and /analytics endpoints")
+	} else {
+		log.Printf("Warning: ADMIN_API_KEY not set, /metrics, /analytics and /admin endpoints are disabled")
+	}
+
+	// Load per-model cost configuration
+	if costPath := os.Getenv("COST_CONFIG"); costPath != "" {
+		if costs, err := loadCostConfig(costPath); err != nil {
+			log.Printf("Warning: %v", err)
+		} else {
+			p.costs.Store(costs)
+		}
+	}
+
+	// Create custom transport with proper timeouts for Ollama. Connection
+	// pooling and HTTP/2 can be tuned for high-throughput setups.
 	transport := &http.Transport{
-		MaxIdleConns:        100,
-		MaxIdleConnsPerHost: 10,
-		IdleConnTimeout:     90 * time.Second,
-		DisableCompression:  true, // Let Ollama handle compression
-		ForceAttemptHTTP2:   false,
+		MaxIdleConns:        getEnvInt("PROXY_MAX_IDLE_CONNS", 100),
+		MaxIdleConnsPerHost: getEnvInt("PROXY_MAX_IDLE_CONNS_PER_HOST", 10),
+		IdleConnTimeout:     getEnvDuration("PROXY_IDLE_CONN_TIMEOUT", 90*time.Second),
+		// Compression stays off: with gzip the transport would decompress and
+		// re-chunk responses, which breaks byte-for-byte NDJSON streaming and
+		// the token accounting done on the streamed body
+		DisableCompression: true,
+		// HTTP/2 is only negotiated over TLS (https:// OLLAMA_TARGET_URL)
+		ForceAttemptHTTP2: strings.EqualFold(strings.TrimSpace(os.Getenv("ENABLE_HTTP2")), "true"),
 		// Add explicit timeouts for service reliability
 		DialContext: (&net.Dialer{
 			Timeout:   30 * time.Second,
@@ -89,8 +227,8 @@ func NewProxy(targetURL string, port int, isService bool) *Proxy {
 
 	// Create reverse proxy with custom director
 	p.reverseProxy = &httputil.ReverseProxy{
-		Transport: transport,
-		FlushInterval: 10 * time.Millisecond, // Small flush interval for streaming (not -1 which can cause issues in service mode)
+		Transport: &breakerTransport{next: newRetryTransport(newConnPoolTransport(transport, metrics)), breaker: p.breaker},
+		FlushInterval: getStreamFlushInterval(), // Same in console and service mode
 		BufferPool: nil, // Use default buffer pool
 		Director: func(req *http.Request) {
 			// Save original host before modification
@@ -102,7 +240,9 @@ func NewProxy(targetURL string, port int, isService bool) *Proxy {
 			// IMPORTANT: Modify the existing URL in place, don't create a new one
 			req.URL.Scheme = p.target.Scheme
 			req.URL.Host = p.target.Host
-			req.Host = p.target.Host
+			if !p.preserveHost {
+				req.Host = p.target.Host
+			}
 			
 			// Add X-Forwarded headers
 			if clientIP, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
@@ -112,8 +252,7 @@ func NewProxy(targetURL string, port int, isService bool) *Proxy {
 			req.Header.Set("X-Forwarded-Proto", "http")
 			
 			// Log the final request being sent
-			// Optional: Log the final request being sent
-			log.Printf("Director: Forwarding to %s%s", req.URL.Host, req.URL.Path)
+			log.Printf("[%s] Director: Forwarding to %s%s", req.Header.Get(requestIDHeader), req.URL.Host, req.URL.Path)
 		},
 		ModifyResponse: p.modifyResponse,
 		ErrorHandler:   p.errorHandler,
@@ -122,40 +261,135 @@ func NewProxy(targetURL string, port int, isService bool) *Proxy {
 	return p
 }
 
+// getAnalyticsDir returns where analytics are stored: ANALYTICS_DIR when set,
+// otherwise based on execution context
+func getAnalyticsDir(isService bool) string {
+	if dir := strings.TrimSpace(os.Getenv("ANALYTICS_DIR")); dir != "" {
+		return dir
+	}
+
+	if isService {
+		// Service mode: use ProgramData
+		programData := os.Getenv("ProgramData")
+		if programData == "" {
+			programData = "C:\\ProgramData"
+		}
+		return filepath.Join(programData, "OllamaProxy", "analytics")
+	}
+
+	// Console mode: use directory relative to executable
+	if exePath, err := os.Executable(); err == nil {
+		return filepath.Join(filepath.Dir(exePath), "ollama_analytics")
+	}
+	return filepath.Join(".", "ollama_analytics")
+}
+
+// ensureWritableDir creates dir if needed and checks that files can be created in it
+func ensureWritableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".write-test-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// getAnalyticsBackend returns the configured analytics backend (default: sqlite)
+func getAnalyticsBackend() string {
+	if backend := strings.ToLower(os.Getenv("ANALYTICS_BACKEND")); backend != "" {
+		return backend
+	}
+	return "sqlite"
+}
+
+// getStreamFlushInterval reads STREAM_FLUSH_INTERVAL: a duration such as
+// "50ms", or -1 to flush after every write. Defaults to DefaultStreamFlushInterval.
+func getStreamFlushInterval() time.Duration {
+	value := strings.TrimSpace(os.Getenv("STREAM_FLUSH_INTERVAL"))
+	if value == "" {
+		return DefaultStreamFlushInterval
+	}
+	if value == "-1" {
+		return -1
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval == 0 {
+		log.Printf("Warning: Invalid value for STREAM_FLUSH_INTERVAL: %q (expected a positive duration or -1), using %v", value, DefaultStreamFlushInterval)
+		return DefaultStreamFlushInterval
+	}
+	if interval < 0 {
+		return -1
+	}
+	return interval
+}
+
 // Start begins the proxy server
 func (p *Proxy) Start() error {
 	mux := http.NewServeMux()
 
+	// Optional endpoints answer 404 when disabled instead of falling through to Ollama
+	handleOptional := func(pattern, toggle string, handler http.HandlerFunc) {
+		if !endpointEnabled(toggle) {
+			log.Printf("%s disabled by %s=false", pattern, toggle)
+			handler = http.NotFound
+		}
+		mux.HandleFunc(pattern, handler)
+	}
+
 	// Metrics endpoint
-	mux.HandleFunc("/metrics", p.handleMetrics)
+	mux.HandleFunc("/metrics", p.requireAdmin(p.handleMetrics))
+	mux.HandleFunc("/metrics/summary", p.cors.wrap(p.requireAdmin(p.handleMetricsSummary)))
+	mux.HandleFunc("/metrics/json", p.cors.wrap(p.requireAdmin(gzipHandler(p.handleMetricsJSON))))
 
 	// Analytics endpoints
-	mux.HandleFunc("/analytics/stats", p.handleAnalyticsStats)
-	mux.HandleFunc("/analytics/stats/enhanced", p.handleAnalyticsStatsEnhanced)
-	mux.HandleFunc("/analytics/search", p.handleAnalyticsSearch)
-	mux.HandleFunc("/analytics/messages", p.handleAnalyticsMessages)
-	mux.HandleFunc("/analytics/messages/", p.handleAnalyticsMessageDetail)
-	mux.HandleFunc("/analytics/models", p.handleAnalyticsModels)
-	mux.HandleFunc("/analytics/export", p.handleAnalyticsExport)
-	mux.HandleFunc("/analytics", p.handleAnalyticsDashboard)
-	mux.HandleFunc("/analytics/", p.handleAnalyticsDashboard)
-
-	// Test endpoint
-	mux.HandleFunc("/test", p.handleTest)
+	mux.HandleFunc("/analytics/stats", p.cors.wrap(p.requireAdmin(p.handleAnalyticsStats)))
+	mux.HandleFunc("/analytics/stats/enhanced", p.cors.wrap(p.requireAdmin(p.handleAnalyticsStatsEnhanced)))
+	mux.HandleFunc("/analytics/timeseries", p.cors.wrap(p.requireAdmin(withTimeRange(p.handleAnalyticsTimeseries))))
+	mux.HandleFunc("/analytics/compare", p.cors.wrap(p.requireAdmin(p.handleAnalyticsCompare)))
+	mux.HandleFunc("/analytics/costs", p.cors.wrap(p.requireAdmin(withTimeRange(p.handleAnalyticsCosts))))
+	mux.HandleFunc("/analytics/search", p.cors.wrap(p.requireAdmin(withTimeRange(gzipHandler(p.handleAnalyticsSearch)))))
+	mux.HandleFunc("/analytics/messages", p.cors.wrap(p.requireAdmin(withTimeRange(gzipHandler(p.handleAnalyticsMessages)))))
+	mux.HandleFunc("/analytics/messages/", p.cors.wrap(p.requireAdmin(p.handleAnalyticsMessageDetail)))
+	mux.HandleFunc("/analytics/models", p.cors.wrap(p.requireAdmin(p.handleAnalyticsModels)))
+	mux.HandleFunc("/analytics/models/stats", p.cors.wrap(p.requireAdmin(p.handleAnalyticsModelsStats)))
+	handleOptional("/analytics/export", "ENABLE_EXPORT", p.cors.wrap(p.requireAdmin(withTimeRange(gzipHandler(p.handleAnalyticsExport)))))
+	mux.HandleFunc("/analytics/live", p.cors.wrap(p.requireAdmin(p.handleAnalyticsLive)))
+	mux.HandleFunc("/analytics/recent", p.cors.wrap(p.requireAdmin(p.handleAnalyticsRecent)))
+	mux.HandleFunc("/analytics/purge", p.cors.wrap(p.requireAdmin(p.handleAnalyticsPurge)))
+	mux.HandleFunc("/analytics/ingest", p.cors.wrap(p.requireAdmin(p.handleAnalyticsIngest)))
+	mux.HandleFunc("/analytics/errors", p.cors.wrap(p.requireAdmin(p.handleAnalyticsErrors)))
+	mux.HandleFunc("/analytics/categorize", p.cors.wrap(p.requireAdmin(p.handleCategorize)))
+	mux.HandleFunc("/admin/reload", p.requireAdmin(p.handleAdminReload))
+	mux.HandleFunc("/admin/pull", p.requireAdmin(p.handleAdminPull))
+	mux.HandleFunc("/admin/analytics/pause", p.requireAdmin(p.handleAdminAnalyticsPause))
+	mux.HandleFunc("/admin/analytics/resume", p.requireAdmin(p.handleAdminAnalyticsResume))
+	handleOptional("/analytics", "ENABLE_DASHBOARD", p.requireAdmin(p.handleAnalyticsDashboard))
+	handleOptional("/analytics/", "ENABLE_DASHBOARD", p.requireAdmin(p.handleAnalyticsDashboard))
+
+	// Test endpoint (sends a request to Ollama)
+	handleOptional("/test", "ENABLE_TEST_ENDPOINT", p.handleTest)
+
+	// Health probes
+	mux.HandleFunc("/health", p.handleHealth)
+	mux.HandleFunc("/ready", p.handleReady)
+	mux.HandleFunc("/status", p.cors.wrap(p.requireAdmin(p.handleStatus)))
 
 	// Proxy all other requests
 	mux.HandleFunc("/", p.handleProxy)
 
 	// Create HTTP server with proper timeouts for graceful shutdown
 	p.server = &http.Server{
-		Addr:         fmt.Sprintf(":%d", p.port),
+		Addr:         net.JoinHostPort(p.bindAddr, strconv.Itoa(p.port)),
 		Handler:      mux,
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 90 * time.Second,  // Long timeout for streaming responses
 		IdleTimeout:  120 * time.Second,
 	}
 
-	log.Printf("Starting Ollama Proxy on port %d", p.port)
+	log.Printf("Starting Ollama Proxy on %s", p.server.Addr)
 	log.Printf("Proxying to Ollama at %s", p.target)
 	log.Printf("Metrics: http://localhost:%d/metrics", p.port)
 	log.Printf("Analytics Dashboard: http://localhost:%d/analytics", p.port)
@@ -171,23 +405,67 @@ func (p *Proxy) Start() error {
 	return p.server.ListenAndServe()
 }
 
+// endpointEnabled reports whether an optional endpoint is served. Endpoints
+// are enabled unless their toggle variable is set to "false".
+func endpointEnabled(toggle string) bool {
+	return !strings.EqualFold(strings.TrimSpace(os.Getenv(toggle)), "false")
+}
+
 // Shutdown gracefully shuts down the proxy
 func (p *Proxy) Shutdown() {
 	log.Printf("Shutting down proxy...")
 	slog.Info("Initiating proxy shutdown")
 
-	// Gracefully shutdown HTTP server with timeout
+	// Gracefully shutdown HTTP server, giving in-flight streams up to drainTimeout to finish
 	if p.server != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		p.draining.Store(true)
+
+		// End live feed connections so they don't hold up the drain
+		if p.analytics != nil {
+			p.analytics.live.closeAll()
+		}
+
+		inFlight := p.streamCount.Load()
+		if inFlight > 0 {
+			log.Printf("Draining %d in-flight streaming requests (timeout %s)", inFlight, p.drainTimeout)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), p.drainTimeout)
 		defer cancel()
 
 		if err := p.server.Shutdown(ctx); err != nil {
 			log.Printf("Proxy shutdown error: %v", err)
 			slog.Error("HTTP server shutdown failed", "error", err)
+
+			// Drain timed out: force-close connections so the remaining streams
+			// are closed and record their metrics before analytics shuts down
+			p.server.Close()
+			p.waitForStreams(5 * time.Second)
 		} else {
 			log.Printf("HTTP server shutdown complete")
 			slog.Info("HTTP server shutdown complete")
 		}
+
+		aborted := p.streamCount.Load()
+		if aborted < 0 {
+			aborted = 0
+		}
+		if inFlight > 0 || aborted > 0 {
+			drained := inFlight - aborted
+			if drained < 0 {
+				drained = 0
+			}
+			log.Printf("Shutdown drain: %d streams completed, %d aborted", drained, aborted)
+			slog.Info("Shutdown drain finished", "drained", drained, "aborted", aborted)
+		}
+	}
+
+	p.idle.close()
+	p.pusher.close()
+
+	// Flush the audit log
+	if p.audit != nil {
+		p.audit.Close()
 	}
 
 	// Close analytics (flushes write queue and closes database)
@@ -200,17 +478,67 @@ func (p *Proxy) Shutdown() {
 	slog.Info("Proxy shutdown complete")
 }
 
+// waitForStreams waits up to timeout for all tracked streams to close
+func (p *Proxy) waitForStreams(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		p.activeStreams.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Printf("Warning: %d streams still open after forced shutdown", p.streamCount.Load())
+	}
+}
+
 // handleProxy processes and forwards requests
 func (p *Proxy) handleProxy(w http.ResponseWriter, r *http.Request) {
+	// Tag the request, its response, the upstream call and every log line with one ID
+	requestID := requestIDFor(r)
+	r.Header.Set(requestIDHeader, requestID)
+	w.Header().Set(requestIDHeader, requestID)
+
 	// Check if client already disconnected before processing
 	select {
 	case <-r.Context().Done():
-		log.Printf("Client disconnected before proxy processing: %s", r.RemoteAddr)
+		log.Printf("[%s] Client disconnected before proxy processing: %s", requestID, r.RemoteAddr)
 		return
 	default:
 	}
 
+	// Reject requests from filtered client addresses
+	if !p.checkClientIP(w, r) {
+		return
+	}
+
+	// Reject requests without a valid API key before doing any work
+	if !p.authorizeProxyRequest(r) {
+		p.recordUnauthorized(r)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="ollama-proxy"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Throttle clients over their per-IP rate before they take a concurrency slot
+	if !p.checkRateLimit(w, r) {
+		return
+	}
+
+	// Serve repeated model list polls from memory
+	if isTagsRequest(r) && p.tags.serve(w) {
+		return
+	}
+
+	// Pulls and deletes change the model list: drop the cached list now and
+	// again once the request finishes, since polls may re-cache it meanwhile
+	if changesModelList(r) {
+		p.tags.invalidate()
+		defer p.tags.invalidate()
+	}
+
 	// Acquire semaphore slot for rate limiting
+	queueStart := time.Now()
 	select {
 	case p.maxConcurrent <- struct{}{}:
 		// Got a slot, continue processing
@@ -221,42 +549,157 @@ func (p *Proxy) handleProxy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	queueWait := time.Since(queueStart).Seconds()
+	p.metrics.queueWait.Observe(queueWait)
+
 	startTime := time.Now()
 
 	// Parse request for metrics
 	var body []byte
+	var modelAlias string
+	var optionsDefaulted, optionsForced []string
+	var streamDowngraded bool
+	var streamed *streamedBody
 	if r.Method == "POST" || r.Method == "PUT" || r.Method == "PATCH" {
-		body, _ = io.ReadAll(r.Body)
-		r.Body = io.NopCloser(bytes.NewReader(body))
-		r.ContentLength = int64(len(body))
-		r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+		// Cap the body size so a huge request can't exhaust memory
+		limited := http.MaxBytesReader(w, r.Body, p.maxRequestBody)
+		var err error
+		var bodyStreamed bool
+		body, bodyStreamed, err = p.readRequestBody(r.ContentLength, limited)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				log.Printf("[%s] [%s] Request body exceeds %d bytes, rejecting", requestID, r.RemoteAddr, p.maxRequestBody)
+				http.Error(w, fmt.Sprintf("Request body too large (max %d bytes)", p.maxRequestBody), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		if bodyStreamed {
+			// Forward the large body as it arrives; body keeps only its start for
+			// metrics, so the request is passed through without any rewriting
+			streamed = streamRequestBody(body, limited)
+			r.Body = streamed
+			r.GetBody = nil
+			log.Printf("[%s] Request body exceeds %d bytes, streaming it to Ollama", requestID, p.streamRequestBody)
+		} else {
+			body, modelAlias = p.rewriteModelAlias(body)
+			if usesOptions(r.URL.Path) {
+				body, optionsDefaulted, optionsForced = p.defaultOptions.apply(body)
+			}
+			if strings.HasPrefix(r.URL.Path, "/api/") && !supportsFlush(w) &&
+				isStreamingRequest(strings.TrimPrefix(r.URL.Path, "/api/"), body) {
+				// The stream would reach the client all at once anyway; ask Ollama for
+				// a single response, which NDJSON clients still parse as one line
+				if rewritten, ok := disableStreaming(body); ok {
+					body, streamDowngraded = rewritten, true
+					log.Printf("[%s] Response writer can't flush, forcing stream:false for %s", requestID, r.URL.Path)
+				}
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			r.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(body)), nil
+			}
+			r.ContentLength = int64(len(body))
+			r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+		}
 	}
 
-	model, prompt, endpoint := p.parseRequest(r, body)
+	model, prompt, endpoint, tools, images, chatTurns := p.parseRequest(r, body)
 	promptCategory := p.metrics.categorizer.Categorize(prompt)
+	var messages []ChatMessage
+	if p.storeFullMessages || p.audit != nil {
+		messages = chatMessages(body)
+	}
 
-	// Track active requests
-	p.metrics.activeRequests.Inc()
-	defer p.metrics.activeRequests.Dec()
+	// Observed before forwarding so requests that fail upstream still count;
+	// a streamed body's size is only known once it has been forwarded
+	if body != nil && shouldTrackEndpoint(endpoint) {
+		promptBytes := p.metrics.promptBytes.WithLabelValues(p.models.label(model), endpoint)
+		if streamed != nil {
+			defer func() { promptBytes.Observe(float64(streamed.size.Load())) }()
+		} else {
+			promptBytes.Observe(float64(len(body)))
+		}
+	}
+	if isEmbeddingEndpoint(endpoint) {
+		// Keep embeddings out of the generation categories and latency histograms
+		promptCategory = "embedding"
+	} else if len(tools) > 0 && p.toolCallCategory != "" {
+		// Tool-enabled chats are agent traffic rather than what the last message says
+		promptCategory = p.toolCallCategory
+	}
 
 	// Log the request with client IP
 	clientIP := r.RemoteAddr
 	if xForwardedFor := r.Header.Get("X-Forwarded-For"); xForwardedFor != "" {
 		clientIP = xForwardedFor + " (via " + r.RemoteAddr + ")"
 	}
-	log.Printf("[%s] Proxying %s %s to %s%s (model: %s, category: %s)", 
-		clientIP, r.Method, r.URL.Path, p.target, r.URL.Path, model, promptCategory)
+	slog.Info("Proxying request",
+		"request_id", requestID,
+		"client_ip", clientIP,
+		"method", r.Method,
+		"path", r.URL.Path,
+		"target", p.target.String(),
+		"model", model,
+		"endpoint", endpoint,
+		"category", promptCategory,
+	)
+
+	// Cap how long the upstream request may run. Cancelling the context
+	// aborts the upstream call, and errorHandler turns it into a 504.
+	streaming := isStreamingRequest(endpoint, body)
+	if streaming && !supportsFlush(w) {
+		log.Printf("[%s] Warning: Response writer can't flush, streamed response for %s will be buffered", requestID, r.URL.Path)
+	}
+	timeout := p.requestTimeout
+	if streaming || streamDowngraded {
+		timeout = p.streamTimeout
+	}
+
+	// Track active requests; streamed generations hold the GPU for their
+	// whole duration, unlike quick calls such as /api/tags
+	activeType := "nonstreaming"
+	if streaming || streamDowngraded {
+		activeType = "streaming"
+	}
+	p.metrics.activeRequests.WithLabelValues(activeType).Inc()
+	defer p.metrics.activeRequests.WithLabelValues(activeType).Dec()
+
+	reqCtx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	r = r.WithContext(reqCtx)
+
+	// Let the request timeout, not the server's WriteTimeout, bound the response
+	// (with a little slack so the 504 can still be written)
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout + 5*time.Second)); err != nil {
+		log.Printf("[%s] Warning: Could not set write deadline for %s: %v", requestID, r.URL.Path, err)
+	}
 
 	// Create context for metrics collection
 	ctx := &ProxyContext{
-		StartTime:      startTime,
-		Model:          model,
-		Prompt:         prompt,
-		Endpoint:       endpoint,
-		PromptCategory: promptCategory,
-		Writer:         w,
-		Request:        r,
-		ClientIP:       clientIP,
+		StartTime:        startTime,
+		Model:            model,
+		Prompt:           prompt,
+		Endpoint:         endpoint,
+		PromptCategory:   promptCategory,
+		Writer:           w,
+		Request:          r,
+		ClientIP:         clientIP,
+		RequestID:        requestID,
+		User:             p.requestUser(r),
+		Tag:              p.requestTag(r),
+		ModelAlias:       modelAlias,
+		OptionsDefaulted: optionsDefaulted,
+		OptionsForced:    optionsForced,
+		Messages:         messages,
+		Tools:            tools,
+		Images:           images,
+		ChatTurns:        chatTurns,
+		Streaming:        streaming,
+		QueueTime:        queueWait,
+		StreamDowngraded: streamDowngraded,
 	}
 
 	// Store context for response processing
@@ -268,8 +711,46 @@ func (p *Proxy) handleProxy(w http.ResponseWriter, r *http.Request) {
 		serviceMode:    IsRunningAsService(),
 	}
 	
-	// Forward the request
-	p.reverseProxy.ServeHTTP(wrapped, r)
+	// Streamed bodies were never read whole, so they can't be cached or coalesced
+	keyBody := body
+	if streamed != nil {
+		keyBody = nil
+	}
+
+	// Serve repeated deterministic requests from the response cache
+	cacheKey, cacheable := p.responseCache.key(r.URL.Path, endpoint, keyBody, streaming || streamDowngraded)
+	if cacheable && p.serveCachedResponse(wrapped, ctx, cacheKey) {
+		return
+	}
+
+	// Bring Ollama back if it was stopped for being idle
+	coldStart, err := p.idle.acquire(requestID)
+	if err != nil {
+		message := fmt.Sprintf("Failed to start Ollama: %v", err)
+		http.Error(w, message, http.StatusServiceUnavailable)
+		p.recordMetrics(ctx, time.Since(ctx.StartTime).Seconds(), 0, 0, http.StatusServiceUnavailable, message)
+		return
+	}
+	defer p.idle.release()
+	ctx.ColdStart = coldStart.Seconds()
+
+	// Forward the request, sharing the response with identical concurrent
+	// requests when single-flight is enabled and keeping a copy for the cache
+	var out http.ResponseWriter = wrapped
+	var cacheRec *flightRecorder
+	if cacheable {
+		cacheRec = &flightRecorder{ResponseWriter: wrapped, limit: p.maxResponseCapture}
+		out = cacheRec
+	}
+	if key, ok := p.singleFlight.key(r.URL.Path, endpoint, keyBody, streaming || streamDowngraded); ok {
+		p.forwardSingleFlight(out, r, ctx, key)
+	} else {
+		p.reverseProxy.ServeHTTP(out, r)
+	}
+	if cacheRec != nil {
+		cacheRec.complete = true
+		p.responseCache.put(cacheKey, cacheRec)
+	}
 	
 	// Ensure final flush in service mode
 	if wrapped.serviceMode {
@@ -283,9 +764,16 @@ func (p *Proxy) handleProxy(w http.ResponseWriter, r *http.Request) {
 func (p *Proxy) modifyResponse(resp *http.Response) error {
 	// Log response received from upstream
 	if IsRunningAsService() {
-		LogPrintf("modifyResponse: Got response %d from upstream for %s", resp.StatusCode, resp.Request.URL.Path)
+		LogPrintf("[%s] modifyResponse: Got response %d from upstream for %s", resp.Request.Header.Get(requestIDHeader), resp.StatusCode, resp.Request.URL.Path)
 	}
 	
+	if resp.StatusCode < 500 {
+		p.markUpstreamContact()
+	}
+
+	// handleProxy already set our X-Request-Id; don't let an upstream echo duplicate it
+	resp.Header.Del(requestIDHeader)
+
 	ctx := getProxyContext(resp.Request.Context())
 	if ctx == nil {
 		if IsRunningAsService() {
@@ -294,22 +782,34 @@ func (p *Proxy) modifyResponse(resp *http.Response) error {
 		return nil
 	}
 
-	// For streaming responses, we need to wrap the body
-	if strings.Contains(resp.Header.Get("Content-Type"), "application/x-ndjson") ||
+	// For streaming responses, we need to wrap the body. Error responses are
+	// a single small JSON object, so they always take the non-streaming path.
+	if resp.StatusCode < 400 && (strings.Contains(resp.Header.Get("Content-Type"), "application/x-ndjson") ||
+		strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream") ||
 		strings.Contains(resp.Request.URL.Path, "/generate") ||
-		strings.Contains(resp.Request.URL.Path, "/chat") {
+		strings.Contains(resp.Request.URL.Path, "/chat")) {
 		
 		// Wrap the response body for streaming metrics collection
+		// and track it so shutdown can drain in-flight streams
+		p.activeStreams.Add(1)
+		p.streamCount.Add(1)
 		resp.Body = &streamingResponseBody{
 			ReadCloser: resp.Body,
 			proxy:      p,
 			ctx:        ctx,
+			statusCode: resp.StatusCode,
 		}
 	} else {
 		// For non-streaming responses, read and process
 		body, err := io.ReadAll(resp.Body)
 		if err == nil {
 			resp.Body = io.NopCloser(bytes.NewReader(body))
+			ctx.ResponseBytes = len(body)
+
+			if resp.StatusCode == http.StatusOK && isTagsRequest(resp.Request) {
+				p.tags.store(resp.Header, body)
+				resp.Header.Set("X-Proxy-Cache", "MISS")
+			}
 			
 			// Extract metrics from response
 			p.processNonStreamingResponse(ctx, body, resp.StatusCode)
@@ -322,9 +822,30 @@ func (p *Proxy) modifyResponse(resp *http.Response) error {
 // errorHandler handles proxy errors
 func (p *Proxy) errorHandler(w http.ResponseWriter, r *http.Request, err error) {
 	ctx := getProxyContext(r.Context())
+
+	status := http.StatusBadGateway
+	message := fmt.Sprintf("Proxy error: %v", err)
+	var maxBytesErr *http.MaxBytesError
+	if errors.Is(err, context.DeadlineExceeded) {
+		status = http.StatusGatewayTimeout
+		message = "Upstream request timed out"
+	} else if errors.As(err, &maxBytesErr) {
+		// A streamed request body went over MAX_REQUEST_BODY_BYTES mid-forward
+		status = http.StatusRequestEntityTooLarge
+		message = fmt.Sprintf("Request body too large (max %d bytes)", maxBytesErr.Limit)
+	} else if errors.Is(err, errCircuitOpen) {
+		// Fail fast without touching Ollama; tell clients when to try again
+		status = http.StatusServiceUnavailable
+		message = "Ollama is unavailable (circuit breaker open), retry later"
+		w.Header().Set("Retry-After", strconv.Itoa(int(p.breaker.retryAfter().Seconds())+1))
+	} else if errors.Is(err, context.Canceled) && r.Context().Err() != nil {
+		// The client went away before Ollama responded; the upstream call is already cancelled
+		status = StatusClientClosedRequest
+	}
+
 	if ctx != nil {
 		duration := time.Since(ctx.StartTime).Seconds()
-		p.recordMetrics(ctx, duration, 0, 0, 500, err.Error())
+		p.recordMetrics(ctx, duration, 0, 0, status, err.Error())
 	}
 
 	clientIP := "unknown"
@@ -333,41 +854,138 @@ func (p *Proxy) errorHandler(w http.ResponseWriter, r *http.Request, err error)
 	} else {
 		clientIP = r.RemoteAddr
 	}
-	log.Printf("[%s] Proxy error for %s %s: %v", clientIP, r.Method, r.URL.Path, err)
-	http.Error(w, fmt.Sprintf("Proxy error: %v", err), http.StatusBadGateway)
+
+	if status == StatusClientClosedRequest {
+		slog.Info("Client disconnected before upstream responded",
+			"request_id", r.Header.Get(requestIDHeader),
+			"client_ip", clientIP,
+			"method", r.Method,
+			"path", r.URL.Path,
+		)
+		return
+	}
+
+	slog.Error("Proxy error",
+		"request_id", r.Header.Get(requestIDHeader),
+		"client_ip", clientIP,
+		"method", r.Method,
+		"path", r.URL.Path,
+		"error_type", classifyError(status, err.Error()),
+		"error", err,
+	)
+	http.Error(w, message, status)
 }
 
-// parseRequest extracts model, prompt, and endpoint from request
-func (p *Proxy) parseRequest(r *http.Request, body []byte) (model, prompt, endpoint string) {
+// parseRequest extracts model, prompt, endpoint, and any offered tool names from request
+func (p *Proxy) parseRequest(r *http.Request, body []byte) (model, prompt, endpoint string, tools []string, images imageStats, turns int) {
 	model = "unknown"
 	prompt = ""
 	endpoint = strings.TrimPrefix(r.URL.Path, "/")
 
 	if len(body) > 0 {
-		var data map[string]interface{}
-		if err := json.Unmarshal(body, &data); err == nil {
+		if data, ok := decodeRequestJSON(body); ok {
 			if m, ok := data["model"].(string); ok {
 				model = m
 			}
-			if p, ok := data["prompt"].(string); ok {
+			tools = declaredTools(data)
+			images = requestImages(data)
+			if isEmbeddingEndpoint(endpoint) {
+				prompt = embeddingInput(data)
+			} else if p, ok := data["prompt"].(string); ok {
 				prompt = p
 			} else if messages, ok := data["messages"].([]interface{}); ok && len(messages) > 0 {
 				// Extract prompt from messages array - bounds check already done above
 				if lastMsg, ok := messages[len(messages)-1].(map[string]interface{}); ok {
-					if content, ok := lastMsg["content"].(string); ok {
+					if content, ok := messageContent(lastMsg); ok {
 						prompt = content
 					}
 				}
 			}
+			if messages, ok := data["messages"].([]interface{}); ok && isChatEndpoint(endpoint) {
+				turns = len(messages)
+			}
 		}
 	}
 
 	// Clean up endpoint
 	if strings.HasPrefix(endpoint, "api/") {
 		endpoint = strings.TrimPrefix(endpoint, "api/")
+	} else if strings.HasPrefix(endpoint, "v1/") {
+		// OpenAI-compatible endpoints, e.g. v1/chat/completions -> chat/completions
+		endpoint = strings.TrimPrefix(endpoint, "v1/")
 	}
 
-	return model, prompt, endpoint
+	return model, prompt, endpoint, tools, images, turns
+}
+
+// isChatEndpoint reports whether the endpoint takes a multi-turn "messages"
+// conversation. Ollama's chat is labelled "chat" and the OpenAI-compatible one
+// "chat/completions"; their completion counterparts are "generate" and "completions".
+func isChatEndpoint(endpoint string) bool {
+	switch strings.TrimPrefix(strings.TrimPrefix(endpoint, "api/"), "v1/") {
+	case "chat", "chat/completions":
+		return true
+	}
+	return false
+}
+
+// isEmbeddingEndpoint reports whether the endpoint returns embeddings instead of generated tokens
+func isEmbeddingEndpoint(endpoint string) bool {
+	switch strings.TrimPrefix(strings.TrimPrefix(endpoint, "api/"), "v1/") {
+	case "embeddings", "embed":
+		return true
+	}
+	return false
+}
+
+// embeddingInput extracts the text being embedded. /api/embeddings sends a
+// single "prompt", /api/embed sends "input" as a string or an array of strings.
+func embeddingInput(data map[string]interface{}) string {
+	if prompt, ok := data["prompt"].(string); ok {
+		return prompt
+	}
+	switch input := data["input"].(type) {
+	case string:
+		return input
+	case []interface{}:
+		parts := make([]string, 0, len(input))
+		for _, item := range input {
+			if text, ok := item.(string); ok {
+				parts = append(parts, text)
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return ""
+}
+
+// isStreamingRequest reports whether the client expects a streamed response.
+// Ollama streams by default unless the body sets "stream": false.
+func isStreamingRequest(endpoint string, body []byte) bool {
+	if data, ok := decodeRequestJSON(body); ok {
+		if stream, ok := data["stream"].(bool); ok {
+			return stream
+		}
+	}
+	switch endpoint {
+	case "generate", "chat", "pull", "push", "create":
+		return true
+	}
+	return false
+}
+
+// disableStreaming sets "stream": false in a JSON request body
+func disableStreaming(body []byte) ([]byte, bool) {
+	var data map[string]json.RawMessage
+	if err := json.Unmarshal(body, &data); err != nil {
+		return body, false
+	}
+	data["stream"] = json.RawMessage("false")
+	rewritten, err := json.Marshal(data)
+	if err != nil {
+		return body, false
+	}
+	return rewritten, true
 }
 
 // processNonStreamingResponse handles metrics for non-streaming responses
@@ -378,9 +996,16 @@ func (p *Proxy) processNonStreamingResponse(ctx *ProxyContext, body []byte, stat
 	tokens := 0
 	promptTokens := 0
 	tokensPerSecond := 0.0
+	errorMsg := ""
 	
 	var data map[string]interface{}
 	if err := json.Unmarshal(body, &data); err == nil {
+		// Surface Ollama's error message for failed requests
+		if statusCode >= 400 {
+			errorMsg = upstreamErrorMessage(data)
+			ctx.ErrorCategory = categorizeUpstreamError(errorMsg)
+		}
+
 		// Extract generated tokens
 		if evalCount, ok := data["eval_count"].(float64); ok {
 			tokens = int(evalCount)
@@ -395,27 +1020,130 @@ func (p *Proxy) processNonStreamingResponse(ctx *ProxyContext, body []byte, stat
 		if promptEvalCount, ok := data["prompt_eval_count"].(float64); ok {
 			promptTokens = int(promptEvalCount)
 		}
+
+		// OpenAI-compatible responses report tokens in a "usage" object instead
+		if usagePrompt, usageCompletion, ok := openAIUsage(data); ok {
+			promptTokens = usagePrompt
+			tokens = usageCompletion
+			if tokens > 0 && duration > 0 {
+				tokensPerSecond = float64(tokens) / duration
+			}
+		}
 		
 		// Store additional metrics in context for analytics
 		ctx.PromptTokens = promptTokens
 		if loadDuration, ok := data["load_duration"].(float64); ok {
 			ctx.LoadDuration = loadDuration / 1e9
+			p.observeModelLoad(ctx)
 		}
 		if totalDuration, ok := data["total_duration"].(float64); ok {
 			ctx.TotalDuration = totalDuration / 1e9
 		}
+		ctx.Timings = ollamaTimings(data)
 		
+		// Embedding responses carry vectors instead of eval_count
+		if isEmbeddingEndpoint(ctx.Endpoint) {
+			if embeddings, ok := data["embeddings"].([]interface{}); ok {
+				ctx.EmbeddingCount = len(embeddings)
+				if len(embeddings) > 0 {
+					if first, ok := embeddings[0].([]interface{}); ok {
+						ctx.EmbeddingDimensions = len(first)
+					}
+				}
+			} else if embedding, ok := data["embedding"].([]interface{}); ok {
+				ctx.EmbeddingCount = 1
+				ctx.EmbeddingDimensions = len(embedding)
+			}
+		}
+
 		// Extract response content for preview
 		if response, ok := data["response"].(string); ok {
-			ctx.ResponsePreview = truncate(response, 200)
+			ctx.ResponseText = response
 		} else if message, ok := data["message"].(map[string]interface{}); ok {
 			if content, ok := message["content"].(string); ok {
-				ctx.ResponsePreview = truncate(content, 200)
+				ctx.ResponseText = content
+			}
+		} else if text, ok := openAIChoiceText(data); ok {
+			ctx.ResponseText = text
+		}
+		ctx.ResponsePreview = truncate(ctx.ResponseText, p.analytics.responsePreviewLen)
+		if len(ctx.Tools) > 0 {
+			ctx.ToolCalls = invokedTools(data)
+		}
+	}
+
+	p.recordMetrics(ctx, duration, tokens, tokensPerSecond, statusCode, errorMsg)
+}
+
+// observeModelLoad records a model load reported by Ollama. A nonzero
+// load_duration means the request paid for loading the model into memory.
+func (p *Proxy) observeModelLoad(ctx *ProxyContext) {
+	if ctx.LoadDuration <= 0 || ctx.reusedResponse() {
+		return
+	}
+	modelLabel := p.models.label(ctx.Model)
+	p.metrics.modelLoadSeconds.WithLabelValues(modelLabel).Observe(ctx.LoadDuration)
+	p.metrics.modelLoads.WithLabelValues(modelLabel).Inc()
+}
+
+// ollamaTimingFields are the nanosecond durations Ollama reports on a final response
+var ollamaTimingFields = []string{"total_duration", "load_duration", "prompt_eval_duration", "eval_duration"}
+
+// ollamaTimings returns the raw nanosecond timing fields present in data
+func ollamaTimings(data map[string]interface{}) map[string]int64 {
+	var timings map[string]int64
+	for _, field := range ollamaTimingFields {
+		if ns, ok := data[field].(float64); ok {
+			if timings == nil {
+				timings = make(map[string]int64, len(ollamaTimingFields))
 			}
+			timings[field] = int64(ns)
 		}
 	}
+	return timings
+}
 
-	p.recordMetrics(ctx, duration, tokens, tokensPerSecond, statusCode, "")
+// promptEvalRate returns prefill speed in prompt tokens per second, which
+// eval-based tokens/sec (decode speed) doesn't show, or 0 if unknown
+func promptEvalRate(ctx *ProxyContext) float64 {
+	ns := ctx.Timings["prompt_eval_duration"]
+	if ctx.PromptTokens <= 0 || ns <= 0 {
+		return 0
+	}
+	return float64(ctx.PromptTokens) / (float64(ns) / 1e9)
+}
+
+// upstreamErrorMessage extracts the error from an Ollama ({"error": "..."})
+// or OpenAI-style ({"error": {"message": "..."}}) error body
+func upstreamErrorMessage(data map[string]interface{}) string {
+	switch e := data["error"].(type) {
+	case string:
+		return e
+	case map[string]interface{}:
+		if message, ok := e["message"].(string); ok {
+			return message
+		}
+	}
+	return ""
+}
+
+// categorizeUpstreamError maps common Ollama error messages to a short category
+func categorizeUpstreamError(msg string) string {
+	lower := strings.ToLower(msg)
+	switch {
+	case lower == "":
+		return ""
+	case strings.Contains(lower, "not found") && strings.Contains(lower, "model"):
+		return "model_not_found"
+	case strings.Contains(lower, "out of memory") || strings.Contains(lower, "more system memory") ||
+		strings.Contains(lower, "insufficient memory"):
+		return "out_of_memory"
+	case strings.Contains(lower, "context length") || strings.Contains(lower, "context window"):
+		return "context_length"
+	case strings.Contains(lower, "invalid") || strings.Contains(lower, "required"):
+		return "invalid_request"
+	}
+	return "other"
 }
 
 // shouldTrackEndpoint determines if an endpoint should be tracked in analytics
@@ -433,7 +1161,7 @@ func shouldTrackEndpoint(endpoint string) bool {
 	switch normalized {
 	case "generate", "chat":
 		return true
-	case "embeddings":
+	case "embeddings", "embed":
 		// Embeddings are tracked but don't generate completion tokens
 		// Can be disabled via environment variable if desired
 		trackEmbeddings := os.Getenv("TRACK_EMBEDDINGS")
@@ -448,31 +1176,106 @@ func shouldTrackEndpoint(endpoint string) bool {
 	}
 }
 
+// classifyError maps a failed request to a normalized error_type label:
+// cancelled, circuit_open, timeout, connection_refused, bad_gateway, upstream_5xx or client_4xx.
+// Returns an empty string for successful requests.
+func classifyError(statusCode int, errorMsg string) string {
+	msg := strings.ToLower(errorMsg)
+	switch {
+	case statusCode == StatusClientClosedRequest:
+		return "cancelled"
+	case strings.Contains(msg, "circuit breaker open"):
+		return "circuit_open"
+	case strings.Contains(msg, "timeout") || strings.Contains(msg, "deadline exceeded"):
+		return "timeout"
+	case strings.Contains(msg, "connection refused") || strings.Contains(msg, "actively refused"):
+		return "connection_refused"
+	case statusCode == http.StatusBadGateway:
+		return "bad_gateway"
+	case statusCode >= 500:
+		return "upstream_5xx"
+	case statusCode >= 400:
+		return "client_4xx"
+	case errorMsg != "":
+		// Transport error without a status from upstream
+		return "bad_gateway"
+	}
+	return ""
+}
+
 // recordMetrics records both Prometheus metrics and analytics
 func (p *Proxy) recordMetrics(ctx *ProxyContext, duration float64, tokens int, tokensPerSecond float64, statusCode int, errorMsg string) {
 	// Filter out non-inference endpoints to prevent pollution of analytics
 	if !shouldTrackEndpoint(ctx.Endpoint) {
 		// Log but don't record metrics for non-inference endpoints
-		log.Printf("[%s] Skipping analytics for non-inference endpoint: %s", ctx.ClientIP, ctx.Endpoint)
+		log.Printf("[%s] [%s] Skipping analytics for non-inference endpoint: %s", ctx.RequestID, ctx.ClientIP, ctx.Endpoint)
 		return
 	}
 
 	// Update Prometheus metrics (client_ip removed from labels to prevent cardinality explosion)
-	// Client IP is still tracked in analytics SQLite database for detailed analysis
-	p.metrics.requestDuration.WithLabelValues(ctx.Model, ctx.Endpoint, ctx.PromptCategory).Observe(duration)
+	// Client IP is still tracked in analytics SQLite database for detailed analysis.
+	// Models Ollama doesn't know are labelled "unknown"; the raw name is kept in analytics.
+	modelLabel := p.models.label(ctx.Model)
+	p.metrics.requestDuration.WithLabelValues(modelLabel, ctx.Endpoint, ctx.PromptCategory).Observe(duration)
+	if ctx.ResponseBytes > 0 {
+		p.metrics.responseBytes.WithLabelValues(modelLabel, ctx.Endpoint).Observe(float64(ctx.ResponseBytes))
+	}
+	if ctx.ChatTurns > 0 {
+		p.metrics.chatTurns.WithLabelValues(modelLabel, ctx.Endpoint).Observe(float64(ctx.ChatTurns))
+	}
 
 	status := "success"
-	if statusCode >= 400 {
+	if statusCode == StatusClientClosedRequest {
+		status = "cancelled"
+	} else if statusCode >= 400 {
 		status = "error"
 	} else if errorMsg != "" {
 		status = "error"
 	}
-	p.metrics.requestsTotal.WithLabelValues(ctx.Model, ctx.Endpoint, ctx.PromptCategory, status).Inc()
+	if ctx.TruncatedReason != "" {
+		// Work was done but the generation never finished. A client that
+		// hung up keeps the cancelled status; the reason still says why.
+		if status != "cancelled" {
+			status = "truncated"
+		}
+		p.metrics.truncatedStreams.WithLabelValues(modelLabel, ctx.TruncatedReason).Inc()
+	}
+	if ctx.CacheHit && status == "success" {
+		status = "cache_hit"
+	}
+	p.metrics.requestsTotal.WithLabelValues(modelLabel, ctx.Endpoint, ctx.PromptCategory, status).Inc()
+	p.metrics.recent.add(duration, status == "error")
+
+	slowOutlier := status == "success" && p.latency.observe(modelLabel, duration)
+	if slowOutlier {
+		p.metrics.slowOutliers.WithLabelValues(modelLabel).Inc()
+	}
+
+	if errorType := classifyError(statusCode, errorMsg); errorType != "" {
+		p.metrics.requestErrors.WithLabelValues(modelLabel, ctx.Endpoint, errorType).Inc()
+	}
+
+	if ctx.TimeToFirstToken > 0 {
+		p.metrics.timeToFirstToken.WithLabelValues(modelLabel, ctx.PromptCategory).Observe(ctx.TimeToFirstToken)
+	}
 
-	if tokens > 0 {
-		p.metrics.tokensGenerated.WithLabelValues(ctx.Model, ctx.PromptCategory).Observe(float64(tokens))
+	for _, name := range ctx.ToolCalls {
+		p.metrics.toolCalls.WithLabelValues(modelLabel, toolLabel(name, ctx.Tools)).Inc()
+	}
+	if ctx.Images.Count > 0 {
+		p.metrics.multimodalRequests.WithLabelValues(modelLabel).Inc()
+	}
+
+	// A cached or shared response reports work Ollama did for another request
+	rate := promptEvalRate(ctx)
+	if rate > 0 && !ctx.reusedResponse() {
+		p.metrics.promptEvalRate.WithLabelValues(modelLabel).Observe(rate)
+	}
+
+	if tokens > 0 && !ctx.reusedResponse() {
+		p.metrics.tokensGenerated.WithLabelValues(modelLabel, ctx.PromptCategory).Observe(float64(tokens))
 		if tokensPerSecond > 0 {
-			p.metrics.tokensPerSecond.WithLabelValues(ctx.Model, ctx.PromptCategory).Observe(tokensPerSecond)
+			p.metrics.tokensPerSecond.WithLabelValues(modelLabel, ctx.PromptCategory).Observe(tokensPerSecond)
 		}
 	}
 
@@ -494,16 +1297,132 @@ func (p *Proxy) recordMetrics(ctx *ProxyContext, duration float64, tokens int, t
 		PromptTokens:     ctx.PromptTokens,
 		LoadDuration:     ctx.LoadDuration,
 		TotalDuration:    ctx.TotalDuration,
-		User:             "anonymous", // Default user
+		User:             ctx.User,
+		Cost:             p.costs.Load().Calculate(ctx.Model, ctx.PromptTokens, tokens),
 		Status:           status,
-		QueueTime:        0, // Could be calculated if we track queue start time
+		QueueTime:        ctx.QueueTime,
 		TimeToFirstToken: ctx.TimeToFirstToken,
-		Metadata:         map[string]interface{}{"endpoint": ctx.Endpoint},
+		Metadata:         map[string]interface{}{"endpoint": ctx.Endpoint, "request_id": ctx.RequestID},
+	}
+	if ctx.Tag != "" {
+		record.Metadata["tag"] = ctx.Tag
+	}
+	if ctx.ChatTurns > 0 {
+		record.Metadata["chat_turns"] = ctx.ChatTurns
+	}
+	if ctx.ModelAlias != "" {
+		// The model column holds the resolved model; keep what the client asked for too
+		record.Metadata["requested_model"] = ctx.ModelAlias
+		record.Metadata["resolved_model"] = ctx.Model
+	}
+	if len(ctx.OptionsDefaulted) > 0 {
+		record.Metadata["options_defaulted"] = ctx.OptionsDefaulted
+	}
+	if len(ctx.OptionsForced) > 0 {
+		record.Metadata["options_forced"] = ctx.OptionsForced
+	}
+	if ctx.ReplayOf != 0 {
+		record.Metadata["replay_of"] = ctx.ReplayOf
+	}
+	if ctx.CoalescedWith != "" {
+		record.Metadata["coalesced_with"] = ctx.CoalescedWith
+	}
+	if ctx.ColdStart > 0 {
+		record.Metadata["cold_start_seconds"] = math.Round(ctx.ColdStart*1000) / 1000
+	}
+	if slowOutlier {
+		record.Metadata["slow_outlier"] = true
+	}
+	if ctx.Images.Count > 0 {
+		record.Metadata["multimodal"] = true
+		record.Metadata["image_count"] = ctx.Images.Count
+		record.Metadata["image_bytes"] = ctx.Images.Bytes
+	}
+	if p.audit != nil {
+		p.audit.Log(AuditEntry{
+			Timestamp:  record.Timestamp,
+			RequestID:  ctx.RequestID,
+			User:       ctx.User,
+			ClientIP:   ctx.ClientIP,
+			UserAgent:  record.UserAgent,
+			Model:      ctx.Model,
+			Endpoint:   ctx.Endpoint,
+			StatusCode: statusCode,
+			Status:     status,
+			Prompt:     ctx.Prompt,
+			Messages:   ctx.Messages,
+			Response:   ctx.ResponseText,
+			ToolCalls:  ctx.ToolCalls,
+			Error:      errorMsg,
+		})
+	}
+	if p.storeFullMessages && len(ctx.Messages) > 0 && p.analytics.storePrompts != ContentStoreNone {
+		// The prompt column keeps only the last message; store the whole
+		// conversation with the same redaction as prompts, within the size cap
+		messages := make([]ChatMessage, len(ctx.Messages))
+		for i, m := range ctx.Messages {
+			messages[i] = ChatMessage{Role: m.Role, Content: storedContent(p.analytics.storePrompts, m.Content, 0)}
+		}
+		messages, truncated := capMessages(messages, p.fullMessagesMaxBytes)
+		record.Metadata["messages"] = messages
+		if truncated {
+			record.Metadata["messages_truncated"] = true
+		}
+	}
+	if len(ctx.Tools) > 0 {
+		record.Metadata["tool_called"] = len(ctx.ToolCalls) > 0
+		if len(ctx.ToolCalls) > 0 {
+			record.Metadata["tool_names"] = ctx.ToolCalls
+		}
+	}
+	if rate > 0 {
+		record.Metadata["prompt_eval_rate"] = math.Round(rate*10) / 10
+	}
+	if p.storeRawTimings && len(ctx.Timings) > 0 {
+		record.Metadata["timings_ns"] = ctx.Timings
+	}
+	if ctx.TruncatedReason != "" {
+		record.Metadata["truncated_reason"] = ctx.TruncatedReason
+	}
+	if ctx.StreamDowngraded {
+		record.Metadata["stream_downgraded"] = true
+	}
+	if ctx.Retries > 0 {
+		record.Metadata["retries"] = ctx.Retries
+	}
+	if ctx.ErrorCategory != "" {
+		record.Metadata["error_category"] = ctx.ErrorCategory
+	}
+	if ctx.EmbeddingCount > 0 {
+		record.Metadata["embedding_count"] = ctx.EmbeddingCount
+		record.Metadata["embedding_dimensions"] = ctx.EmbeddingDimensions
+	}
+	if sample, ok := p.host.Sample(); ok {
+		record.Metadata["host_cpu_percent"] = math.Round(sample.CPUPercent*10) / 10
+		record.Metadata["host_mem_used_percent"] = math.Round(sample.MemUsedPercent*10) / 10
+		record.Metadata["host_mem_used_bytes"] = sample.MemUsedBytes
 	}
 
-	p.analytics.Record(record)
+	// The in-memory tail sees every request, even while the write queue is backed up
+	p.recentRequests.add(p.analytics.redacted(record))
 
-	log.Printf("[%s] %s/%s - %.2fs - %d tokens - %d", ctx.ClientIP, ctx.Model, ctx.PromptCategory, duration, tokens, statusCode)
+	// Prometheus above sees every request; failures and slow outliers are
+	// always stored so ANALYTICS_SAMPLE_RATE never hides them
+	if (status != "success" && status != "cache_hit") || slowOutlier || p.analytics.Sampled() {
+		p.analytics.Record(record)
+	}
+
+	slog.Info("Request complete",
+		"request_id", ctx.RequestID,
+		"client_ip", ctx.ClientIP,
+		"user", ctx.User,
+		"model", ctx.Model,
+		"endpoint", ctx.Endpoint,
+		"category", ctx.PromptCategory,
+		"duration", duration,
+		"tokens", tokens,
+		"status", statusCode,
+	)
 }
 
 // handleMetrics serves Prometheus metrics
@@ -572,69 +1491,164 @@ func (w *responseWriterWrapper) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Unwrap lets http.ResponseController (used by the reverse proxy's
+// FlushInterval) reach the underlying writer's Flush
+func (w *responseWriterWrapper) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// supportsFlush reports whether w, or a writer it wraps, implements http.Flusher
+func supportsFlush(w http.ResponseWriter) bool {
+	for {
+		if _, ok := w.(http.Flusher); ok {
+			return true
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return false
+		}
+		w = u.Unwrap()
+	}
+}
+
 // streamingResponseBody wraps the response body for streaming metrics collection
 type streamingResponseBody struct {
 	io.ReadCloser
 	proxy           *Proxy
 	ctx             *ProxyContext
+	statusCode      int // Upstream response status
 	accumulated     []byte
 	tokens          int
+	tokensReported  int // Tokens added to ollama_streaming_tokens_inflight so far
 	responseText    strings.Builder
 	firstTokenTime  time.Time
 	metricsData     map[string]interface{}
-	metricsRecorded bool // Prevents double-recording on early close
+	metricsRecorded bool      // Prevents double-recording on early close
+	closeOnce       sync.Once // Releases the shutdown drain tracking exactly once
+	readErr         error     // Upstream read failure, if any
+	clientGone      bool      // Closed early because writing to the client failed
+	done            bool      // Final chunk (done:true, or [DONE] for OpenAI streams) was received
+	partial         []byte    // Incomplete last line of the previous read
 }
 
 func (s *streamingResponseBody) Read(p []byte) (n int, err error) {
 	n, err = s.ReadCloser.Read(p)
 
 	if n > 0 {
-		// Accumulate data for metrics (limit to 1MB to prevent memory issues)
-		if len(s.accumulated) < 1024*1024 {
-			s.accumulated = append(s.accumulated, p[:n]...)
-		}
+		s.ctx.ResponseBytes += n
 
-		// Parse NDJSON chunks
-		lines := strings.Split(string(p[:n]), "\n")
-		for _, line := range lines {
-			if line == "" {
-				continue
+		// Accumulate data for metrics (capped to prevent memory issues)
+		if remaining := s.proxy.maxResponseCapture - len(s.accumulated); remaining > 0 {
+			if remaining > n {
+				remaining = n
 			}
+			s.accumulated = append(s.accumulated, p[:remaining]...)
+		}
 
-			var data map[string]interface{}
-			if err := json.Unmarshal([]byte(line), &data); err == nil {
-				// Extract response text
-				if response, ok := data["response"].(string); ok {
-					if s.firstTokenTime.IsZero() && response != "" {
-						s.firstTokenTime = time.Now()
-						s.ctx.TimeToFirstToken = s.firstTokenTime.Sub(s.ctx.StartTime).Seconds()
-					}
-					s.responseText.WriteString(response)
-				}
-
-				// Store metrics data from the final chunk
-				if done, ok := data["done"].(bool); ok && done {
-					s.metricsData = data
-				}
-			}
+		// Parse complete NDJSON lines. A chunk split across reads (the final
+		// generate chunk carries a large context array) is kept until the
+		// rest of it arrives.
+		data := append(s.partial, p[:n]...)
+		lines := strings.Split(string(data), "\n")
+		last := len(lines) - 1
+		for _, line := range lines[:last] {
+			s.parseLine(line)
+		}
+		s.partial = append(data[:0], lines[last]...)
+		if len(s.partial) > maxStreamLine {
+			s.partial = nil
 		}
 	}
 
-	// When stream ends, record metrics
+	// When stream ends, record metrics; the last chunk may lack a newline
 	if err == io.EOF {
+		if len(s.partial) > 0 {
+			s.parseLine(string(s.partial))
+			s.partial = nil
+		}
 		s.recordStreamMetrics()
+	} else if err != nil {
+		s.readErr = err
 	}
 
 	return n, err
 }
 
+// parseLine extracts response text, tool calls and final metrics from one
+// NDJSON (or server-sent event) line of the stream
+func (s *streamingResponseBody) parseLine(line string) {
+	// OpenAI-compatible endpoints stream server-sent events ("data: {...}")
+	line = strings.TrimPrefix(strings.TrimSpace(line), "data: ")
+	if line == "[DONE]" {
+		s.done = true
+		return
+	}
+	if line == "" {
+		return
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &data); err == nil {
+		// Extract response text (generate uses "response", chat uses "message.content")
+		response, ok := data["response"].(string)
+		if !ok {
+			if message, isMsg := data["message"].(map[string]interface{}); isMsg {
+				response, ok = message["content"].(string)
+			} else {
+				response, ok = openAIChoiceText(data)
+			}
+		}
+		if ok {
+			if s.firstTokenTime.IsZero() && response != "" {
+				s.firstTokenTime = time.Now()
+				s.ctx.TimeToFirstToken = s.firstTokenTime.Sub(s.ctx.StartTime).Seconds()
+			}
+			if response != "" {
+				// Each content chunk is one token; used when the stream ends early
+				s.tokens++
+				if every := s.proxy.streamTokenUpdate; every > 0 && s.tokens%every == 0 {
+					s.reportInflightTokens()
+				}
+			}
+			s.responseText.WriteString(response)
+		}
+		if len(s.ctx.Tools) > 0 {
+			s.ctx.ToolCalls = append(s.ctx.ToolCalls, invokedTools(data)...)
+		}
+
+		// Store metrics data from the final chunk (OpenAI sends "usage" instead of "done")
+		if done, ok := data["done"].(bool); ok && done {
+			s.metricsData = data
+			s.done = true
+		} else if _, ok := data["usage"].(map[string]interface{}); ok {
+			s.metricsData = data
+		}
+	}
+}
+
 // Close ensures metrics are recorded even on early connection close
 func (s *streamingResponseBody) Close() error {
-	// Record metrics if not already done (handles early disconnect)
+	// Record metrics if not already done (handles early disconnect and forced shutdown)
 	if !s.metricsRecorded {
+		// The reverse proxy only stops copying early when reading upstream or
+		// writing to the client fails; without a read error the client is gone
+		s.clientGone = s.readErr == nil && !s.proxy.draining.Load()
 		s.recordStreamMetrics()
 	}
-	return s.ReadCloser.Close()
+	err := s.ReadCloser.Close()
+	s.closeOnce.Do(func() {
+		s.proxy.streamCount.Add(-1)
+		s.proxy.activeStreams.Done()
+	})
+	return err
+}
+
+// reportInflightTokens adds the tokens counted since the last update to the
+// in-flight gauge
+func (s *streamingResponseBody) reportInflightTokens() {
+	model := s.proxy.models.label(s.ctx.Model)
+	s.proxy.metrics.streamingTokens.WithLabelValues(model).Add(float64(s.tokens - s.tokensReported))
+	s.tokensReported = s.tokens
 }
 
 // recordStreamMetrics extracts and records metrics from streaming response
@@ -645,6 +1659,14 @@ func (s *streamingResponseBody) recordStreamMetrics() {
 	}
 	s.metricsRecorded = true
 
+	// The finished stream leaves the in-flight gauge; its final count comes
+	// from eval_count below
+	if s.tokensReported > 0 {
+		model := s.proxy.models.label(s.ctx.Model)
+		s.proxy.metrics.streamingTokens.WithLabelValues(model).Sub(float64(s.tokensReported))
+		s.tokensReported = 0
+	}
+
 	duration := time.Since(s.ctx.StartTime).Seconds()
 	tokensPerSecond := 0.0
 	tokens := 0
@@ -664,15 +1686,74 @@ func (s *streamingResponseBody) recordStreamMetrics() {
 
 		if loadDuration, ok := s.metricsData["load_duration"].(float64); ok {
 			s.ctx.LoadDuration = loadDuration / 1e9
+			s.proxy.observeModelLoad(s.ctx)
 		}
 
 		if totalDuration, ok := s.metricsData["total_duration"].(float64); ok {
 			s.ctx.TotalDuration = totalDuration / 1e9
 		}
+		s.ctx.Timings = ollamaTimings(s.metricsData)
+
+		// OpenAI-compatible responses report tokens in a "usage" object
+		if promptTokens, completionTokens, ok := openAIUsage(s.metricsData); ok && tokens == 0 {
+			tokens = completionTokens
+			s.ctx.PromptTokens = promptTokens
+			// Measure generation speed from the first token when known
+			genTime := duration - s.ctx.TimeToFirstToken
+			if genTime <= 0 {
+				genTime = duration
+			}
+			if tokens > 0 && genTime > 0 {
+				tokensPerSecond = float64(tokens) / genTime
+			}
+		}
 	}
 
 	// Store response preview
-	s.ctx.ResponsePreview = truncate(s.responseText.String(), 200)
+	s.ctx.ResponseText = s.responseText.String()
+	s.ctx.ResponsePreview = truncate(s.ctx.ResponseText, s.proxy.analytics.responsePreviewLen)
+
+	// A stream that ended without its final chunk was cut off: count the
+	// tokens seen so far
+	if s.metricsData == nil && s.tokens > 0 {
+		tokens = s.tokens
+		if genTime := duration - s.ctx.TimeToFirstToken; genTime > 0 {
+			tokensPerSecond = float64(tokens) / genTime
+		}
+	}
+
+	// Record why the stream was cut off: request timeout or client disconnect.
+	// Either way the request context is done, which cancels the upstream read.
+	statusCode, errorMsg := s.statusCode, ""
+	switch err := s.ctx.Request.Context().Err(); {
+	case errors.Is(err, context.DeadlineExceeded):
+		statusCode = http.StatusGatewayTimeout
+		errorMsg = fmt.Sprintf("stream exceeded request timeout after %.0fs", duration)
+	case (errors.Is(err, context.Canceled) || s.clientGone) && s.metricsData == nil:
+		statusCode = StatusClientClosedRequest
+		errorMsg = fmt.Sprintf("client disconnected after %d tokens", tokens)
+	}
+
+	// Without the final chunk a successful stream was cut off; tokens above
+	// are then counted from the content chunks received. Upstream error
+	// bodies never carry a final chunk and are not truncated streams.
+	if !s.done && s.statusCode >= 200 && s.statusCode < 300 {
+		switch {
+		case statusCode == http.StatusGatewayTimeout:
+			s.ctx.TruncatedReason = "timeout"
+		case statusCode == StatusClientClosedRequest:
+			s.ctx.TruncatedReason = "client_disconnect"
+		case s.readErr != nil:
+			s.ctx.TruncatedReason = "upstream_error"
+			errorMsg = fmt.Sprintf("stream from Ollama failed after %d tokens: %v", tokens, s.readErr)
+		case s.proxy.draining.Load():
+			s.ctx.TruncatedReason = "shutdown"
+			errorMsg = fmt.Sprintf("stream closed by proxy shutdown after %d tokens", tokens)
+		default:
+			s.ctx.TruncatedReason = "incomplete"
+			errorMsg = fmt.Sprintf("stream ended without a final chunk after %d tokens", tokens)
+		}
+	}
 
-	s.proxy.recordMetrics(s.ctx, duration, tokens, tokensPerSecond, 200, "")
+	s.proxy.recordMetrics(s.ctx, duration, tokens, tokensPerSecond, statusCode, errorMsg)
 }
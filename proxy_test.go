@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestProxy returns a proxy forwarding to upstream, with analytics stored
+// in a temporary SQLite database
+func newTestProxy(t *testing.T, upstream string) *Proxy {
+	t.Helper()
+	t.Setenv("ANALYTICS_DIR", t.TempDir())
+	t.Setenv("ANALYTICS_BACKEND", "sqlite")
+
+	p := NewProxy(upstream, 0, false)
+	t.Cleanup(p.analytics.Close)
+	return p
+}
+
+// waitForRecord waits for the writer to store a record and returns the newest one
+func waitForRecord(t *testing.T, p *Proxy) AnalyticsRecord {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		records, err := p.analytics.Search(url.Values{})
+		if err == nil && len(records) > 0 {
+			return records[0]
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("no analytics record was stored")
+	return AnalyticsRecord{}
+}
+
+func TestClientDisconnectCancelsUpstream(t *testing.T) {
+	upstreamCancelled := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/generate" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, word := range []string{"one", "two", "three"} {
+			fmt.Fprintf(w, "{\"model\":\"llama3\",\"response\":%q,\"done\":false}\n", word)
+			w.(http.Flusher).Flush()
+		}
+		// Keep generating until the proxy gives up on the request
+		select {
+		case <-r.Context().Done():
+			close(upstreamCancelled)
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer upstream.Close()
+
+	p := newTestProxy(t, upstream.URL)
+	server := httptest.NewServer(http.HandlerFunc(p.handleProxy))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/api/generate",
+		strings.NewReader(`{"model":"llama3","prompt":"count to ten"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Hang up once the first chunks have arrived
+	reader := bufio.NewReader(resp.Body)
+	for i := 0; i < 3; i++ {
+		if _, err := reader.ReadString('\n'); err != nil {
+			t.Fatalf("reading chunk %d: %v", i, err)
+		}
+	}
+	cancel()
+	resp.Body.Close()
+
+	select {
+	case <-upstreamCancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("upstream request was not cancelled after the client disconnected")
+	}
+
+	record := waitForRecord(t, p)
+	if record.Status != "cancelled" {
+		t.Errorf("status = %q, want cancelled", record.Status)
+	}
+	if record.StatusCode != StatusClientClosedRequest {
+		t.Errorf("status code = %d, want %d", record.StatusCode, StatusClientClosedRequest)
+	}
+	if record.TokensGenerated != 3 {
+		t.Errorf("tokens = %d, want the 3 streamed before the disconnect", record.TokensGenerated)
+	}
+}
+
+// nonFlushingWriter hides the http.Flusher of the writer it wraps, like the
+// writers some service hosts hand to the proxy
+type nonFlushingWriter struct {
+	http.ResponseWriter
+}
+
+func TestNonFlushingWriterDowngradesStream(t *testing.T) {
+	upstreamBody := make(chan []byte, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		upstreamBody <- body
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"model":"llama3","message":{"role":"assistant","content":"hello"},"done":true,"eval_count":1}`)
+	}))
+	defer upstream.Close()
+
+	p := newTestProxy(t, upstream.URL)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/chat",
+		strings.NewReader(`{"model":"llama3","messages":[{"role":"user","content":"hi"}]}`))
+	p.handleProxy(nonFlushingWriter{rec}, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	select {
+	case body := <-upstreamBody:
+		var forwarded map[string]interface{}
+		if err := json.Unmarshal(body, &forwarded); err != nil {
+			t.Fatalf("upstream body is not JSON: %v", err)
+		}
+		if stream, ok := forwarded["stream"].(bool); !ok || stream {
+			t.Errorf("upstream stream = %v, want false", forwarded["stream"])
+		}
+	default:
+		t.Fatal("request never reached upstream")
+	}
+
+	record := waitForRecord(t, p)
+	if downgraded, _ := record.Metadata["stream_downgraded"].(bool); !downgraded {
+		t.Errorf("metadata.stream_downgraded = %v, want true", record.Metadata["stream_downgraded"])
+	}
+}
+
+func TestUpstreamErrorOnStreamingEndpoint(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"model \"missing\" not found, try pulling it first"}`)
+	}))
+	defer upstream.Close()
+
+	p := newTestProxy(t, upstream.URL)
+	server := httptest.NewServer(http.HandlerFunc(p.handleProxy))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/api/generate", "application/json",
+		strings.NewReader(`{"model":"missing","prompt":"hello"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", resp.StatusCode)
+	}
+
+	// The error body is recorded as an error, not as a truncated 200 stream
+	record := waitForRecord(t, p)
+	if record.Status != "error" {
+		t.Errorf("status = %q, want error", record.Status)
+	}
+	if record.StatusCode != http.StatusNotFound {
+		t.Errorf("status code = %d, want 404", record.StatusCode)
+	}
+	if !strings.Contains(record.ErrorMessage, "not found") {
+		t.Errorf("error message = %q, want Ollama's error", record.ErrorMessage)
+	}
+	if category := record.Metadata["error_category"]; category != "model_not_found" {
+		t.Errorf("metadata.error_category = %v, want model_not_found", category)
+	}
+	if reason, ok := record.Metadata["truncated_reason"]; ok {
+		t.Errorf("metadata.truncated_reason = %v, want none", reason)
+	}
+}
+
+func TestDirectorPreserveHost(t *testing.T) {
+	tests := []struct {
+		name         string
+		preserveHost string
+		wantHost     string
+	}{
+		{name: "default rewrites Host", preserveHost: "", wantHost: "ollama.internal:11434"},
+		{name: "PRESERVE_HOST keeps the client Host", preserveHost: "true", wantHost: "proxy.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PRESERVE_HOST", tt.preserveHost)
+			p := newTestProxy(t, "http://ollama.internal:11434")
+
+			req := httptest.NewRequest(http.MethodPost, "http://proxy.example.com/api/generate", nil)
+			p.reverseProxy.Director(req)
+
+			if req.URL.Host != "ollama.internal:11434" || req.URL.Scheme != "http" {
+				t.Errorf("URL = %s, want it pointed at the target", req.URL)
+			}
+			if req.Host != tt.wantHost {
+				t.Errorf("Host = %q, want %q", req.Host, tt.wantHost)
+			}
+			if got := req.Header.Get("X-Forwarded-Host"); got != "proxy.example.com" {
+				t.Errorf("X-Forwarded-Host = %q, want proxy.example.com", got)
+			}
+		})
+	}
+}
+
+func TestFinalChunkSplitAcrossReads(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		fmt.Fprint(w, "{\"model\":\"llama3\",\"response\":\"hi\",\"done\":false}\n")
+		w.(http.Flusher).Flush()
+
+		// The final chunk arrives in two flushes, split inside its context array
+		final := `{"model":"llama3","response":"","done":true,"context":[1,2,3,4,5,6,7,8],"eval_count":42,"eval_duration":2000000000,"prompt_eval_count":7}` + "\n"
+		half := len(final) / 2
+		fmt.Fprint(w, final[:half])
+		w.(http.Flusher).Flush()
+		time.Sleep(50 * time.Millisecond)
+		fmt.Fprint(w, final[half:])
+	}))
+	defer upstream.Close()
+
+	p := newTestProxy(t, upstream.URL)
+	server := httptest.NewServer(http.HandlerFunc(p.handleProxy))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/api/generate", "application/json",
+		strings.NewReader(`{"model":"llama3","prompt":"hello"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	record := waitForRecord(t, p)
+	if record.Status != "success" {
+		t.Errorf("status = %q, want success", record.Status)
+	}
+	if reason, ok := record.Metadata["truncated_reason"]; ok {
+		t.Errorf("metadata.truncated_reason = %v, want none", reason)
+	}
+	if record.TokensGenerated != 42 || record.PromptTokens != 7 {
+		t.Errorf("tokens = %d/%d, want eval_count 42 and prompt_eval_count 7", record.TokensGenerated, record.PromptTokens)
+	}
+}
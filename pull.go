@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// pullEndpoint labels model pulls in analytics and metrics
+const pullEndpoint = "model_pull"
+
+// handleAdminPull pulls a model through Ollama's /api/pull, streaming the
+// NDJSON progress back to the caller, and records the outcome in analytics as
+// a model_pull entry. Accepts the same body as /api/pull, e.g. {"model": "llama3"}.
+func (p *Proxy) handleAdminPull(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req map[string]interface{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body: expected {\"model\": \"...\"}", http.StatusBadRequest)
+		return
+	}
+	model, _ := req["model"].(string)
+	if model == "" {
+		// Older Ollama clients send "name"
+		model, _ = req["name"].(string)
+	}
+	if model == "" {
+		http.Error(w, "Missing model", http.StatusBadRequest)
+		return
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	requestID := requestIDFor(r)
+	w.Header().Set(requestIDHeader, requestID)
+	log.Printf("[%s] Pulling model %s (requested by %s)", requestID, model, r.RemoteAddr)
+
+	if _, err := p.idle.acquire(requestID); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to start Ollama: %v", err), http.StatusServiceUnavailable)
+		return
+	}
+	defer p.idle.release()
+
+	p.tags.invalidate()
+	defer p.tags.invalidate()
+
+	// The server's WriteTimeout would otherwise cut off long downloads
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("[%s] Warning: Could not clear write deadline for pull: %v", requestID, err)
+	}
+
+	start := time.Now()
+	statusCode, lastStatus, errorMsg := p.streamPull(w, r, requestID, body)
+	p.recordPull(r, requestID, model, start, statusCode, lastStatus, errorMsg)
+}
+
+// streamPull forwards the pull to Ollama and relays each progress line as it
+// arrives. It returns the upstream status code, the last progress status and
+// any error reported along the way.
+func (p *Proxy) streamPull(w http.ResponseWriter, r *http.Request, requestID string, body []byte) (statusCode int, lastStatus, errorMsg string) {
+	upstream, err := http.NewRequestWithContext(r.Context(), http.MethodPost, p.target.String()+"/api/pull", bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return http.StatusInternalServerError, "", err.Error()
+	}
+	upstream.Header.Set("Content-Type", "application/json")
+	upstream.Header.Set(requestIDHeader, requestID)
+
+	// No client timeout: large models take a long time to download
+	resp, err := http.DefaultClient.Do(upstream)
+	if err != nil {
+		http.Error(w, "Ollama unavailable: "+err.Error(), http.StatusBadGateway)
+		return http.StatusBadGateway, "", err.Error()
+	}
+	defer resp.Body.Close()
+
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.WriteHeader(resp.StatusCode)
+	flusher, _ := w.(http.Flusher)
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			// The client went away; Ollama cancels the pull with the request context
+			return StatusClientClosedRequest, lastStatus, "client disconnected: " + err.Error()
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		var progress map[string]interface{}
+		if json.Unmarshal(line, &progress) != nil {
+			continue
+		}
+		if msg := upstreamErrorMessage(progress); msg != "" {
+			errorMsg = msg
+		}
+		if status, ok := progress["status"].(string); ok {
+			lastStatus = status
+		}
+	}
+	if err := scanner.Err(); err != nil && errorMsg == "" {
+		if r.Context().Err() != nil {
+			return StatusClientClosedRequest, lastStatus, "client disconnected: " + err.Error()
+		}
+		errorMsg = err.Error()
+	}
+
+	if errorMsg == "" && resp.StatusCode == http.StatusOK && lastStatus != "success" {
+		errorMsg = fmt.Sprintf("pull ended without success (last status %q)", lastStatus)
+	}
+	return resp.StatusCode, lastStatus, errorMsg
+}
+
+// recordPull logs the pull outcome and records it in analytics and metrics
+func (p *Proxy) recordPull(r *http.Request, requestID, model string, start time.Time, statusCode int, lastStatus, errorMsg string) {
+	duration := time.Since(start).Seconds()
+	status := "success"
+	switch {
+	case statusCode == StatusClientClosedRequest:
+		status = "cancelled"
+		log.Printf("[%s] Pull of %s cancelled after %.1fs", requestID, model, duration)
+	case errorMsg != "" || statusCode >= 400:
+		status = "error"
+		log.Printf("[%s] Pull of %s failed after %.1fs: %s", requestID, model, duration, errorMsg)
+	default:
+		log.Printf("[%s] Pulled %s in %.1fs", requestID, model, duration)
+		// Pick up the new model for metric labels right away
+		go p.models.refresh()
+	}
+
+	if errorType := classifyError(statusCode, errorMsg); status != "success" && errorType != "" {
+		p.metrics.requestErrors.WithLabelValues(p.models.label(model), pullEndpoint, errorType).Inc()
+	}
+
+	clientIP := r.RemoteAddr
+	if xForwardedFor := r.Header.Get("X-Forwarded-For"); xForwardedFor != "" {
+		clientIP = xForwardedFor + " (via " + r.RemoteAddr + ")"
+	}
+	p.analytics.Record(AnalyticsRecord{
+		Timestamp:       start,
+		Model:           model,
+		Endpoint:        pullEndpoint,
+		PromptCategory:  pullEndpoint,
+		DurationSeconds: duration,
+		StatusCode:      statusCode,
+		ErrorMessage:    errorMsg,
+		ClientIP:        clientIP,
+		UserAgent:       r.Header.Get("User-Agent"),
+		User:            p.requestUser(r),
+		Status:          status,
+		Metadata: map[string]interface{}{
+			"type":        pullEndpoint,
+			"request_id":  requestID,
+			"last_status": lastStatus,
+		},
+	})
+}
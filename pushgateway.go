@@ -0,0 +1,109 @@
+package main
+
+import (
+	"log"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+const (
+	DefaultPushInterval = 15 * time.Second // How often metrics are pushed when PUSH_INTERVAL is unset
+	DefaultPushJob      = "ollama_proxy"
+)
+
+// metricsPusher periodically pushes the metrics registry to a Prometheus
+// Pushgateway, for proxies that can't be scraped (behind NAT, short-lived).
+// /metrics keeps working alongside it.
+type metricsPusher struct {
+	pusher   *push.Pusher
+	target   string
+	interval time.Duration
+	failing  bool // Last push failed; logged once until a push succeeds
+	done     chan struct{}
+	finished chan struct{}
+}
+
+// newMetricsPusher starts pushing registry to PUSHGATEWAY_URL every
+// PUSH_INTERVAL. Returns nil when PUSHGATEWAY_URL is unset or invalid.
+func newMetricsPusher(registry *prometheus.Registry) *metricsPusher {
+	target := strings.TrimSpace(os.Getenv("PUSHGATEWAY_URL"))
+	if target == "" {
+		return nil
+	}
+	if u, err := url.Parse(target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		log.Printf("Warning: Ignoring invalid PUSHGATEWAY_URL %q, metrics won't be pushed", target)
+		return nil
+	}
+
+	job := strings.TrimSpace(os.Getenv("PUSH_JOB"))
+	if job == "" {
+		job = DefaultPushJob
+	}
+	instance := strings.TrimSpace(os.Getenv("PUSH_INSTANCE"))
+	if instance == "" {
+		instance, _ = os.Hostname()
+	}
+	if instance == "" {
+		instance = "unknown"
+	}
+
+	m := &metricsPusher{
+		pusher: push.New(target, job).
+			Gatherer(registry).
+			Grouping("instance", instance).
+			Client(&http.Client{Timeout: 10 * time.Second}),
+		target:   target,
+		interval: getEnvDuration("PUSH_INTERVAL", DefaultPushInterval),
+		done:     make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+	go m.run()
+	log.Printf("Pushing metrics to %s every %s (job=%s, instance=%s)", target, m.interval, job, instance)
+	return m
+}
+
+// run pushes on every tick until close
+func (m *metricsPusher) run() {
+	defer close(m.finished)
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+			m.push()
+		}
+	}
+}
+
+// push replaces this instance's metrics on the Pushgateway. Failures are
+// logged when they start and when pushing recovers, not on every attempt.
+func (m *metricsPusher) push() {
+	err := m.pusher.Push()
+	if err != nil && !m.failing {
+		slog.Warn("Pushing metrics failed, will keep retrying", "pushgateway", m.target, "error", err)
+	} else if err == nil && m.failing {
+		slog.Info("Pushing metrics recovered", "pushgateway", m.target)
+	}
+	m.failing = err != nil
+}
+
+// close stops the pusher after one last push so the final counts are kept.
+// Safe to call on a nil metricsPusher.
+func (m *metricsPusher) close() {
+	if m == nil {
+		return
+	}
+	close(m.done)
+	<-m.finished
+	m.push()
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"hash/fnv"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const rateLimitShards = 16
+
+// rateLimiter is a per-client-IP token bucket. Buckets are spread over
+// shards so concurrent clients rarely contend on the same lock, and idle
+// buckets are swept out so memory stays bounded by active clients.
+type rateLimiter struct {
+	rate   float64      // Tokens added per second
+	burst  float64      // Bucket capacity
+	exempt []*net.IPNet // Addresses never limited
+	shards [rateLimitShards]rateShard
+}
+
+type rateShard struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter reads PER_IP_RATE_LIMIT (requests per minute), PER_IP_RATE_BURST
+// and PER_IP_RATE_EXEMPT. Returns nil when rate limiting is disabled.
+func newRateLimiter() *rateLimiter {
+	perMinute := getEnvInt("PER_IP_RATE_LIMIT", 0)
+	if perMinute <= 0 {
+		return nil
+	}
+	burst := getEnvInt("PER_IP_RATE_BURST", perMinute)
+	if burst <= 0 {
+		burst = perMinute
+	}
+
+	rl := &rateLimiter{
+		rate:   float64(perMinute) / 60,
+		burst:  float64(burst),
+		exempt: parseCIDRList("PER_IP_RATE_EXEMPT"),
+	}
+	for i := range rl.shards {
+		rl.shards[i].buckets = make(map[string]*tokenBucket)
+	}
+	log.Printf("Per-IP rate limiting enabled: %d requests/minute, burst %d (%d exempt ranges)", perMinute, burst, len(rl.exempt))
+	return rl
+}
+
+// allow takes a token for the client, returning how long to wait when none is left
+func (rl *rateLimiter) allow(ip net.IP, key string) (bool, time.Duration) {
+	for _, n := range rl.exempt {
+		if ip != nil && n.Contains(ip) {
+			return true, 0
+		}
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	shard := &rl.shards[h.Sum32()%rateLimitShards]
+
+	now := time.Now()
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	rl.sweep(shard, now)
+
+	b, ok := shard.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: rl.burst, last: now}
+		shard.buckets[key] = b
+	}
+	b.tokens = math.Min(rl.burst, b.tokens+now.Sub(b.last).Seconds()*rl.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / rl.rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep drops buckets that have been idle long enough to refill completely,
+// since they are indistinguishable from a new bucket. Runs at most once a
+// refill period per shard; the caller holds shard.mu.
+func (rl *rateLimiter) sweep(shard *rateShard, now time.Time) {
+	refill := time.Duration(rl.burst / rl.rate * float64(time.Second))
+	if now.Sub(shard.lastSweep) < refill {
+		return
+	}
+	shard.lastSweep = now
+	for key, b := range shard.buckets {
+		if now.Sub(b.last) >= refill {
+			delete(shard.buckets, key)
+		}
+	}
+}
+
+// checkRateLimit rejects clients over their per-IP rate with a 429.
+// Returns false when the request was rejected.
+func (p *Proxy) checkRateLimit(w http.ResponseWriter, r *http.Request) bool {
+	if p.rateLimiter == nil {
+		return true
+	}
+
+	ip := p.ipFilter.clientIP(r)
+	key := r.RemoteAddr
+	if ip != nil {
+		key = ip.String()
+	}
+
+	ok, wait := p.rateLimiter.allow(ip, key)
+	if ok {
+		return true
+	}
+
+	p.recordRateLimited(r, key)
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	http.Error(w, "Too many requests", http.StatusTooManyRequests)
+	return false
+}
+
+// recordRateLimited logs and counts a rate-limited request. Rejections are not
+// stored in analytics, since a client over its limit would otherwise flood the writer.
+func (p *Proxy) recordRateLimited(r *http.Request, clientIP string) {
+	log.Printf("[%s] [%s] Rate limited: %s %s", r.Header.Get(requestIDHeader), clientIP, r.Method, r.URL.Path)
+	p.metrics.rateLimited.Inc()
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newTestRateLimiter returns a limiter allowing perMinute requests with the given burst
+func newTestRateLimiter(t *testing.T, perMinute, burst string) *rateLimiter {
+	t.Helper()
+	t.Setenv("PER_IP_RATE_LIMIT", perMinute)
+	t.Setenv("PER_IP_RATE_BURST", burst)
+	t.Setenv("PER_IP_RATE_EXEMPT", "")
+	rl := newRateLimiter()
+	if rl == nil {
+		t.Fatal("rate limiter is disabled")
+	}
+	return rl
+}
+
+// rewind moves a bucket's last refill back by d, as if d had passed since
+func rewind(rl *rateLimiter, key string, d time.Duration) {
+	for i := range rl.shards {
+		shard := &rl.shards[i]
+		shard.mu.Lock()
+		if b, ok := shard.buckets[key]; ok {
+			b.last = b.last.Add(-d)
+		}
+		shard.mu.Unlock()
+	}
+}
+
+func TestRateLimiterBurst(t *testing.T) {
+	rl := newTestRateLimiter(t, "60", "3")
+	ip := net.ParseIP("10.0.0.1")
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := rl.allow(ip, ip.String()); !ok {
+			t.Fatalf("request %d within the burst was limited", i+1)
+		}
+	}
+	ok, wait := rl.allow(ip, ip.String())
+	if ok {
+		t.Fatal("request over the burst was allowed")
+	}
+	if wait <= 0 || wait > time.Second {
+		t.Errorf("wait = %v, want up to the 1s it takes to refill a token", wait)
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	rl := newTestRateLimiter(t, "60", "2")
+	ip := net.ParseIP("10.0.0.1")
+	key := ip.String()
+
+	rl.allow(ip, key)
+	rl.allow(ip, key)
+	if ok, _ := rl.allow(ip, key); ok {
+		t.Fatal("request over the burst was allowed")
+	}
+
+	// One second refills one token at 60 requests/minute
+	rewind(rl, key, time.Second)
+	if ok, _ := rl.allow(ip, key); !ok {
+		t.Fatal("request after a token refilled was limited")
+	}
+	if ok, _ := rl.allow(ip, key); ok {
+		t.Fatal("refill added more than one token")
+	}
+
+	// A long pause refills the bucket only up to the burst
+	rewind(rl, key, time.Hour)
+	for i := 0; i < 2; i++ {
+		if ok, _ := rl.allow(ip, key); !ok {
+			t.Fatalf("request %d after a full refill was limited", i+1)
+		}
+	}
+	if ok, _ := rl.allow(ip, key); ok {
+		t.Fatal("refill went past the burst")
+	}
+}
+
+func TestRateLimiterPerKeyIsolation(t *testing.T) {
+	rl := newTestRateLimiter(t, "60", "1")
+	noisy := net.ParseIP("10.0.0.1")
+	quiet := net.ParseIP("2001:db8::1")
+
+	rl.allow(noisy, noisy.String())
+	if ok, _ := rl.allow(noisy, noisy.String()); ok {
+		t.Fatal("noisy client was not limited")
+	}
+	if ok, _ := rl.allow(quiet, quiet.String()); !ok {
+		t.Fatal("another client was limited by the noisy client's bucket")
+	}
+}
+
+func TestRateLimiterExempt(t *testing.T) {
+	t.Setenv("PER_IP_RATE_LIMIT", "60")
+	t.Setenv("PER_IP_RATE_BURST", "1")
+	t.Setenv("PER_IP_RATE_EXEMPT", "10.0.0.0/8")
+	rl := newRateLimiter()
+	ip := net.ParseIP("10.1.2.3")
+
+	for i := 0; i < 5; i++ {
+		if ok, _ := rl.allow(ip, ip.String()); !ok {
+			t.Fatalf("exempt request %d was limited", i+1)
+		}
+	}
+}
+
+func TestCheckRateLimitRetryAfter(t *testing.T) {
+	// 6 requests/minute refills a token every 10 seconds
+	t.Setenv("PER_IP_RATE_LIMIT", "6")
+	t.Setenv("PER_IP_RATE_BURST", "1")
+	p := newTestProxy(t, "http://127.0.0.1:0")
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/api/generate", nil)
+		req.RemoteAddr = "192.0.2.10:5000"
+		return req
+	}
+
+	rec := httptest.NewRecorder()
+	if !p.checkRateLimit(rec, newRequest()) {
+		t.Fatalf("first request was limited (status %d)", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	if p.checkRateLimit(rec, newRequest()) {
+		t.Fatal("second request was allowed")
+	}
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "10" {
+		t.Errorf("Retry-After = %q, want 10", got)
+	}
+	if got := testutil.ToFloat64(p.metrics.rateLimited); got != 1 {
+		t.Errorf("ollama_rate_limited_requests_total = %v, want 1", got)
+	}
+
+	// The same client on another port shares the bucket
+	req := newRequest()
+	req.RemoteAddr = "192.0.2.10:6000"
+	if p.checkRateLimit(httptest.NewRecorder(), req) {
+		t.Error("request from another port of the same client was allowed")
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+)
+
+// reloadConfig re-reads COST_CONFIG and CATEGORIZER_CONFIG and swaps them in
+// without restarting. Each structure is replaced atomically, so in-flight
+// requests see either the old or the new configuration. A file that fails to
+// load leaves its previous configuration in place.
+func (p *Proxy) reloadConfig() error {
+	var errs []error
+
+	if costPath := os.Getenv("COST_CONFIG"); costPath != "" {
+		if costs, err := loadCostConfig(costPath); err != nil {
+			errs = append(errs, err)
+		} else {
+			p.costs.Store(costs)
+		}
+	}
+
+	if configPath := os.Getenv("CATEGORIZER_CONFIG"); configPath != "" {
+		if err := p.metrics.categorizer.LoadRules(configPath); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// handleAdminReload reloads config files over HTTP, for platforms without
+// SIGHUP (Windows) or when signalling the process is inconvenient
+func (p *Proxy) handleAdminReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	log.Printf("Reloading configuration (requested by %s)", r.RemoteAddr)
+	if err := p.reloadConfig(); err != nil {
+		log.Printf("Warning: Config reload incomplete: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "reloaded"})
+}
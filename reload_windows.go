@@ -0,0 +1,8 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// notifyReload is a no-op on Windows, which has no SIGHUP; use POST /admin/reload instead
+func notifyReload(c chan<- os.Signal) {}
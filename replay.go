@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ReplayStats are the figures compared between an interaction and its replay
+type ReplayStats struct {
+	Response       string  `json:"response"`
+	OutputTokens   int     `json:"output_tokens"`
+	InputTokens    int     `json:"input_tokens"`
+	LatencySeconds float64 `json:"latency"`
+}
+
+// ReplayResult is the response of /analytics/messages/{id}/replay
+type ReplayResult struct {
+	SourceID        int64       `json:"source_id"`
+	RequestID       string      `json:"request_id"` // Request ID of the replay's own analytics record
+	Model           string      `json:"model"`
+	Endpoint        string      `json:"endpoint"`
+	StatusCode      int         `json:"status_code"`
+	Error           string      `json:"error,omitempty"`
+	PromptTruncated bool        `json:"prompt_truncated"` // Stored prompt hit PROMPT_PREVIEW_LEN, so the replay may differ
+	Original        ReplayStats `json:"original"`
+	Replay          ReplayStats `json:"replay"`
+	Comparison      struct {
+		OutputTokensDiff   int      `json:"output_tokens_diff"`
+		LatencyDiffSeconds float64  `json:"latency_diff"`
+		LatencyChange      *float64 `json:"latency_change_pct"`
+	} `json:"comparison"`
+}
+
+// replayRequest rebuilds the upstream request for a stored interaction. Chat
+// requests reuse the stored conversation (STORE_FULL_MESSAGES) when complete,
+// otherwise only the stored prompt is sent as a single user message.
+func replayRequest(record *AnalyticsRecord) (path string, body []byte, messages []ChatMessage, err error) {
+	if record.Prompt == "" || strings.HasPrefix(record.Prompt, "sha256:") {
+		return "", nil, nil, fmt.Errorf("prompt was not stored for this interaction (STORE_PROMPTS)")
+	}
+
+	request := map[string]interface{}{"model": record.Model, "stream": false}
+	switch record.Endpoint {
+	case "generate", "completions":
+		request["prompt"] = record.Prompt
+	case "chat", "chat/completions":
+		messages = storedMessages(record.Metadata)
+		if len(messages) == 0 {
+			messages = []ChatMessage{{Role: "user", Content: record.Prompt}}
+		}
+		request["messages"] = messages
+	default:
+		return "", nil, nil, fmt.Errorf("endpoint %q can't be replayed", record.Endpoint)
+	}
+
+	path = "/api/" + record.Endpoint
+	if strings.Contains(record.Endpoint, "completions") {
+		path = "/v1/" + record.Endpoint
+	}
+	body, err = json.Marshal(request)
+	return path, body, messages, err
+}
+
+// storedMessages returns the conversation kept in a record's metadata, or nil
+// when none was stored or it was cut down to fit the size cap
+func storedMessages(metadata map[string]interface{}) []ChatMessage {
+	if truncated, _ := metadata["messages_truncated"].(bool); truncated {
+		return nil
+	}
+	raw, ok := metadata["messages"].([]interface{})
+	if !ok {
+		return nil
+	}
+	messages := make([]ChatMessage, 0, len(raw))
+	for _, m := range raw {
+		entry, ok := m.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		role, _ := entry["role"].(string)
+		content, _ := entry["content"].(string)
+		if strings.HasPrefix(content, "sha256:") {
+			return nil
+		}
+		messages = append(messages, ChatMessage{Role: role, Content: content})
+	}
+	return messages
+}
+
+// responseTokens returns the generated token count from a non-streaming response
+func responseTokens(data map[string]interface{}) int {
+	if _, completion, ok := openAIUsage(data); ok {
+		return completion
+	}
+	if evalCount, ok := data["eval_count"].(float64); ok {
+		return int(evalCount)
+	}
+	return 0
+}
+
+// handleAnalyticsReplay re-runs a stored interaction against Ollama and
+// compares the new response with the original. The replay is recorded in
+// analytics like any other request, with metadata.replay_of set to the source ID.
+func (p *Proxy) handleAnalyticsReplay(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	original, err := p.analytics.GetMessageByID(id)
+	if err != nil {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+	path, body, messages, err := replayRequest(original)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	clientIP := r.RemoteAddr
+	if xForwardedFor := r.Header.Get("X-Forwarded-For"); xForwardedFor != "" {
+		clientIP = xForwardedFor + " (via " + r.RemoteAddr + ")"
+	}
+	ctx := &ProxyContext{
+		StartTime:      time.Now(),
+		Model:          original.Model,
+		Prompt:         original.Prompt,
+		Endpoint:       original.Endpoint,
+		PromptCategory: original.PromptCategory,
+		Request:        r,
+		ClientIP:       clientIP,
+		RequestID:      requestIDFor(r),
+		User:           p.requestUser(r),
+		Tag:            p.requestTag(r),
+		Messages:       messages,
+		ChatTurns:      len(messages),
+		ReplayOf:       id,
+	}
+	w.Header().Set(requestIDHeader, ctx.RequestID)
+	log.Printf("[%s] Replaying interaction %d (%s %s)", ctx.RequestID, id, original.Model, path)
+
+	// Replays share the concurrency limit with proxied traffic
+	select {
+	case p.maxConcurrent <- struct{}{}:
+		defer func() { <-p.maxConcurrent }()
+	case <-r.Context().Done():
+		return
+	}
+	coldStart, err := p.idle.acquire(ctx.RequestID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to start Ollama: %v", err), http.StatusServiceUnavailable)
+		return
+	}
+	defer p.idle.release()
+	ctx.ColdStart = coldStart.Seconds()
+
+	result := ReplayResult{
+		SourceID:        id,
+		RequestID:       ctx.RequestID,
+		Model:           original.Model,
+		Endpoint:        original.Endpoint,
+		PromptTruncated: p.analytics.promptPreviewLen > 0 && len(original.Prompt) >= p.analytics.promptPreviewLen,
+		Original: ReplayStats{
+			Response:       original.ResponsePreview,
+			OutputTokens:   original.TokensGenerated,
+			InputTokens:    original.PromptTokens,
+			LatencySeconds: original.DurationSeconds,
+		},
+	}
+
+	upstream, err := http.NewRequestWithContext(r.Context(), http.MethodPost, p.target.String()+path, bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	upstream.Header.Set("Content-Type", "application/json")
+	upstream.Header.Set(requestIDHeader, ctx.RequestID)
+
+	client := &http.Client{Timeout: p.requestTimeout}
+	resp, err := client.Do(upstream)
+	if err != nil {
+		p.recordMetrics(ctx, time.Since(ctx.StartTime).Seconds(), 0, 0, http.StatusBadGateway, err.Error())
+		http.Error(w, "Ollama unavailable: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		p.recordMetrics(ctx, time.Since(ctx.StartTime).Seconds(), 0, 0, http.StatusBadGateway, err.Error())
+		http.Error(w, "Failed to read Ollama response: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	ctx.ResponseBytes = len(respBody)
+	p.processNonStreamingResponse(ctx, respBody, resp.StatusCode)
+
+	result.StatusCode = resp.StatusCode
+	result.Replay = ReplayStats{
+		Response:       ctx.ResponseText,
+		InputTokens:    ctx.PromptTokens,
+		LatencySeconds: time.Since(ctx.StartTime).Seconds(),
+	}
+	var data map[string]interface{}
+	if json.Unmarshal(respBody, &data) == nil {
+		result.Replay.OutputTokens = responseTokens(data)
+		if resp.StatusCode >= 400 {
+			result.Error = upstreamErrorMessage(data)
+		}
+	}
+	result.Comparison.OutputTokensDiff = result.Replay.OutputTokens - result.Original.OutputTokens
+	result.Comparison.LatencyDiffSeconds = result.Replay.LatencySeconds - result.Original.LatencySeconds
+	result.Comparison.LatencyChange = percentChange(result.Original.LatencySeconds, result.Replay.LatencySeconds)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync/atomic"
+)
+
+// readRequestBody reads a request body, capped by limited. Bodies up to
+// STREAM_REQUEST_BODY_BYTES (or any size when that is 0) are read whole.
+// Larger ones return only their first bytes with streamed set: the caller
+// forwards the rest as it arrives with streamRequestBody.
+func (p *Proxy) readRequestBody(contentLength int64, limited io.Reader) (body []byte, streamed bool, err error) {
+	if p.streamRequestBody <= 0 || (contentLength >= 0 && contentLength <= p.streamRequestBody) {
+		body, err = io.ReadAll(limited)
+		return body, false, err
+	}
+	body, err = io.ReadAll(io.LimitReader(limited, p.streamRequestBody+1))
+	if err != nil {
+		return nil, false, err
+	}
+	return body, int64(len(body)) > p.streamRequestBody, nil
+}
+
+// streamedBody forwards a request body whose start was already read, counting
+// the bytes that pass through on their way upstream
+type streamedBody struct {
+	io.Reader
+	io.Closer
+	size atomic.Int64
+}
+
+func (b *streamedBody) Write(p []byte) (int, error) {
+	b.size.Add(int64(len(p)))
+	return len(p), nil
+}
+
+// streamRequestBody replaces the request body with prefix followed by the
+// unread remainder, so Ollama receives it without the proxy buffering it all.
+// Such requests aren't retried since the body can't be replayed.
+func streamRequestBody(prefix []byte, rest io.ReadCloser) *streamedBody {
+	b := &streamedBody{Closer: rest}
+	b.size.Store(int64(len(prefix)))
+	b.Reader = io.MultiReader(bytes.NewReader(prefix), io.TeeReader(rest, b))
+	return b
+}
+
+// decodeRequestJSON decodes a JSON request body. For a body cut short (the
+// start of a streamed body) it returns the top-level fields that are complete,
+// which usually includes the model and prompt sent ahead of large attachments.
+func decodeRequestJSON(body []byte) (map[string]interface{}, bool) {
+	var data map[string]interface{}
+	if err := json.Unmarshal(body, &data); err == nil {
+		return data, true
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, false
+	}
+	data = make(map[string]interface{})
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		key, ok := tok.(string)
+		if !ok {
+			break
+		}
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			break
+		}
+		data[key] = value
+	}
+	return data, len(data) > 0
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"container/list"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultResponseCacheSize is how many responses the cache holds when RESPONSE_CACHE_SIZE is unset
+const DefaultResponseCacheSize = 1000
+
+// responseCache is an LRU cache of complete non-streaming responses to
+// deterministic requests, served without contacting Ollama until they expire
+type responseCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	size    int
+	all     bool // Also cache requests that aren't deterministic (CACHE_ALL)
+	entries map[string]*list.Element
+	order   *list.List // Most recently used at the front
+	hits    atomic.Int64
+	misses  atomic.Int64
+}
+
+// cachedResponse is one stored response
+type cachedResponse struct {
+	key     string
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// newResponseCache returns the response cache when RESPONSE_CACHE_TTL is set, otherwise nil
+func newResponseCache() *responseCache {
+	ttl := getEnvDuration("RESPONSE_CACHE_TTL", 0)
+	if ttl == 0 {
+		return nil
+	}
+	size := getEnvInt("RESPONSE_CACHE_SIZE", DefaultResponseCacheSize)
+	if size <= 0 {
+		size = DefaultResponseCacheSize
+	}
+	c := &responseCache{
+		ttl:     ttl,
+		size:    size,
+		all:     strings.EqualFold(strings.TrimSpace(os.Getenv("CACHE_ALL")), "true"),
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+	log.Printf("Response cache enabled: %d entries, TTL %s (all requests: %v)", size, ttl, c.all)
+	return c
+}
+
+// key returns the cache key for a request, or false when it can't be cached:
+// streamed, not an inference request, or not deterministic unless CACHE_ALL is set
+func (c *responseCache) key(path, endpoint string, body []byte, streaming bool) (string, bool) {
+	if c == nil || streaming || len(body) == 0 || !shouldTrackEndpoint(endpoint) {
+		return "", false
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return "", false
+	}
+	if !c.all && !deterministicRequest(endpoint, data) {
+		return "", false
+	}
+	return requestKey(path, data), true
+}
+
+// get returns an unexpired response for key and marks it most recently used
+func (c *responseCache) get(key string) (*cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if ok {
+		entry := el.Value.(*cachedResponse)
+		if time.Now().Before(entry.expires) {
+			c.order.MoveToFront(el)
+			c.hits.Add(1)
+			return entry, true
+		}
+		c.order.Remove(el)
+		delete(c.entries, key)
+	}
+	c.misses.Add(1)
+	return nil, false
+}
+
+// put stores a complete 200 response captured by rec, evicting the least
+// recently used entry when the cache is full
+func (c *responseCache) put(key string, rec *flightRecorder) {
+	if !rec.complete || rec.status != http.StatusOK || rec.unshareable {
+		return
+	}
+	header := rec.Header().Clone()
+	header.Del(requestIDHeader)
+	header.Del("X-Proxy-Coalesced")
+	entry := &cachedResponse{key: key, header: header, body: rec.body.Bytes(), expires: time.Now().Add(c.ttl)}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedResponse).key)
+	}
+}
+
+// stats reports the cache's size and hit rate for /analytics/stats
+func (c *responseCache) stats() map[string]interface{} {
+	if c == nil {
+		return map[string]interface{}{"enabled": false}
+	}
+	c.mu.Lock()
+	entries := c.order.Len()
+	c.mu.Unlock()
+
+	hits, misses := c.hits.Load(), c.misses.Load()
+	hitRate := 0.0
+	if hits+misses > 0 {
+		hitRate = math.Round(float64(hits)/float64(hits+misses)*10000) / 10000
+	}
+	return map[string]interface{}{
+		"enabled":     true,
+		"entries":     entries,
+		"max_entries": c.size,
+		"ttl_seconds": c.ttl.Seconds(),
+		"hits":        hits,
+		"misses":      misses,
+		"hit_rate":    hitRate,
+	}
+}
+
+// serveCachedResponse answers a request from the cache and records it with
+// status cache_hit. Returns false on a miss.
+func (p *Proxy) serveCachedResponse(w http.ResponseWriter, ctx *ProxyContext, key string) bool {
+	entry, ok := p.responseCache.get(key)
+	if !ok {
+		// Marks the response stored for this request
+		w.Header().Set("X-Proxy-Cache", "MISS")
+		return false
+	}
+
+	for name, values := range entry.header {
+		w.Header()[name] = append([]string(nil), values...)
+	}
+	w.Header().Set("X-Proxy-Cache", "HIT")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(entry.body); err != nil {
+		log.Printf("[%s] Failed to write cached response: %v", ctx.RequestID, err)
+	}
+
+	ctx.CacheHit = true
+	ctx.ResponseBytes = len(entry.body)
+	p.processNonStreamingResponse(ctx, entry.body, http.StatusOK)
+	return true
+}
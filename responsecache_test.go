@@ -0,0 +1,160 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestResponseCache returns a cache holding size entries for ttl
+func newTestResponseCache(t *testing.T, ttl, size string) *responseCache {
+	t.Helper()
+	t.Setenv("RESPONSE_CACHE_TTL", ttl)
+	t.Setenv("RESPONSE_CACHE_SIZE", size)
+	t.Setenv("CACHE_ALL", "")
+	c := newResponseCache()
+	if c == nil {
+		t.Fatal("response cache is disabled")
+	}
+	return c
+}
+
+// capturedResponse returns a recorder holding a complete response, as put receives it
+func capturedResponse(status int, body string) *flightRecorder {
+	rec := &flightRecorder{ResponseWriter: httptest.NewRecorder(), limit: 1 << 20}
+	rec.Header().Set("Content-Type", "application/json")
+	rec.Header().Set(requestIDHeader, "leader-request")
+	rec.WriteHeader(status)
+	rec.Write([]byte(body))
+	rec.complete = true
+	return rec
+}
+
+func TestResponseCacheKey(t *testing.T) {
+	c := newTestResponseCache(t, "1m", "10")
+	tests := []struct {
+		name      string
+		path      string
+		endpoint  string
+		body      string
+		streaming bool
+		cacheable bool
+	}{
+		{name: "temperature 0 in options", path: "/api/generate", endpoint: "generate", body: `{"model":"llama3","prompt":"hi","options":{"temperature":0}}`, cacheable: true},
+		{name: "OpenAI temperature 0", path: "/v1/chat/completions", endpoint: "chat/completions", body: `{"model":"llama3","messages":[],"temperature":0}`, cacheable: true},
+		{name: "embeddings", path: "/api/embed", endpoint: "embed", body: `{"model":"nomic-embed-text","input":"hi"}`, cacheable: true},
+		{name: "default temperature", path: "/api/generate", endpoint: "generate", body: `{"model":"llama3","prompt":"hi"}`},
+		{name: "non-zero temperature", path: "/api/chat", endpoint: "chat", body: `{"model":"llama3","messages":[],"options":{"temperature":0.7}}`},
+		{name: "options override top-level temperature", path: "/api/generate", endpoint: "generate", body: `{"model":"llama3","prompt":"hi","temperature":0,"options":{"temperature":0.7}}`},
+		{name: "streaming", path: "/api/generate", endpoint: "generate", body: `{"model":"llama3","prompt":"hi","options":{"temperature":0}}`, streaming: true},
+		{name: "not an inference endpoint", path: "/api/show", endpoint: "show", body: `{"model":"llama3"}`},
+		{name: "invalid JSON", path: "/api/generate", endpoint: "generate", body: `{"model":`},
+		{name: "empty body", path: "/api/generate", endpoint: "generate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := c.key(tt.path, tt.endpoint, []byte(tt.body), tt.streaming); ok != tt.cacheable {
+				t.Errorf("cacheable = %v, want %v", ok, tt.cacheable)
+			}
+		})
+	}
+
+	// Field order doesn't matter, the path does
+	a, _ := c.key("/api/generate", "generate", []byte(`{"model":"llama3","prompt":"hi","options":{"temperature":0}}`), false)
+	b, _ := c.key("/api/generate", "generate", []byte(`{"options":{"temperature":0},"prompt":"hi","model":"llama3"}`), false)
+	other, _ := c.key("/v1/completions", "completions", []byte(`{"model":"llama3","prompt":"hi","options":{"temperature":0}}`), false)
+	if a != b {
+		t.Error("reordered fields produced a different key")
+	}
+	if a == other {
+		t.Error("different paths produced the same key")
+	}
+
+	// CACHE_ALL caches sampled requests too
+	t.Setenv("CACHE_ALL", "true")
+	if _, ok := newResponseCache().key("/api/generate", "generate", []byte(`{"model":"llama3","prompt":"hi"}`), false); !ok {
+		t.Error("CACHE_ALL did not cache a sampled request")
+	}
+}
+
+func TestResponseCacheGetPut(t *testing.T) {
+	c := newTestResponseCache(t, "1m", "10")
+
+	if _, ok := c.get("missing"); ok {
+		t.Fatal("empty cache returned an entry")
+	}
+	c.put("key", capturedResponse(http.StatusOK, `{"response":"hello"}`))
+	entry, ok := c.get("key")
+	if !ok {
+		t.Fatal("stored response was not returned")
+	}
+	if string(entry.body) != `{"response":"hello"}` {
+		t.Errorf("body = %q", entry.body)
+	}
+	if entry.header.Get("Content-Type") != "application/json" || entry.header.Get(requestIDHeader) != "" {
+		t.Errorf("header = %v, want Content-Type kept and the request ID dropped", entry.header)
+	}
+
+	// Only complete 200 responses that fit the capture limit are stored
+	failed := capturedResponse(http.StatusInternalServerError, `{"error":"boom"}`)
+	incomplete := capturedResponse(http.StatusOK, `{"response":"hel`)
+	incomplete.complete = false
+	oversized := &flightRecorder{ResponseWriter: httptest.NewRecorder(), limit: 4}
+	oversized.Write([]byte(`{"response":"hello"}`))
+	oversized.complete = true
+	for name, rec := range map[string]*flightRecorder{"error": failed, "incomplete": incomplete, "oversized": oversized} {
+		c.put(name, rec)
+		if _, ok := c.get(name); ok {
+			t.Errorf("%s response was cached", name)
+		}
+	}
+
+	stats := c.stats()
+	if stats["hits"] != int64(1) || stats["entries"] != 1 {
+		t.Errorf("stats = %v, want 1 hit and 1 entry", stats)
+	}
+}
+
+func TestResponseCacheLRUEviction(t *testing.T) {
+	c := newTestResponseCache(t, "1m", "2")
+
+	c.put("a", capturedResponse(http.StatusOK, "a"))
+	c.put("b", capturedResponse(http.StatusOK, "b"))
+	c.get("a") // b is now the least recently used
+	c.put("c", capturedResponse(http.StatusOK, "c"))
+
+	if _, ok := c.get("b"); ok {
+		t.Error("least recently used entry was not evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.get(key); !ok {
+			t.Errorf("entry %q was evicted", key)
+		}
+	}
+
+	// Replacing an entry doesn't grow the cache
+	c.put("a", capturedResponse(http.StatusOK, "a2"))
+	if entry, _ := c.get("a"); entry == nil || string(entry.body) != "a2" {
+		t.Error("entry was not replaced")
+	}
+	if entries := c.stats()["entries"]; entries != 2 {
+		t.Errorf("entries = %v, want 2", entries)
+	}
+}
+
+func TestResponseCacheTTL(t *testing.T) {
+	c := newTestResponseCache(t, "50ms", "10")
+
+	c.put("key", capturedResponse(http.StatusOK, "hello"))
+	if _, ok := c.get("key"); !ok {
+		t.Fatal("fresh entry was not returned")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, ok := c.get("key"); ok {
+		t.Fatal("expired entry was returned")
+	}
+	if entries := c.stats()["entries"]; entries != 0 {
+		t.Errorf("entries = %v, want the expired entry removed", entries)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// retryTransport retries upstream requests that fail transiently, such as
+// while Ollama is restarting. Retries happen inside RoundTrip, before any
+// response bytes reach the client, and only for non-streaming requests.
+type retryTransport struct {
+	next       http.RoundTripper
+	maxRetries int           // Additional attempts after the first (0 disables retries)
+	backoff    time.Duration // Delay before the first retry, doubled each attempt
+}
+
+// newRetryTransport wraps next with the retry policy from PROXY_MAX_RETRIES and PROXY_RETRY_BACKOFF
+func newRetryTransport(next http.RoundTripper) *retryTransport {
+	maxRetries := getEnvInt("PROXY_MAX_RETRIES", 2)
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	return &retryTransport{
+		next:       next,
+		maxRetries: maxRetries,
+		backoff:    getEnvDuration("PROXY_RETRY_BACKOFF", 500*time.Millisecond),
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	pctx := getProxyContext(req.Context())
+	if t.maxRetries == 0 || !isRetryableRequest(req, pctx) {
+		return t.next.RoundTrip(req)
+	}
+
+	backoff := t.backoff
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if attempt > 0 {
+			// Each attempt needs a fresh copy of the buffered body
+			attemptReq = req.Clone(req.Context())
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				attemptReq.Body = body
+			}
+		}
+
+		resp, err := t.next.RoundTrip(attemptReq)
+		if attempt >= t.maxRetries || !shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		reason := "503 Service Unavailable"
+		if err != nil {
+			reason = err.Error()
+		} else {
+			resp.Body.Close()
+		}
+		log.Printf("[%s] Upstream attempt %d/%d for %s failed (%s), retrying in %s",
+			req.Header.Get(requestIDHeader), attempt+1, t.maxRetries+1, req.URL.Path, reason, backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		backoff *= 2
+
+		if pctx != nil {
+			pctx.Retries = attempt + 1
+		}
+	}
+}
+
+// isRetryableRequest reports whether a request can safely be replayed upstream
+func isRetryableRequest(req *http.Request, pctx *ProxyContext) bool {
+	if pctx != nil && pctx.Streaming {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	return true
+}
+
+// shouldRetry reports whether an upstream result looks transient
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return isConnectionRefused(err)
+	}
+	return resp.StatusCode == http.StatusServiceUnavailable
+}
+
+// isConnectionRefused detects connection-refused errors on all platforms
+func isConnectionRefused(err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "connection refused") || strings.Contains(msg, "actively refused")
+}
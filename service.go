@@ -5,6 +5,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
@@ -35,58 +36,40 @@ func (s *ollamaProxyService) Execute(args []string, r <-chan svc.ChangeRequest,
 	LogPrintf("Ollama Proxy Service starting")
 	LogPrintf("Working directory: %s", getCurrentDirectory())
 
-	// Find ollama executable
-	ollamaPath, err := findOllamaExecutable()
-	if err != nil {
-		s.elog.Error(1, fmt.Sprintf("Failed to find Ollama: %v", err))
-		LogPrintf("ERROR: Failed to find Ollama: %v", err)
-		changes <- svc.Status{State: svc.Stopped}
-		return false, 1
-	}
+	targetURL := "http://localhost:11435"
+	remoteURL := getExternalTargetURL()
+	var ollamaPath string
 
-	// Kill any existing Ollama processes on default port
-	LogPrintf("Checking for existing Ollama processes...")
-	if err := killExistingOllama(); err != nil {
-		s.elog.Warning(1, fmt.Sprintf("Failed to kill existing Ollama: %v", err))
-		LogPrintf("WARNING: Failed to kill existing Ollama: %v", err)
-	}
-
-	// Start Ollama on port 11435 (hidden port)
-	s.elog.Info(1, fmt.Sprintf("Starting Ollama from: %s on port 11435", ollamaPath))
-	LogPrintf("Starting Ollama from: %s on port 11435", ollamaPath)
-	s.ollamaProcess, err = startOllama(ollamaPath, 11435)
-	if err != nil {
-		s.elog.Error(1, fmt.Sprintf("CRITICAL: Failed to start Ollama: %v", err))
-		LogPrintf("CRITICAL ERROR: Failed to start Ollama: %v", err)
-		changes <- svc.Status{State: svc.Stopped}
-		return false, 1
-	}
-	
-	// Cleanup function for Ollama
-	defer func() {
-		if s.ollamaProcess != nil {
-			s.elog.Info(1, "Stopping Ollama process in defer")
-			s.ollamaProcess.Stop()
-			// Give it time to terminate
-			time.Sleep(2 * time.Second)
+	if remoteURL != "" {
+		// Remote or unmanaged mode: Ollama is managed elsewhere, only run the metrics layer
+		targetURL = remoteURL
+		s.elog.Info(1, fmt.Sprintf("External Ollama: forwarding to %s", remoteURL))
+		LogPrintf("External Ollama: forwarding to %s (Ollama not managed)", remoteURL)
+	} else {
+		var err error
+		ollamaPath, err = s.startLocalOllama()
+		if err != nil {
+			changes <- svc.Status{State: svc.Stopped}
+			return false, 1
 		}
-	}()
 
-	// Wait for Ollama to be ready on 11435
-	s.elog.Info(1, "Waiting for Ollama to be ready on port 11435...")
-	LogPrintf("Waiting for Ollama to be ready on port 11435...")
-	if !waitForOllama("localhost", 11435, 30*time.Second) {
-		s.elog.Error(1, "CRITICAL: Ollama did not become ready within 30 seconds")
-		LogPrintf("CRITICAL ERROR: Ollama did not become ready within 30 seconds")
-		changes <- svc.Status{State: svc.Stopped}
-		return false, 1
+		// Cleanup function for Ollama
+		defer func() {
+			if s.ollamaProcess != nil {
+				s.elog.Info(1, "Stopping Ollama process in defer")
+				s.ollamaProcess.Stop()
+				// Give it time to terminate
+				time.Sleep(2 * time.Second)
+			}
+		}()
 	}
-	s.elog.Info(1, "Ollama is ready on port 11435!")
-	LogPrintf("Ollama is ready on port 11435!")
 
-	// Start metrics proxy on 11434 (where apps expect Ollama) forwarding to 11435
-	LogPrintf("Creating proxy to forward localhost:11434 -> localhost:11435")
-	s.proxy = NewProxy("http://localhost:11435", 11434, true)
+	// Start metrics proxy on 11434 (where apps expect Ollama) forwarding to Ollama
+	LogPrintf("Creating proxy to forward localhost:11434 -> %s", targetURL)
+	s.proxy = NewProxy(targetURL, 11434, true)
+	if remoteURL == "" {
+		s.proxy.enableIdleShutdown(ollamaPath, 11435, &s.ollamaProcess)
+	}
 	
 	// Start proxy in background
 	go func() {
@@ -115,14 +98,20 @@ func (s *ollamaProxyService) Execute(args []string, r <-chan svc.ChangeRequest,
 	changes <- svc.Status{State: svc.Running, Accepts: cmdsAccepted}
 	s.elog.Info(1, "Ollama Proxy Service started successfully")
 	LogPrintf("Ollama Proxy Service is now running")
-	LogPrintf("Proxy: http://localhost:11434 -> Ollama: http://localhost:11435")
+	LogPrintf("Proxy: http://localhost:11434 -> Ollama: %s", targetURL)
+
+	// Preload WARMUP_MODELS in the background: loading can take minutes and
+	// the service must report Running to the service manager promptly
+	go warmupModels(targetURL)
 
 	// Start health monitoring in background
 	stopHealthCheck := make(chan bool)
-	go s.monitorOllamaHealth(ollamaPath, stopHealthCheck)
-	defer func() {
-		stopHealthCheck <- true
-	}()
+	if remoteURL != "" {
+		go s.monitorRemoteOllama(remoteURL, stopHealthCheck)
+	} else {
+		go s.monitorOllamaHealth(ollamaPath, stopHealthCheck)
+	}
+	defer close(stopHealthCheck) // Close rather than send: the monitor may have already given up
 
 loop:
 	for {
@@ -134,17 +123,19 @@ loop:
 			changes <- c.CurrentStatus
 		case svc.Stop, svc.Shutdown:
 			s.elog.Info(1, "Service stop requested")
-			// CRITICAL: Stop Ollama process FIRST before shutting down proxy
+			// Shut down the proxy first so in-flight streams can drain while
+			// Ollama is still running. Tell the SCM how long that may take.
+			if s.proxy != nil {
+				changes <- svc.Status{State: svc.StopPending, WaitHint: uint32((s.proxy.drainTimeout + 15*time.Second) / time.Millisecond)}
+				s.proxy.Shutdown()
+			}
+			// CRITICAL: Always stop the Ollama process before the service exits
 			if s.ollamaProcess != nil {
 				s.elog.Info(1, "Stopping Ollama process...")
 				s.ollamaProcess.Stop()
 				// Give it time to terminate
 				time.Sleep(2 * time.Second)
 			}
-			// Then shutdown proxy
-			if s.proxy != nil {
-				s.proxy.Shutdown()
-			}
 			break loop
 		default:
 			s.elog.Error(1, fmt.Sprintf("Unexpected control request #%d", c))
@@ -155,15 +146,124 @@ loop:
 	return false, 0
 }
 
-// monitorOllamaHealth monitors Ollama health and restarts if crashed
+// startLocalOllama finds, starts and waits for the managed Ollama process on port 11435
+func (s *ollamaProxyService) startLocalOllama() (string, error) {
+	// Find ollama executable
+	ollamaPath, err := findOllamaExecutable()
+	if err != nil {
+		s.elog.Error(1, fmt.Sprintf("Failed to find Ollama: %v", err))
+		LogPrintf("ERROR: Failed to find Ollama: %v", err)
+		return "", err
+	}
+
+	// Kill any existing Ollama processes on default port
+	LogPrintf("Checking for existing Ollama processes...")
+	if err := killExistingOllama(); err != nil {
+		s.elog.Warning(1, fmt.Sprintf("Failed to kill existing Ollama: %v", err))
+		LogPrintf("WARNING: Failed to kill existing Ollama: %v", err)
+	}
+
+	// Start Ollama on port 11435 (hidden port)
+	s.elog.Info(1, fmt.Sprintf("Starting Ollama from: %s on port 11435", ollamaPath))
+	LogPrintf("Starting Ollama from: %s on port 11435", ollamaPath)
+	s.ollamaProcess, err = startOllama(ollamaPath, 11435)
+	if err != nil {
+		s.elog.Error(1, fmt.Sprintf("CRITICAL: Failed to start Ollama: %v", err))
+		LogPrintf("CRITICAL ERROR: Failed to start Ollama: %v", err)
+		return "", err
+	}
+
+	// Wait for Ollama to be ready on 11435
+	s.elog.Info(1, "Waiting for Ollama to be ready on port 11435...")
+	LogPrintf("Waiting for Ollama to be ready on port 11435...")
+	if !waitForOllama("localhost", 11435, 30*time.Second) {
+		s.elog.Error(1, "CRITICAL: Ollama did not become ready within 30 seconds")
+		LogPrintf("CRITICAL ERROR: Ollama did not become ready within 30 seconds")
+		s.ollamaProcess.Stop()
+		s.ollamaProcess = nil
+		return "", fmt.Errorf("ollama did not become ready")
+	}
+	s.elog.Info(1, "Ollama is ready on port 11435!")
+	LogPrintf("Ollama is ready on port 11435!")
+
+	return ollamaPath, nil
+}
+
+// monitorRemoteOllama pings a remote Ollama and logs reachability changes.
+// The remote process isn't ours, so it is never restarted.
+func (s *ollamaProxyService) monitorRemoteOllama(remoteURL string, stop <-chan bool) {
+	interval := getEnvDuration("OLLAMA_HEALTH_CHECK_INTERVAL", 30*time.Second)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	healthy := true
+	LogPrintf("Remote health monitoring started for %s (checking every %s)", remoteURL, interval)
+
+	for {
+		select {
+		case <-stop:
+			LogPrintf("Health monitoring stopped")
+			return
+		case <-ticker.C:
+			if err := pingOllama(remoteURL, 10*time.Second); err != nil {
+				if healthy {
+					s.elog.Warning(1, fmt.Sprintf("Remote Ollama at %s is unreachable: %v", remoteURL, err))
+				}
+				LogPrintf("WARNING: Remote Ollama at %s is unreachable: %v", remoteURL, err)
+				healthy = false
+			} else if !healthy {
+				s.elog.Info(1, fmt.Sprintf("Remote Ollama at %s is reachable again", remoteURL))
+				LogPrintf("Remote Ollama at %s is reachable again", remoteURL)
+				healthy = true
+			}
+		}
+	}
+}
+
+// restartPolicy controls how the health monitor restarts a crashed Ollama
+type restartPolicy struct {
+	checkInterval      time.Duration // How often Ollama is health checked
+	maxFailures        int           // Consecutive failed checks before restarting
+	backoffBase        time.Duration // Initial delay between restart attempts
+	backoffMax         time.Duration // Upper bound for the restart delay
+	maxRestartsPerHour int           // Give up after this many restarts in an hour
+	readyTimeout       time.Duration // How long to wait for a restarted Ollama
+}
+
+// loadRestartPolicy reads the health monitor configuration from the environment
+func loadRestartPolicy() restartPolicy {
+	policy := restartPolicy{
+		checkInterval:      getEnvDuration("OLLAMA_HEALTH_CHECK_INTERVAL", 30*time.Second),
+		maxFailures:        getEnvInt("OLLAMA_HEALTH_MAX_FAILURES", 1),
+		backoffBase:        getEnvDuration("OLLAMA_RESTART_BACKOFF_BASE", 10*time.Second),
+		backoffMax:         getEnvDuration("OLLAMA_RESTART_BACKOFF_MAX", 5*time.Minute),
+		maxRestartsPerHour: getEnvInt("OLLAMA_MAX_RESTARTS_PER_HOUR", 5),
+		readyTimeout:       getEnvDuration("OLLAMA_RESTART_READY_TIMEOUT", 60*time.Second),
+	}
+	if policy.maxFailures < 1 {
+		policy.maxFailures = 1
+	}
+	if policy.backoffMax < policy.backoffBase {
+		policy.backoffMax = policy.backoffBase
+	}
+	return policy
+}
+
+// monitorOllamaHealth monitors Ollama health and restarts if crashed,
+// backing off exponentially between restart attempts
 func (s *ollamaProxyService) monitorOllamaHealth(ollamaPath string, stop <-chan bool) {
-	ticker := time.NewTicker(30 * time.Second)
+	policy := loadRestartPolicy()
+
+	ticker := time.NewTicker(policy.checkInterval)
 	defer ticker.Stop()
 
 	consecutiveFailures := 0
-	const maxFailures = 1 // Restart immediately on failure
+	backoff := policy.backoffBase
+	var nextRestartAllowed, lastRestart time.Time
+	var restartTimes []time.Time
 
-	LogPrintf("Health monitoring started (checking every 30s, 10s timeout)")
+	LogPrintf("Health monitoring started (checking every %s, restart after %d failures, backoff %s-%s, max %d restarts/hour)",
+		policy.checkInterval, policy.maxFailures, policy.backoffBase, policy.backoffMax, policy.maxRestartsPerHour)
 
 	for {
 		select {
@@ -171,58 +271,123 @@ func (s *ollamaProxyService) monitorOllamaHealth(ollamaPath string, stop <-chan
 			LogPrintf("Health monitoring stopped")
 			return
 		case <-ticker.C:
+			// Ollama stopped for being idle is started by the next request
+			if s.proxy.idle.isStopped() {
+				consecutiveFailures = 0
+				continue
+			}
+
 			// Check if Ollama is responsive (10s timeout for faster response)
-			if !waitForOllama("localhost", 11435, 10*time.Second) {
-				consecutiveFailures++
-				s.elog.Warning(1, fmt.Sprintf("Ollama health check failed (%d/%d)", consecutiveFailures, maxFailures))
-				LogPrintf("WARNING: Ollama health check failed (%d/%d)", consecutiveFailures, maxFailures)
-
-				if consecutiveFailures >= maxFailures {
-					s.elog.Error(1, "Ollama appears to have crashed - attempting restart")
-					LogPrintf("CRITICAL: Ollama appears to have crashed - attempting restart")
-
-					// Stop old process
-					if s.ollamaProcess != nil {
-						s.ollamaProcess.Stop()
-						time.Sleep(2 * time.Second)
-					}
-
-					// Kill any remaining Ollama processes
-					if err := killExistingOllama(); err != nil {
-						LogPrintf("Warning: Failed to kill existing Ollama: %v", err)
-					}
-
-					// Restart Ollama
-					newProcess, err := startOllama(ollamaPath, 11435)
-					if err != nil {
-						s.elog.Error(1, fmt.Sprintf("Failed to restart Ollama: %v", err))
-						LogPrintf("ERROR: Failed to restart Ollama: %v", err)
-					} else {
-						s.ollamaProcess = newProcess
-						if waitForOllama("localhost", 11435, 30*time.Second) {
-							s.elog.Info(1, "Ollama restarted successfully")
-							LogPrintf("SUCCESS: Ollama restarted successfully")
-							consecutiveFailures = 0
-						} else {
-							s.elog.Error(1, "Ollama restart failed - not responding")
-							LogPrintf("ERROR: Ollama restart failed - not responding")
-						}
-					}
-				}
-			} else {
+			if waitForOllama("localhost", 11435, 10*time.Second) {
 				// Health check passed
 				if consecutiveFailures > 0 {
 					LogPrintf("Ollama health check recovered")
 				}
 				consecutiveFailures = 0
+
+				// Reset the backoff once Ollama has stayed up for a while
+				if backoff != policy.backoffBase && time.Since(lastRestart) > policy.backoffMax {
+					backoff = policy.backoffBase
+				}
+				continue
+			}
+
+			if s.proxy.idle.isStopped() {
+				// Stopped for being idle while the check ran
+				continue
+			}
+			consecutiveFailures++
+			s.elog.Warning(1, fmt.Sprintf("Ollama health check failed (%d/%d)", consecutiveFailures, policy.maxFailures))
+			LogPrintf("WARNING: Ollama health check failed (%d/%d)", consecutiveFailures, policy.maxFailures)
+
+			if consecutiveFailures < policy.maxFailures {
+				continue
+			}
+
+			if time.Now().Before(nextRestartAllowed) {
+				LogPrintf("Restart backoff in effect, next attempt in %s", time.Until(nextRestartAllowed).Round(time.Second))
+				continue
+			}
+
+			// Enforce the hourly restart cap
+			cutoff := time.Now().Add(-1 * time.Hour)
+			recent := restartTimes[:0]
+			for _, t := range restartTimes {
+				if t.After(cutoff) {
+					recent = append(recent, t)
+				}
+			}
+			restartTimes = recent
+			if len(restartTimes) >= policy.maxRestartsPerHour {
+				s.elog.Error(1, fmt.Sprintf("CRITICAL: Ollama restarted %d times in the last hour - giving up on automatic restarts", len(restartTimes)))
+				LogPrintf("CRITICAL: Ollama restarted %d times in the last hour - giving up on automatic restarts", len(restartTimes))
+				return
+			}
+
+			s.elog.Error(1, "Ollama appears to have crashed - attempting restart")
+			slog.Warn("Ollama appears to have crashed - attempting restart", "consecutive_failures", consecutiveFailures, "restarts_last_hour", len(restartTimes))
+
+			lastRestart = time.Now()
+			restartTimes = append(restartTimes, lastRestart)
+			nextRestartAllowed = lastRestart.Add(backoff)
+			LogPrintf("Next restart attempt allowed after %s", backoff)
+			backoff *= 2
+			if backoff > policy.backoffMax {
+				backoff = policy.backoffMax
+			}
+
+			// Idle shutdown and cold starts also replace the process; restart
+			// under the same lock so a cold start's Ollama is never killed
+			var restarted bool
+			if !s.proxy.idle.exclusive(func() { restarted = s.restartOllama(ollamaPath, policy.readyTimeout) }) {
+				// Stopped for being idle while the check ran
+				consecutiveFailures = 0
+				continue
+			}
+			if restarted {
+				consecutiveFailures = 0
 			}
 		}
 	}
 }
 
-func runAsService() {
-	const svcName = "OllamaMetricsProxy"
+// restartOllama stops the current Ollama process and starts a new one
+func (s *ollamaProxyService) restartOllama(ollamaPath string, readyTimeout time.Duration) bool {
+	// Stop old process
+	if s.ollamaProcess != nil {
+		s.ollamaProcess.Stop()
+		time.Sleep(2 * time.Second)
+	}
+
+	// Kill any remaining Ollama processes
+	if err := killExistingOllama(); err != nil {
+		LogPrintf("Warning: Failed to kill existing Ollama: %v", err)
+	}
 
+	// Restart Ollama
+	newProcess, err := startOllama(ollamaPath, 11435)
+	if err != nil {
+		s.elog.Error(1, fmt.Sprintf("Failed to restart Ollama: %v", err))
+		slog.Error("Ollama restart failed", "error", err)
+		return false
+	}
+	s.ollamaProcess = newProcess
+
+	if !waitForOllama("localhost", 11435, readyTimeout) {
+		s.elog.Error(1, "Ollama restart failed - not responding")
+		slog.Error("Ollama restart failed", "error", "not responding", "ready_timeout", readyTimeout)
+		return false
+	}
+
+	s.elog.Info(1, "Ollama restarted successfully")
+	slog.Info("Ollama restarted successfully")
+	if s.proxy != nil {
+		s.proxy.markOllamaRestart()
+	}
+	return true
+}
+
+func runAsService() {
 	isIntSess, err := svc.IsAnInteractiveSession()
 	if err != nil {
 		log.Fatalf("Failed to determine if we are running in an interactive session: %v", err)
@@ -232,13 +397,13 @@ func runAsService() {
 		log.Fatal("Cannot run service in interactive session")
 	}
 
-	elog, err := eventlog.Open(svcName)
+	elog, err := eventlog.Open(windowsServiceName)
 	if err != nil {
 		return
 	}
 	defer elog.Close()
 
-	err = svc.Run(svcName, &ollamaProxyService{elog: elog})
+	err = svc.Run(windowsServiceName, &ollamaProxyService{elog: elog})
 	if err != nil {
 		elog.Error(1, fmt.Sprintf("Service failed: %v", err))
 		return
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// singleFlight coalesces identical concurrent non-streaming requests: the
+// first one is forwarded to Ollama and the others wait for its response
+type singleFlight struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+	force bool // Also coalesce requests that aren't deterministic
+}
+
+// flightCall is one request in progress whose response others are waiting for
+type flightCall struct {
+	done      chan struct{}
+	requestID string // Request that was forwarded to Ollama
+	ok        bool   // A complete 200 response was captured
+	header    http.Header
+	body      []byte
+}
+
+// newSingleFlight returns the single-flight layer when ENABLE_SINGLEFLIGHT=true, otherwise nil
+func newSingleFlight() *singleFlight {
+	if !strings.EqualFold(strings.TrimSpace(os.Getenv("ENABLE_SINGLEFLIGHT")), "true") {
+		return nil
+	}
+	sf := &singleFlight{
+		calls: make(map[string]*flightCall),
+		force: strings.EqualFold(strings.TrimSpace(os.Getenv("SINGLEFLIGHT_FORCE")), "true"),
+	}
+	log.Printf("Single-flight enabled for identical concurrent requests (force=%v)", sf.force)
+	return sf
+}
+
+// key returns the coalescing key for a request, or false when it must be
+// forwarded on its own: streamed, not an inference request, or sampled with
+// randomness unless SINGLEFLIGHT_FORCE is set
+func (sf *singleFlight) key(path, endpoint string, body []byte, streaming bool) (string, bool) {
+	if sf == nil || streaming || len(body) == 0 || !shouldTrackEndpoint(endpoint) {
+		return "", false
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return "", false
+	}
+	if !sf.force && !deterministicRequest(endpoint, data) {
+		return "", false
+	}
+	return requestKey(path, data), true
+}
+
+// deterministicRequest reports whether a request always produces the same
+// output: embeddings, or generations with temperature 0. Ollama samples with
+// a non-zero temperature when none is given.
+func deterministicRequest(endpoint string, data map[string]interface{}) bool {
+	if isEmbeddingEndpoint(endpoint) {
+		return true
+	}
+	// OpenAI-compatible requests set it at the top level, Ollama in options
+	temperature, ok := data["temperature"]
+	if options, isMap := data["options"].(map[string]interface{}); isMap {
+		if t, set := options["temperature"]; set {
+			temperature, ok = t, true
+		}
+	}
+	t, isNumber := temperature.(float64)
+	return ok && isNumber && t == 0
+}
+
+// requestKey hashes the path and the request body. The body is re-encoded
+// first so clients that order JSON fields differently share a key.
+func requestKey(path string, data map[string]interface{}) string {
+	canonical, _ := json.Marshal(data)
+	sum := sha256.Sum256(append([]byte(path+"\x00"), canonical...))
+	return hex.EncodeToString(sum[:])
+}
+
+// join returns the call in progress for key, or registers a new one, in which
+// case the caller is the leader and must forward the request and finish it
+func (sf *singleFlight) join(key, requestID string) (call *flightCall, leader bool) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if call, ok := sf.calls[key]; ok {
+		return call, false
+	}
+	call = &flightCall{done: make(chan struct{}), requestID: requestID}
+	sf.calls[key] = call
+	return call, true
+}
+
+// finish shares the leader's response with the waiting requests
+func (sf *singleFlight) finish(key string, call *flightCall, rec *flightRecorder) {
+	if rec.complete && rec.status == http.StatusOK && !rec.unshareable {
+		call.ok = true
+		call.header = rec.Header().Clone()
+		call.header.Del(requestIDHeader)
+		call.body = rec.body.Bytes()
+	}
+	sf.mu.Lock()
+	delete(sf.calls, key)
+	sf.mu.Unlock()
+	close(call.done)
+}
+
+// flightRecorder passes the leader's response through to its client while
+// keeping a copy for the requests waiting on it
+type flightRecorder struct {
+	http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	limit       int
+	complete    bool // The reverse proxy returned normally
+	unshareable bool // Response exceeded limit or was cut short writing to the client
+}
+
+func (r *flightRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *flightRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	if !r.unshareable {
+		if r.body.Len()+len(b) > r.limit {
+			r.unshareable = true
+			r.body = bytes.Buffer{}
+		} else {
+			r.body.Write(b)
+		}
+	}
+	n, err := r.ResponseWriter.Write(b)
+	if err != nil {
+		// The reverse proxy stops copying, so the captured body is partial
+		r.unshareable = true
+	}
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *flightRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// forwardSingleFlight forwards the first of a set of identical requests and
+// answers the rest with its response. If the shared request fails, each
+// waiting request is forwarded on its own.
+func (p *Proxy) forwardSingleFlight(w http.ResponseWriter, r *http.Request, ctx *ProxyContext, key string) {
+	call, leader := p.singleFlight.join(key, ctx.RequestID)
+	if leader {
+		rec := &flightRecorder{ResponseWriter: w, limit: p.maxResponseCapture}
+		// Deferred so waiters are released even when the reverse proxy
+		// aborts with a panic on a failed upstream read
+		defer p.singleFlight.finish(key, call, rec)
+		p.reverseProxy.ServeHTTP(rec, r)
+		rec.complete = true
+		return
+	}
+
+	log.Printf("[%s] Waiting for identical request %s", ctx.RequestID, call.requestID)
+	select {
+	case <-call.done:
+	case <-r.Context().Done():
+		status, msg := StatusClientClosedRequest, "client disconnected while waiting for a coalesced request"
+		if errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+			status, msg = http.StatusGatewayTimeout, "Upstream request timed out"
+			http.Error(w, msg, status)
+		}
+		p.recordMetrics(ctx, time.Since(ctx.StartTime).Seconds(), 0, 0, status, msg)
+		return
+	}
+
+	if !call.ok {
+		log.Printf("[%s] Identical request %s failed, forwarding on its own", ctx.RequestID, call.requestID)
+		p.reverseProxy.ServeHTTP(w, r)
+		return
+	}
+
+	for name, values := range call.header {
+		w.Header()[name] = append([]string(nil), values...)
+	}
+	w.Header().Set("X-Proxy-Coalesced", call.requestID)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(call.body); err != nil {
+		log.Printf("[%s] Failed to write coalesced response: %v", ctx.RequestID, err)
+	}
+
+	p.metrics.coalescedRequests.WithLabelValues(p.models.label(ctx.Model)).Inc()
+	ctx.CoalescedWith = call.requestID
+	ctx.ResponseBytes = len(call.body)
+	p.processNonStreamingResponse(ctx, call.body, http.StatusOK)
+}
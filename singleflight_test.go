@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe for the log package to write to concurrently
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) count(s string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.Count(b.buf.String(), s)
+}
+
+// captureLog redirects the standard logger for the rest of the test
+func captureLog(t *testing.T) *syncBuffer {
+	t.Helper()
+	buf := &syncBuffer{}
+	log.SetOutput(buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return buf
+}
+
+type coalescedResponse struct {
+	status    int
+	body      string
+	coalesced string
+}
+
+// runCoalesced sends n identical deterministic requests through the proxy while
+// the upstream holds the first one, releasing it once the rest are waiting
+func runCoalesced(t *testing.T, p *Proxy, n int, logs *syncBuffer, upstreamCalls *atomic.Int32, release chan struct{}) []coalescedResponse {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(p.handleProxy))
+	defer server.Close()
+	defer func() {
+		// Unblock the upstream if the test failed before releasing it
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	}()
+
+	responses := make([]coalescedResponse, n)
+	var wg sync.WaitGroup
+	send := func(i int) {
+		defer wg.Done()
+		resp, err := http.Post(server.URL+"/api/generate", "application/json",
+			strings.NewReader(`{"model":"llama3","prompt":"hi","stream":false,"options":{"temperature":0}}`))
+		if err != nil {
+			t.Errorf("request %d: %v", i, err)
+			return
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		responses[i] = coalescedResponse{resp.StatusCode, string(body), resp.Header.Get("X-Proxy-Coalesced")}
+	}
+
+	// Start the leader and wait for it to reach upstream, then the followers
+	wg.Add(1)
+	go send(0)
+	waitFor(t, "leader to reach upstream", func() bool { return upstreamCalls.Load() == 1 })
+	for i := 1; i < n; i++ {
+		wg.Add(1)
+		go send(i)
+	}
+	waitFor(t, "followers to wait on the leader", func() bool {
+		return logs.count("Waiting for identical request") == n-1
+	})
+	close(release)
+	wg.Wait()
+	return responses
+}
+
+// waitFor polls cond for up to 5 seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// serveModelList answers the proxy's own /api/tags lookups so they aren't
+// counted as forwarded requests
+func serveModelList(w http.ResponseWriter, r *http.Request) bool {
+	if r.URL.Path != "/api/tags" {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, `{"models":[{"name":"llama3:latest"}]}`)
+	return true
+}
+
+const singleFlightResponse = `{"model":"llama3","response":"hello","done":true,"eval_count":1}`
+
+func TestSingleFlightCoalescesIdenticalRequests(t *testing.T) {
+	logs := captureLog(t)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if serveModelList(w, r) {
+			return
+		}
+		calls.Add(1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, singleFlightResponse)
+	}))
+	defer upstream.Close()
+
+	t.Setenv("ENABLE_SINGLEFLIGHT", "true")
+	p := newTestProxy(t, upstream.URL)
+
+	responses := runCoalesced(t, p, 4, logs, &calls, release)
+	if got := calls.Load(); got != 1 {
+		t.Errorf("upstream calls = %d, want 1", got)
+	}
+	coalesced := 0
+	for i, resp := range responses {
+		if resp.status != http.StatusOK || resp.body != singleFlightResponse {
+			t.Errorf("response %d = %d %q, want the upstream response", i, resp.status, resp.body)
+		}
+		if resp.coalesced != "" {
+			coalesced++
+		}
+	}
+	if coalesced != 3 {
+		t.Errorf("coalesced responses = %d, want 3", coalesced)
+	}
+}
+
+func TestSingleFlightFallsBack(t *testing.T) {
+	tests := []struct {
+		name         string
+		leaderStatus int
+		captureLimit string
+	}{
+		{name: "leader fails", leaderStatus: http.StatusInternalServerError},
+		{name: "response exceeds capture limit", leaderStatus: http.StatusOK, captureLimit: "16"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLog(t)
+			var calls atomic.Int32
+			release := make(chan struct{})
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if serveModelList(w, r) {
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				if calls.Add(1) == 1 {
+					<-release
+					w.WriteHeader(tt.leaderStatus)
+					if tt.leaderStatus != http.StatusOK {
+						fmt.Fprint(w, `{"error":"model crashed"}`)
+						return
+					}
+				}
+				fmt.Fprint(w, singleFlightResponse)
+			}))
+			defer upstream.Close()
+
+			t.Setenv("ENABLE_SINGLEFLIGHT", "true")
+			t.Setenv("MAX_RESPONSE_CAPTURE_BYTES", tt.captureLimit)
+			p := newTestProxy(t, upstream.URL)
+
+			responses := runCoalesced(t, p, 3, logs, &calls, release)
+			if got := calls.Load(); got != 3 {
+				t.Errorf("upstream calls = %d, want each follower to forward its own request", got)
+			}
+			if got := logs.count("forwarding on its own"); got != 2 {
+				t.Errorf("followers falling back = %d, want 2", got)
+			}
+			for i, resp := range responses[1:] {
+				if resp.status != http.StatusOK || resp.body != singleFlightResponse || resp.coalesced != "" {
+					t.Errorf("follower %d = %d %q (coalesced %q), want its own upstream response", i+1, resp.status, resp.body, resp.coalesced)
+				}
+			}
+		})
+	}
+}
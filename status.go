@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// statusCacheTTL is how long the Ollama details in /status are reused
+const statusCacheTTL = 5 * time.Second
+
+// LoadedModel is a model Ollama currently holds in memory (from /api/ps)
+type LoadedModel struct {
+	Name      string `json:"name"`
+	SizeBytes int64  `json:"size_bytes"`
+	VRAMBytes int64  `json:"vram_bytes"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+}
+
+// ollamaStatus is what /status reports about Ollama itself
+type ollamaStatus struct {
+	Reachable    bool          `json:"reachable"`
+	Version      string        `json:"version,omitempty"`
+	LoadedModels []LoadedModel `json:"loaded_models"`
+	Error        string        `json:"error,omitempty"`
+}
+
+// statusCache keeps the last Ollama status so frequent monitoring polls
+// make at most one pair of upstream calls per statusCacheTTL
+type statusCache struct {
+	mu      sync.Mutex
+	fetched time.Time
+	status  ollamaStatus
+}
+
+// ollamaStatus returns Ollama's version and loaded models, cached briefly
+func (p *Proxy) ollamaStatus() ollamaStatus {
+	p.status.mu.Lock()
+	defer p.status.mu.Unlock()
+
+	if time.Since(p.status.fetched) < statusCacheTTL {
+		return p.status.status
+	}
+
+	status := ollamaStatus{LoadedModels: []LoadedModel{}}
+	client := &http.Client{Timeout: 2 * time.Second}
+
+	var version struct {
+		Version string `json:"version"`
+	}
+	if err := getOllamaJSON(client, p.target.String()+"/api/version", &version); err != nil {
+		status.Error = err.Error()
+	} else {
+		status.Reachable = true
+		status.Version = version.Version
+		p.markUpstreamContact()
+	}
+
+	var ps struct {
+		Models []struct {
+			Name      string `json:"name"`
+			Size      int64  `json:"size"`
+			SizeVRAM  int64  `json:"size_vram"`
+			ExpiresAt string `json:"expires_at"`
+		} `json:"models"`
+	}
+	if status.Reachable {
+		if err := getOllamaJSON(client, p.target.String()+"/api/ps", &ps); err != nil {
+			status.Error = err.Error()
+		}
+		for _, m := range ps.Models {
+			status.LoadedModels = append(status.LoadedModels, LoadedModel{
+				Name:      m.Name,
+				SizeBytes: m.Size,
+				VRAMBytes: m.SizeVRAM,
+				ExpiresAt: m.ExpiresAt,
+			})
+		}
+	}
+
+	p.status.fetched = time.Now()
+	p.status.status = status
+	return status
+}
+
+// getOllamaJSON fetches an Ollama API endpoint and decodes its JSON response
+func getOllamaJSON(client *http.Client, url string, v interface{}) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// markOllamaRestart records that the health monitor restarted Ollama
+func (p *Proxy) markOllamaRestart() {
+	p.ollamaRestarts.Add(1)
+	p.lastOllamaRestart.Store(time.Now().Unix())
+}
+
+// handleStatus reports the proxy's and Ollama's state in one payload for
+// monitoring: uptime, Ollama version, loaded models and restarts
+func (p *Proxy) handleStatus(w http.ResponseWriter, r *http.Request) {
+	// Don't probe an Ollama that was stopped for being idle
+	idleStopped := p.idle.isStopped()
+	ollama := ollamaStatus{LoadedModels: []LoadedModel{}}
+	if !idleStopped {
+		ollama = p.ollamaStatus()
+	}
+
+	status := "ok"
+	if !ollama.Reachable && !idleStopped {
+		status = "degraded"
+	}
+
+	ollamaInfo := map[string]interface{}{
+		"url":           p.target.String(),
+		"reachable":     ollama.Reachable,
+		"loaded_models": ollama.LoadedModels,
+		"restarts":      p.ollamaRestarts.Load(),
+		"last_restart":  nil,
+	}
+	if ollama.Version != "" {
+		ollamaInfo["version"] = ollama.Version
+	}
+	if ollama.Error != "" {
+		ollamaInfo["error"] = ollama.Error
+	}
+	if last := p.lastOllamaRestart.Load(); last > 0 {
+		ollamaInfo["last_restart"] = last
+	}
+	if p.idle != nil {
+		ollamaInfo["idle_stopped"] = idleStopped
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":              status,
+		"started_at":          p.started.Unix(),
+		"uptime_seconds":      int64(time.Since(p.started).Seconds()),
+		"active_streams":      p.streamCount.Load(),
+		"analytics_available": p.analytics.Available(),
+		"ollama":              ollamaInfo,
+	})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultTagsCacheTTL is how long a GET /api/tags response is served from memory
+const DefaultTagsCacheTTL = 5 * time.Second
+
+// tagsCache holds the most recent /api/tags response so frequent model list
+// polls don't each round-trip to Ollama
+type tagsCache struct {
+	ttl       time.Duration
+	mu        sync.RWMutex
+	body      []byte
+	header    http.Header
+	fetchedAt time.Time
+}
+
+// newTagsCache creates a cache using TAGS_CACHE_TTL
+func newTagsCache() *tagsCache {
+	return &tagsCache{ttl: getEnvDuration("TAGS_CACHE_TTL", DefaultTagsCacheTTL)}
+}
+
+// isTagsRequest reports whether a request is a cacheable model list request
+func isTagsRequest(r *http.Request) bool {
+	return r.Method == http.MethodGet && r.URL.Path == "/api/tags" && r.URL.RawQuery == ""
+}
+
+// changesModelList reports whether a request can add or remove models
+func changesModelList(r *http.Request) bool {
+	switch r.URL.Path {
+	case "/api/pull", "/api/delete", "/api/create", "/api/copy":
+		return true
+	}
+	return false
+}
+
+// serve writes the cached response if it is still fresh
+func (c *tagsCache) serve(w http.ResponseWriter) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.body == nil || time.Since(c.fetchedAt) > c.ttl {
+		return false
+	}
+
+	for key, values := range c.header {
+		w.Header()[key] = values
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(c.body)))
+	w.Header().Set("X-Proxy-Cache", "HIT")
+	w.WriteHeader(http.StatusOK)
+	w.Write(c.body)
+	return true
+}
+
+// store caches a successful upstream /api/tags response. Encoded bodies are
+// skipped since the next client may not accept the same encoding.
+func (c *tagsCache) store(header http.Header, body []byte) {
+	if header.Get("Content-Encoding") != "" {
+		return
+	}
+	cached := make(http.Header)
+	if contentType := header.Get("Content-Type"); contentType != "" {
+		cached.Set("Content-Type", contentType)
+	}
+
+	c.mu.Lock()
+	c.body = body
+	c.header = cached
+	c.fetchedAt = time.Now()
+	c.mu.Unlock()
+}
+
+// invalidate drops the cached response so the next poll sees model changes
+func (c *tagsCache) invalidate() {
+	c.mu.Lock()
+	c.body = nil
+	c.mu.Unlock()
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// DefaultToolCallCategory is the prompt category for requests that offer tools
+const DefaultToolCallCategory = "tool_call"
+
+// loadToolCallCategory returns the category used for tool-enabled requests
+// (TOOL_CALL_CATEGORY), or "" when set to "none" to keep the regular categorizer
+func loadToolCallCategory() string {
+	category := strings.TrimSpace(os.Getenv("TOOL_CALL_CATEGORY"))
+	switch {
+	case category == "":
+		return DefaultToolCallCategory
+	case strings.EqualFold(category, "none"):
+		return ""
+	}
+	return category
+}
+
+// declaredTools returns the function names offered in a request's "tools"
+// array. Entries without a name are reported as "unknown", so a non-empty
+// result always means the request offered tools.
+func declaredTools(data map[string]interface{}) []string {
+	tools, ok := data["tools"].([]interface{})
+	if !ok {
+		return nil
+	}
+	names := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		name := ""
+		if t, ok := tool.(map[string]interface{}); ok {
+			name = functionName(t)
+		}
+		if name == "" {
+			name = "unknown"
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// invokedTools returns the function names the model called in a response or
+// stream chunk. Ollama reports them in message.tool_calls, OpenAI-compatible
+// endpoints in choices[0].message.tool_calls (or delta.tool_calls when streaming).
+func invokedTools(data map[string]interface{}) []string {
+	var calls []interface{}
+	if message, ok := data["message"].(map[string]interface{}); ok {
+		calls, _ = message["tool_calls"].([]interface{})
+	} else if choices, ok := data["choices"].([]interface{}); ok && len(choices) > 0 {
+		if choice, ok := choices[0].(map[string]interface{}); ok {
+			for _, key := range []string{"message", "delta"} {
+				if message, ok := choice[key].(map[string]interface{}); ok {
+					calls, _ = message["tool_calls"].([]interface{})
+					break
+				}
+			}
+		}
+	}
+
+	var names []string
+	for _, call := range calls {
+		c, ok := call.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		// Streamed OpenAI deltas repeat the call with only argument fragments;
+		// the name arrives once, in the first delta
+		if name := functionName(c); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// functionName reads {"function": {"name": ...}} from a tool or tool call
+func functionName(entry map[string]interface{}) string {
+	if fn, ok := entry["function"].(map[string]interface{}); ok {
+		if name, ok := fn["name"].(string); ok {
+			return strings.TrimSpace(name)
+		}
+	}
+	return ""
+}
+
+// toolLabel bounds the tool label to names the request declared, since the
+// model can reply with any string
+func toolLabel(name string, declared []string) string {
+	for _, d := range declared {
+		if d == name {
+			return name
+		}
+	}
+	return "other"
+}